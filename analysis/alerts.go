@@ -0,0 +1,344 @@
+package analysis
+
+/*
+ * Helium Analysis
+ * Copyright (c) 2021-2022 Aaron Turner  <aturner at synfin dot net>
+ *
+ * This program is free software: you can redistribute it
+ * and/or modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or with the authors permission any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	log "github.com/sirupsen/logrus"
+	bolt "go.etcd.io/bbolt"
+)
+
+const (
+	ALERT_NO_BEACON   = "no-beacon"   // no beacon in Hours
+	ALERT_NO_WITNESS  = "no-witness"  // we haven't witnessed anyone in Hours
+	ALERT_VALID_RATIO = "valid-ratio" // less than Percent of our witnesses in Hours were valid
+	ALERT_PEER_SILENT = "peer-silent" // a peer which heard us Minimum times hasn't in Hours
+
+	ALERT_FIRING   = "firing"
+	ALERT_RESOLVED = "resolved"
+
+	ALERT_KEY_PREFIX = "alert:" // META_BUCKET key prefix for firing alerts
+	ALERT_TIMEOUT    = 10 * time.Second
+)
+
+var ALERT_TYPES = []string{ALERT_NO_BEACON, ALERT_NO_WITNESS, ALERT_VALID_RATIO, ALERT_PEER_SILENT}
+
+type AlertRule struct {
+	Name    string  `json:"name"`
+	Type    string  `json:"type"`
+	Hours   float64 `json:"hours"`
+	Percent float64 `json:"percent"` // valid-ratio only
+	Minimum int     `json:"minimum"` // min witnesses for valid-ratio & peer-silent
+}
+
+type AlertConfig struct {
+	Rules    []AlertRule `json:"rules"`
+	Webhooks []string    `json:"webhooks"`
+}
+
+// An alert as sent to the webhooks
+type Alert struct {
+	Key      string `json:"key"` // unique for the rule, hotspot & peer
+	Status   string `json:"status"`
+	Rule     string `json:"rule"`
+	Type     string `json:"type"`
+	Address  string `json:"address"`
+	Name     string `json:"name"`
+	Peer     string `json:"peer,omitempty"`
+	PeerName string `json:"peer_name,omitempty"`
+	Message  string `json:"message"`
+	Since    int64  `json:"since"` // unix time the alert started firing
+	Time     int64  `json:"time"`  // unix time of this notification
+	// webhooks which haven't accepted this status yet.  Only saved, never sent.
+	Pending []string `json:"pending,omitempty"`
+}
+
+// Load & validate the alert rules from a JSON file
+func LoadAlertConfig(filename string) (AlertConfig, error) {
+	config := AlertConfig{}
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return config, err
+	}
+	if err = json.Unmarshal(data, &config); err != nil {
+		return config, fmt.Errorf("Unable to parse %s: %s", filename, err)
+	}
+
+	names := map[string]bool{}
+	for i, rule := range config.Rules {
+		if rule.Name == "" {
+			return config, fmt.Errorf("Alert rule #%d has no name", i+1)
+		} else if names[rule.Name] {
+			return config, fmt.Errorf("Duplicate alert rule: %s", rule.Name)
+		} else if strings.Contains(rule.Name, ":") {
+			return config, fmt.Errorf("Alert rule %s: name can not contain ':'", rule.Name)
+		}
+		names[rule.Name] = true
+
+		valid := false
+		for _, t := range ALERT_TYPES {
+			valid = valid || rule.Type == t
+		}
+		if !valid {
+			return config, fmt.Errorf("Alert rule %s: invalid type '%s'.  Must be one of: %s",
+				rule.Name, rule.Type, strings.Join(ALERT_TYPES, ", "))
+		}
+		if rule.Hours <= 0 {
+			return config, fmt.Errorf("Alert rule %s: hours must be > 0", rule.Name)
+		}
+		if rule.Type == ALERT_VALID_RATIO && (rule.Percent <= 0 || rule.Percent > 100) {
+			return config, fmt.Errorf("Alert rule %s: percent must be > 0 and <= 100", rule.Name)
+		}
+		if rule.Minimum < 1 {
+			config.Rules[i].Minimum = 1
+		}
+	}
+	return config, nil
+}
+
+// returns the unix time of the hours before now
+func hoursAgo(now time.Time, hours float64) int64 {
+	return now.Add(-time.Duration(hours * float64(time.Hour))).Unix()
+}
+
+// returns how long ago the unix time was in a human readable format
+func ago(now time.Time, t int64) string {
+	return now.Sub(time.Unix(t, 0)).Round(time.Minute).String()
+}
+
+// Evaluate the rules against the challenges and returns the firing alerts
+func (b *BoltDB) EvaluateAlerts(address string, challenges []Challenges, rules []AlertRule, now time.Time) ([]Alert, error) {
+	alerts := []Alert{}
+	name, err := b.GetHotspotName(address)
+	if err != nil {
+		return alerts, err
+	}
+
+	beacons := beaconTimes(address, challenges)
+	peers, results, err := b.getAllWitnessResults(address, challenges)
+	if err != nil {
+		return alerts, err
+	}
+
+	// all the times we heard someone (unix time) & if it was valid
+	rxTimes := []int64{}
+	rxValid := []bool{}
+	for _, peer := range peers {
+		for _, wr := range results[peer] {
+			if wr.Type == RX {
+				rxTimes = append(rxTimes, wr.Timestamp/1000000000)
+				rxValid = append(rxValid, wr.Valid)
+			}
+		}
+	}
+
+	newAlert := func(rule AlertRule, peer, message string) Alert {
+		key := fmt.Sprintf("%s:%s", address, rule.Name)
+		if peer != "" {
+			key = fmt.Sprintf("%s:%s", key, peer)
+		}
+		return Alert{
+			Key:     key,
+			Status:  ALERT_FIRING,
+			Rule:    rule.Name,
+			Type:    rule.Type,
+			Address: address,
+			Name:    name,
+			Peer:    peer,
+			Message: message,
+			Since:   now.Unix(),
+			Time:    now.Unix(),
+		}
+	}
+
+	for _, rule := range rules {
+		cutoff := hoursAgo(now, rule.Hours)
+
+		switch rule.Type {
+		case ALERT_NO_BEACON:
+			if len(beacons) == 0 {
+				alerts = append(alerts, newAlert(rule, "", fmt.Sprintf("%s has no beacons", name)))
+			} else if last := beacons[len(beacons)-1]; last < cutoff {
+				alerts = append(alerts, newAlert(rule, "", fmt.Sprintf(
+					"%s has not beaconed in %s", name, ago(now, last))))
+			}
+
+		case ALERT_NO_WITNESS:
+			var last int64 = 0
+			for _, t := range rxTimes {
+				if t > last {
+					last = t
+				}
+			}
+			if last == 0 {
+				alerts = append(alerts, newAlert(rule, "", fmt.Sprintf("%s has not witnessed any beacons", name)))
+			} else if last < cutoff {
+				alerts = append(alerts, newAlert(rule, "", fmt.Sprintf(
+					"%s has not witnessed a beacon in %s", name, ago(now, last))))
+			}
+
+		case ALERT_VALID_RATIO:
+			total := 0
+			valid := 0
+			for i, t := range rxTimes {
+				if t < cutoff {
+					continue
+				}
+				total += 1
+				if rxValid[i] {
+					valid += 1
+				}
+			}
+			if total < rule.Minimum {
+				continue
+			}
+			percent := float64(valid) / float64(total) * 100.0
+			if percent < rule.Percent {
+				alerts = append(alerts, newAlert(rule, "", fmt.Sprintf(
+					"Only %.0f%% of the %d witnesses by %s in the last %gh were valid",
+					percent, total, name, rule.Hours)))
+			}
+
+		case ALERT_PEER_SILENT:
+			// only meaningful if we've beaconed since the cutoff
+			beaconed := len(beacons) > 0 && beacons[len(beacons)-1] >= cutoff
+			if !beaconed {
+				continue
+			}
+			for _, peer := range peers {
+				heard := 0
+				var last int64 = 0
+				for _, wr := range results[peer] {
+					if wr.Type != TX {
+						continue
+					}
+					heard += 1
+					if t := wr.Timestamp / 1000000000; t > last {
+						last = t
+					}
+				}
+				if heard < rule.Minimum || last >= cutoff {
+					continue
+				}
+				alert := newAlert(rule, peer, "")
+				alert.PeerName = peer
+				if peerName, err := b.GetHotspotName(peer); err == nil {
+					alert.PeerName = peerName
+				}
+				alert.Message = fmt.Sprintf("%s heard %s %d times but not in %s",
+					alert.PeerName, name, heard, ago(now, last))
+				alerts = append(alerts, alert)
+			}
+		}
+	}
+	return alerts, nil
+}
+
+// Compares the firing alerts with the saved state and returns the alerts
+// which need to be sent: new firing alerts, resolved ones and saved alerts
+// which some webhooks haven't accepted yet.  Alerts which are still firing
+// are not returned again once every webhook has them.
+func (b *BoltDB) AlertChanges(address string, firing []Alert, now time.Time) ([]Alert, error) {
+	changes := []Alert{}
+	saved := map[string]Alert{}
+	prefix := []byte(fmt.Sprintf("%s%s:", ALERT_KEY_PREFIX, address))
+
+	err := b.db.View(func(tx *bolt.Tx) error {
+		cursor := tx.Bucket(META_BUCKET).Cursor()
+		for k, v := cursor.Seek(prefix); k != nil && strings.HasPrefix(string(k), string(prefix)); k, v = cursor.Next() {
+			alert := Alert{}
+			if err := json.Unmarshal(v, &alert); err != nil {
+				return err
+			}
+			saved[alert.Key] = alert
+		}
+		return nil
+	})
+	if err != nil {
+		return changes, err
+	}
+
+	current := map[string]bool{}
+	for _, alert := range firing {
+		current[alert.Key] = true
+		s, ok := saved[alert.Key]
+		switch {
+		case !ok || s.Status == ALERT_RESOLVED:
+			// new, or firing again before every webhook got the resolve
+			changes = append(changes, alert)
+		case len(s.Pending) > 0:
+			changes = append(changes, s)
+		}
+	}
+	for key, alert := range saved {
+		if current[key] {
+			continue
+		}
+		if alert.Status == ALERT_FIRING {
+			alert.Status = ALERT_RESOLVED
+			alert.Time = now.Unix()
+			alert.Message = fmt.Sprintf("Resolved after %s: %s", ago(now, alert.Since), alert.Message)
+			alert.Pending = nil
+		}
+		changes = append(changes, alert)
+	}
+	return changes, nil
+}
+
+// Record that the alert was sent so it isn't sent again.  Alerts with
+// Pending webhooks are kept so they can be retried.
+func (b *BoltDB) SaveAlert(alert Alert) error {
+	key := []byte(ALERT_KEY_PREFIX + alert.Key)
+	return b.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(META_BUCKET)
+		if alert.Status == ALERT_RESOLVED && len(alert.Pending) == 0 {
+			return bucket.Delete(key)
+		}
+		jdata, err := json.Marshal(alert)
+		if err != nil {
+			return err
+		}
+		return bucket.Put(key, jdata)
+	})
+}
+
+// POST the alert as JSON to the webhook
+func SendAlert(url string, alert Alert) error {
+	alert.Pending = nil
+	client := resty.New().SetTimeout(ALERT_TIMEOUT)
+	resp, err := client.R().
+		SetHeader("Content-Type", "application/json").
+		SetBody(alert).
+		Post(url)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode() < http.StatusOK || resp.StatusCode() >= http.StatusMultipleChoices {
+		return fmt.Errorf("Webhook %s returned %s", url, resp.Status())
+	}
+	log.Debugf("Sent %s alert %s to %s", alert.Status, alert.Key, url)
+	return nil
+}
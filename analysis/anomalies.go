@@ -0,0 +1,240 @@
+package analysis
+
+/*
+ * Helium Analysis
+ * Copyright (c) 2021-2022 Aaron Turner  <aturner at synfin dot net>
+ *
+ * This program is free software: you can redistribute it
+ * and/or modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or with the authors permission any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+const (
+	ANOMALY_RSSI        = "rssi"
+	ANOMALY_SNR         = "snr"
+	ANOMALY_DISAPPEARED = "disappeared"
+	ANOMALY_P_VALUE     = 0.001 // much stricter than SIGNIFICANCE_LEVEL since we test many segments
+	ANOMALY_MAX_DEPTH   = 4     // max levels of binary segmentation
+)
+
+// Settings for FindAnomalies()
+type AnomalySettings struct {
+	MinShift   float64 // minimum change in the mean in dB to report
+	MinSamples int     // minimum witnesses on each side of a change point
+	GapFactor  float64 // a peer disappeared if it has been silent this many times its usual interval
+}
+
+// A single dated change in the link with a peer
+type AnomalyEvent struct {
+	Time      int64   `json:"time"` // unix secs
+	Address   string  `json:"address"`
+	Name      string  `json:"name"`
+	Metric    string  `json:"metric"`    // rssi, snr or disappeared
+	Direction string  `json:"direction"` // tx (they hear us) or rx (we hear them)
+	Before    float64 `json:"before"`    // mean before the change or usual interval (hours)
+	After     float64 `json:"after"`     // mean after the change or time silent (hours)
+	Delta     float64 `json:"delta"`
+	PValue    float64 `json:"p_value"`
+	Message   string  `json:"message"`
+}
+
+// a time series of one metric for one direction of a link
+type anomalySeries struct {
+	times []int64 // unix secs
+	vals  []float64
+}
+
+// Find the change points in the series via binary segmentation: the CUSUM
+// estimate splits the series in two, the split is kept if the means on either
+// side are significantly different and then each side is searched again.
+// Returns the indexes of the change points in order.
+func findChangePoints(vals []float64, settings AnomalySettings, depth int) []int {
+	points := []int{}
+	if depth >= ANOMALY_MAX_DEPTH || len(vals) < settings.MinSamples*2 {
+		return points
+	}
+
+	idx := cusumChangePoint(vals)
+	if idx < settings.MinSamples || len(vals)-idx < settings.MinSamples {
+		return points
+	}
+	before := vals[:idx]
+	after := vals[idx:]
+	_, _, p := welchTTest(before, after)
+	if math.IsNaN(p) || p > ANOMALY_P_VALUE || math.Abs(mean(after)-mean(before)) < settings.MinShift {
+		return points
+	}
+
+	points = append(points, findChangePoints(before, settings, depth+1)...)
+	points = append(points, idx)
+	for _, i := range findChangePoints(after, settings, depth+1) {
+		points = append(points, idx+i)
+	}
+	return points
+}
+
+// returns the events for each change point in the series
+func seriesEvents(s anomalySeries, metric string, rxtx RXTX, settings AnomalySettings) []AnomalyEvent {
+	events := []AnomalyEvent{}
+	points := findChangePoints(s.vals, settings, 0)
+
+	// compare each segment to the one before it
+	start := 0
+	for i, idx := range points {
+		end := len(s.vals)
+		if i+1 < len(points) {
+			end = points[i+1]
+		}
+		before := s.vals[start:idx]
+		after := s.vals[idx:end]
+		_, _, p := welchTTest(before, after)
+		e := AnomalyEvent{
+			Time:      s.times[idx],
+			Metric:    metric,
+			Direction: "rx",
+			Before:    mean(before),
+			After:     mean(after),
+			PValue:    p,
+		}
+		if rxtx == TX {
+			e.Direction = "tx"
+		}
+		e.Delta = e.After - e.Before
+		if math.IsNaN(e.PValue) {
+			e.PValue = -1.0
+		}
+		events = append(events, e)
+		start = idx
+	}
+	return events
+}
+
+// returns the event if the peer has been silent for much longer than usual
+func disappeared(times []int64, now time.Time, settings AnomalySettings) (AnomalyEvent, bool) {
+	e := AnomalyEvent{Metric: ANOMALY_DISAPPEARED}
+	if len(times) < settings.MinSamples {
+		return e, false
+	}
+
+	intervals := []float64{}
+	longest := 0.0
+	for i := 1; i < len(times); i++ {
+		gap := float64(times[i] - times[i-1])
+		intervals = append(intervals, gap)
+		longest = math.Max(longest, gap)
+	}
+	last := times[len(times)-1]
+	silent := float64(now.Unix() - last)
+	usual := median(intervals)
+	if silent <= longest || silent <= usual*settings.GapFactor {
+		return e, false
+	}
+	e.Time = last
+	e.Before = usual / 3600.0
+	e.After = silent / 3600.0
+	e.Delta = e.After - e.Before
+	e.PValue = -1.0
+	return e, true
+}
+
+// returns a human readable description of the event
+func (e AnomalyEvent) describe() string {
+	date := FormatDate(time.Unix(e.Time, 0))
+	if e.Metric == ANOMALY_DISAPPEARED {
+		return fmt.Sprintf("%s stopped witnessing after %s: silent for %.0fh, usually every %.1fh",
+			e.Name, date, e.After, e.Before)
+	}
+
+	metric := "RSSI"
+	unit := "dB"
+	if e.Metric == ANOMALY_SNR {
+		metric = "SNR"
+	}
+	dir := "to"
+	if e.Direction == "rx" {
+		dir = "from"
+	}
+	change := "rose"
+	if e.Delta < 0 {
+		change = "dropped"
+	}
+	return fmt.Sprintf("%s %s %s %s %.0f%s on %s", metric, dir, e.Name, change, math.Abs(e.Delta), unit, date)
+}
+
+// Find change points in the RSSI & SNR of each direction of every link and
+// peers which used to witness regularly but have gone silent.  now is the
+// end of the time window.  Events are returned in order of time.
+func (b *BoltDB) FindAnomalies(address string, challenges []Challenges, now time.Time, settings AnomalySettings) ([]AnomalyEvent, error) {
+	events := []AnomalyEvent{}
+	if settings.MinSamples < 2 {
+		return events, fmt.Errorf("Minimum samples must be >= 2")
+	}
+
+	peers, results, err := b.getAllWitnessResults(address, challenges)
+	if err != nil {
+		return events, err
+	}
+
+	for _, peer := range peers {
+		name := peer
+		if n, err := b.GetHotspotName(peer); err == nil && n != "" {
+			name = n
+		}
+
+		wr := results[peer]
+		sort.SliceStable(wr, func(i, j int) bool { return wr[i].Timestamp < wr[j].Timestamp })
+
+		peerEvents := []AnomalyEvent{}
+		times := []int64{}
+		for _, rxtx := range []RXTX{TX, RX} {
+			rssi := anomalySeries{}
+			snr := anomalySeries{}
+			for _, r := range wr {
+				if r.Type != rxtx {
+					continue
+				}
+				t := time.Unix(0, r.Timestamp).Unix()
+				rssi.times = append(rssi.times, t)
+				rssi.vals = append(rssi.vals, float64(r.Signal))
+				snr.times = append(snr.times, t)
+				snr.vals = append(snr.vals, r.Snr)
+			}
+			peerEvents = append(peerEvents, seriesEvents(rssi, ANOMALY_RSSI, rxtx, settings)...)
+			peerEvents = append(peerEvents, seriesEvents(snr, ANOMALY_SNR, rxtx, settings)...)
+		}
+		for _, r := range wr {
+			times = append(times, time.Unix(0, r.Timestamp).Unix())
+		}
+		if e, ok := disappeared(times, now, settings); ok {
+			peerEvents = append(peerEvents, e)
+		}
+
+		for _, e := range peerEvents {
+			e.Address = peer
+			e.Name = name
+			e.Message = e.describe()
+			events = append(events, e)
+		}
+	}
+
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].Time < events[j].Time
+	})
+	return events, nil
+}
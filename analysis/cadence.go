@@ -0,0 +1,187 @@
+package analysis
+
+/*
+ * Helium Analysis
+ * Copyright (c) 2021-2022 Aaron Turner  <aturner at synfin dot net>
+ *
+ * This program is free software: you can redistribute it
+ * and/or modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or with the authors permission any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/wcharczuk/go-chart/v2"
+)
+
+const (
+	NETWORK_BEACON_INTERVAL = 8 * time.Hour // typical time between beacons on the network
+	CADENCE_OFFLINE_FACTOR  = 3.0           // current gap vs. median interval to flag
+	CADENCE_MAX_BINS        = 48            // max histogram bins, the last bin holds everything larger
+)
+
+// Beacon inter-arrival statistics.  All intervals are in hours.
+type BeaconCadence struct {
+	Address         string    `json:"address"`
+	Beacons         int       `json:"beacons"`
+	FirstBeacon     int64     `json:"first_beacon"` // unix secs
+	LastBeacon      int64     `json:"last_beacon"`  // unix secs
+	MeanInterval    float64   `json:"mean_interval"`
+	MedianInterval  float64   `json:"median_interval"`
+	P90Interval     float64   `json:"p90_interval"`
+	LongestGap      float64   `json:"longest_gap"`
+	LongestGapStart int64     `json:"longest_gap_start"` // unix secs
+	CurrentGap      float64   `json:"current_gap"`       // time since the last beacon
+	NetworkInterval float64   `json:"network_interval"`
+	NetworkRatio    float64   `json:"network_ratio"` // MeanInterval / NetworkInterval
+	Offline         bool      `json:"offline"`       // hotspot may be offline or unchallenged
+	Intervals       []float64 `json:"intervals"`
+}
+
+// returns the sorted unix times of all our beacons
+func beaconTimes(address string, challenges []Challenges) []int64 {
+	times := []int64{}
+	for _, challenge := range challenges {
+		if challenge.Path == nil || len(*challenge.Path) == 0 {
+			continue
+		}
+		if (*challenge.Path)[0].Challengee == address {
+			times = append(times, challenge.Time)
+		}
+	}
+	sort.Slice(times, func(i, j int) bool { return times[i] < times[j] })
+	return times
+}
+
+// Calculate how often the hotspot beacons.  now is the end of the time window
+// and is used to determine how long it has been since the last beacon.
+func GetBeaconCadence(address string, challenges []Challenges, now time.Time, network time.Duration) (BeaconCadence, error) {
+	cadence := BeaconCadence{
+		Address:         address,
+		NetworkInterval: network.Hours(),
+		Intervals:       []float64{},
+	}
+
+	times := beaconTimes(address, challenges)
+	cadence.Beacons = len(times)
+	if len(times) < 2 {
+		return cadence, fmt.Errorf("Need at least 2 beacons to calculate the cadence.  Have %d", len(times))
+	}
+	cadence.FirstBeacon = times[0]
+	cadence.LastBeacon = times[len(times)-1]
+
+	for i := 1; i < len(times); i++ {
+		gap := float64(times[i]-times[i-1]) / 3600.0
+		cadence.Intervals = append(cadence.Intervals, gap)
+		if gap > cadence.LongestGap {
+			cadence.LongestGap = gap
+			cadence.LongestGapStart = times[i-1]
+		}
+	}
+
+	cadence.MeanInterval = mean(cadence.Intervals)
+	cadence.MedianInterval = median(cadence.Intervals)
+	cadence.P90Interval = percentile(cadence.Intervals, 90.0)
+	if cadence.NetworkInterval > 0.0 {
+		cadence.NetworkRatio = cadence.MeanInterval / cadence.NetworkInterval
+	}
+
+	cadence.CurrentGap = now.Sub(time.Unix(cadence.LastBeacon, 0)).Hours()
+	cadence.Offline = cadence.CurrentGap > cadence.LongestGap &&
+		cadence.CurrentGap > cadence.MedianInterval*CADENCE_OFFLINE_FACTOR
+	return cadence, nil
+}
+
+// Creates the PNG histogram of the beacon intervals
+func (b *BoltDB) GenerateCadenceGraph(address string, cadence BeaconCadence, settings GraphSettings) error {
+	style := styleFor("beacon-cadence")
+	hotspotName, err := b.GetHotspotName(address)
+	if err != nil {
+		return err
+	}
+	artifact := Artifact{Hotspot: hotspotName, Address: address, Graph: "beacon-cadence"}
+
+	if len(cadence.Intervals) < settings.Min {
+		return fmt.Errorf("Only %d datapoints available", len(cadence.Intervals))
+	}
+
+	// one hour bins
+	bins := int(cadence.LongestGap) + 1
+	if bins > CADENCE_MAX_BINS {
+		bins = CADENCE_MAX_BINS
+	}
+	counts := make([]int, bins)
+	for _, gap := range cadence.Intervals {
+		bin := int(gap)
+		if bin >= bins {
+			bin = bins - 1
+		}
+		counts[bin] += 1
+	}
+
+	// whole number ticks on the y axis
+	maxCount := 0
+	for _, cnt := range counts {
+		if cnt > maxCount {
+			maxCount = cnt
+		}
+	}
+	step := maxCount/10 + 1
+	ticks := []chart.Tick{}
+	for i := 0; i <= maxCount; i += step {
+		ticks = append(ticks, chart.Tick{Value: float64(i), Label: fmt.Sprintf("%d", i)})
+	}
+
+	bars := []chart.Value{}
+	for i, cnt := range counts {
+		label := fmt.Sprintf("%d", i)
+		if i == CADENCE_MAX_BINS-1 {
+			label = fmt.Sprintf("%d+", i)
+		}
+		bars = append(bars, chart.Value{
+			Label: label,
+			Value: float64(cnt),
+			Style: chart.Style{
+				FillColor:   chart.ColorBlue,
+				StrokeColor: chart.ColorBlue,
+			},
+		})
+	}
+
+	graph := chart.BarChart{
+		Title: fmt.Sprintf("Hours Between Beacons for %s (mean %.1fh, network %.1fh)",
+			hotspotName, cadence.MeanInterval, cadence.NetworkInterval),
+		Height: style.Height,
+		Width:  style.Width,
+		Background: chart.Style{
+			Padding: chart.Box{
+				Top:    60,
+				Left:   20,
+				Right:  20,
+				Bottom: 20,
+			},
+		},
+		YAxis: chart.YAxis{
+			Name:  "beacons",
+			Range: &chart.ContinuousRange{Min: 0.0, Max: float64(maxCount)},
+			Ticks: ticks,
+		},
+		BarSpacing: 2,
+		BarWidth:   (style.Width - 80) / bins,
+		Bars:       bars,
+	}
+
+	return settings.SaveGraph(artifact, graph)
+}
@@ -176,6 +176,11 @@ func getRxResults(address string, challenges []Challenges) ([]ChallengeResult, e
 	return results, nil
 }
 
+// Returns the witness results between the hotspot and a single peer
+func (b *BoltDB) GetWitnessResults(address, peer string, challenges []Challenges) ([]WitnessResult, error) {
+	return b.getWitnessResults(address, peer, challenges)
+}
+
 func (b *BoltDB) getWitnessResults(address, witness string, challenges []Challenges) ([]WitnessResult, error) {
 	results := []WitnessResult{}
 	aHost, err := b.GetHotspot(address)
@@ -0,0 +1,357 @@
+package analysis
+
+/*
+ * Helium Analysis
+ * Copyright (c) 2021-2022 Aaron Turner  <aturner at synfin dot net>
+ *
+ * This program is free software: you can redistribute it
+ * and/or modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or with the authors permission any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/wcharczuk/go-chart/v2"
+)
+
+const (
+	SIGNIFICANCE_LEVEL = 0.05 // p-value
+	COMPARE_MAX_PEERS  = 10   // max peers in the compare graph
+)
+
+// Aggregate stats for one side of a compare
+type WindowStats struct {
+	First              int64   `json:"first"` // unix secs
+	Last               int64   `json:"last"`  // unix secs
+	Beacons            int     `json:"beacons"`
+	Witnesses          int     `json:"witnesses"` // witnesses of our beacons
+	WitnessesPerBeacon float64 `json:"witnesses_per_beacon"`
+	Peers              int     `json:"peers"`
+	RssiMean           float64 `json:"rssi_mean"`
+	SnrMean            float64 `json:"snr_mean"`
+	ValidRatio         float64 `json:"valid_ratio"`
+
+	witnessCounts []float64
+	rssi          []float64
+	snr           []float64
+}
+
+// Per-peer before/after stats
+type PeerCompare struct {
+	Address     string  `json:"address"`
+	Name        string  `json:"name"`
+	Km          float64 `json:"km"`
+	Status      string  `json:"status"` // both, gained or lost
+	CountBefore int     `json:"count_before"`
+	CountAfter  int     `json:"count_after"`
+	RssiBefore  float64 `json:"rssi_before"`
+	RssiAfter   float64 `json:"rssi_after"`
+	RssiDelta   float64 `json:"rssi_delta"`
+	SnrBefore   float64 `json:"snr_before"`
+	SnrAfter    float64 `json:"snr_after"`
+	SnrDelta    float64 `json:"snr_delta"`
+	ValidBefore float64 `json:"valid_ratio_before"`
+	ValidAfter  float64 `json:"valid_ratio_after"`
+	RssiPValue  float64 `json:"rssi_p_value"` // -1 if not enough data
+}
+
+// The result of comparing two windows of challenges around a split time
+type CompareReport struct {
+	Address        string        `json:"address"`
+	Split          int64         `json:"split"` // unix secs
+	Before         WindowStats   `json:"before"`
+	After          WindowStats   `json:"after"`
+	Peers          []PeerCompare `json:"peers"`
+	Gained         []string      `json:"gained"`
+	Lost           []string      `json:"lost"`
+	WitnessPValue  float64       `json:"witness_p_value"` // -1 if not enough data
+	RssiPValue     float64       `json:"rssi_p_value"`
+	SnrPValue      float64       `json:"snr_p_value"`
+	WitnessChanged bool          `json:"witness_changed"`
+	RssiChanged    bool          `json:"rssi_changed"`
+	SnrChanged     bool          `json:"snr_changed"`
+}
+
+// returns true if the p-value is statistically significant
+func isSignificant(p float64) bool {
+	return !math.IsNaN(p) && p < SIGNIFICANCE_LEVEL
+}
+
+// Splits the challenges into those before and at/after the split time
+func SplitChallenges(challenges []Challenges, split time.Time) ([]Challenges, []Challenges) {
+	before := []Challenges{}
+	after := []Challenges{}
+	for _, c := range challenges {
+		if c.Time < split.Unix() {
+			before = append(before, c)
+		} else {
+			after = append(after, c)
+		}
+	}
+	return before, after
+}
+
+// Calculate the window stats & per-peer stats for a list of challenges
+func (b *BoltDB) calcWindowStats(address string, challenges []Challenges) (WindowStats, map[string][]WitnessResult, error) {
+	ws := WindowStats{}
+	if len(challenges) > 0 {
+		ws.First = challenges[0].Time
+		ws.Last = challenges[len(challenges)-1].Time
+	}
+
+	for _, challenge := range challenges {
+		if challenge.Path == nil {
+			continue
+		}
+		path := *challenge.Path
+		if path[0].Challengee != address || path[0].Witnesses == nil {
+			continue
+		}
+		cnt := 0
+		for _, witness := range *path[0].Witnesses {
+			if witness.Gateway != address {
+				cnt += 1
+			}
+		}
+		ws.Beacons += 1
+		ws.Witnesses += cnt
+		ws.witnessCounts = append(ws.witnessCounts, float64(cnt))
+	}
+	ws.WitnessesPerBeacon = mean(ws.witnessCounts)
+
+	peers, results, err := b.getAllWitnessResults(address, challenges)
+	if err != nil {
+		return ws, results, err
+	}
+	ws.Peers = len(peers)
+
+	valid := 0
+	total := 0
+	for _, peer := range peers {
+		for _, r := range results[peer] {
+			ws.rssi = append(ws.rssi, float64(r.Signal))
+			ws.snr = append(ws.snr, r.Snr)
+			if r.Valid {
+				valid += 1
+			}
+			total += 1
+		}
+	}
+	ws.RssiMean = mean(ws.rssi)
+	ws.SnrMean = mean(ws.snr)
+	if total > 0 {
+		ws.ValidRatio = float64(valid) / float64(total)
+	}
+	return ws, results, nil
+}
+
+// returns the signals, SNR values & valid ratio for a list of witness results
+func witnessValues(wr []WitnessResult) ([]float64, []float64, float64) {
+	rssi := []float64{}
+	snr := []float64{}
+	valid := 0
+	for _, r := range wr {
+		rssi = append(rssi, float64(r.Signal))
+		snr = append(snr, r.Snr)
+		if r.Valid {
+			valid += 1
+		}
+	}
+	ratio := 0.0
+	if len(wr) > 0 {
+		ratio = float64(valid) / float64(len(wr))
+	}
+	return rssi, snr, ratio
+}
+
+// Compare the before & after challenges for the given hotspot
+func (b *BoltDB) Compare(address string, before, after []Challenges, split time.Time) (CompareReport, error) {
+	report := CompareReport{
+		Address: address,
+		Split:   split.Unix(),
+		Peers:   []PeerCompare{},
+		Gained:  []string{},
+		Lost:    []string{},
+	}
+
+	var err error
+	var bResults, aResults map[string][]WitnessResult
+	report.Before, bResults, err = b.calcWindowStats(address, before)
+	if err != nil {
+		return report, err
+	}
+	report.After, aResults, err = b.calcWindowStats(address, after)
+	if err != nil {
+		return report, err
+	}
+
+	addresses := map[string]bool{}
+	for peer := range bResults {
+		addresses[peer] = true
+	}
+	for peer := range aResults {
+		addresses[peer] = true
+	}
+
+	for peer := range addresses {
+		bwr := bResults[peer]
+		awr := aResults[peer]
+		pc := PeerCompare{
+			Address:     peer,
+			Name:        peer,
+			Status:      "both",
+			CountBefore: len(bwr),
+			CountAfter:  len(awr),
+			RssiPValue:  math.NaN(),
+		}
+		if name, err := b.GetHotspotName(peer); err == nil && name != "" {
+			pc.Name = name
+		}
+
+		bRssi, bSnr, bValid := witnessValues(bwr)
+		aRssi, aSnr, aValid := witnessValues(awr)
+		pc.RssiBefore, pc.SnrBefore, pc.ValidBefore = mean(bRssi), mean(bSnr), bValid
+		pc.RssiAfter, pc.SnrAfter, pc.ValidAfter = mean(aRssi), mean(aSnr), aValid
+
+		switch {
+		case len(bwr) == 0:
+			pc.Status = "gained"
+			pc.Km = awr[0].Km
+			report.Gained = append(report.Gained, pc.Name)
+		case len(awr) == 0:
+			pc.Status = "lost"
+			pc.Km = bwr[0].Km
+			report.Lost = append(report.Lost, pc.Name)
+		default:
+			pc.Km = bwr[0].Km
+			pc.RssiDelta = pc.RssiAfter - pc.RssiBefore
+			pc.SnrDelta = pc.SnrAfter - pc.SnrBefore
+			_, _, pc.RssiPValue = welchTTest(bRssi, aRssi)
+		}
+		report.Peers = append(report.Peers, pc)
+	}
+	sort.SliceStable(report.Peers, func(i, j int) bool {
+		return report.Peers[i].Km < report.Peers[j].Km
+	})
+	sort.Strings(report.Gained)
+	sort.Strings(report.Lost)
+
+	_, _, report.WitnessPValue = welchTTest(report.Before.witnessCounts, report.After.witnessCounts)
+	_, _, report.RssiPValue = welchTTest(report.Before.rssi, report.After.rssi)
+	_, _, report.SnrPValue = welchTTest(report.Before.snr, report.After.snr)
+	report.WitnessChanged = isSignificant(report.WitnessPValue)
+	report.RssiChanged = isSignificant(report.RssiPValue)
+	report.SnrChanged = isSignificant(report.SnrPValue)
+
+	// NaN isn't valid JSON
+	for i := range report.Peers {
+		if math.IsNaN(report.Peers[i].RssiPValue) {
+			report.Peers[i].RssiPValue = -1.0
+		}
+	}
+	for _, p := range []*float64{&report.WitnessPValue, &report.RssiPValue, &report.SnrPValue} {
+		if math.IsNaN(*p) {
+			*p = -1.0
+		}
+	}
+	return report, nil
+}
+
+// Creates the paired bar PNG of the mean RSSI for each peer before & after
+func (b *BoltDB) GenerateCompareGraph(address string, report CompareReport, settings GraphSettings) error {
+	style := styleFor("compare")
+	hotspotName, err := b.GetHotspotName(address)
+	if err != nil {
+		return err
+	}
+	artifact := Artifact{Hotspot: hotspotName, Address: address, Graph: "compare"}
+
+	peers := []PeerCompare{}
+	for _, p := range report.Peers {
+		if p.Status == "both" && p.CountBefore+p.CountAfter >= settings.Min {
+			peers = append(peers, p)
+		}
+	}
+	if len(peers) == 0 {
+		return fmt.Errorf("No peers with at least %d witnesses in both windows", settings.Min)
+	}
+	sort.SliceStable(peers, func(i, j int) bool {
+		return peers[i].CountBefore+peers[i].CountAfter > peers[j].CountBefore+peers[j].CountAfter
+	})
+	if len(peers) > COMPARE_MAX_PEERS {
+		peers = peers[:COMPARE_MAX_PEERS]
+	}
+
+	y_min := style.YMin
+	y_max := style.YMax
+	bars := []chart.Value{}
+	for _, p := range peers {
+		y_min = math.Min(y_min, math.Min(p.RssiBefore, p.RssiAfter)-5.0)
+		y_max = math.Max(y_max, math.Max(p.RssiBefore, p.RssiAfter)+5.0)
+		bars = append(bars,
+			chart.Value{
+				Label: strings.Replace(p.Name, "-", " ", -1),
+				Value: p.RssiBefore,
+				Style: chart.Style{
+					FillColor:   chart.ColorAlternateGray,
+					StrokeColor: chart.ColorAlternateGray,
+				},
+			},
+			chart.Value{
+				Value: p.RssiAfter,
+				Style: chart.Style{
+					FillColor:   chart.ColorGreen,
+					StrokeColor: chart.ColorGreen,
+				},
+			},
+		)
+	}
+
+	// make sure there is enough room for the peer names
+	width := style.Width
+	if len(bars)*64 > width {
+		width = len(bars) * 64
+	}
+
+	split := FormatDate(time.Unix(report.Split, 0))
+	graph := chart.BarChart{
+		Title:  fmt.Sprintf("Mean RSSI for %s before (gray) & after (green) %s", hotspotName, split),
+		Height: style.Height,
+		Width:  width,
+		Background: chart.Style{
+			Padding: chart.Box{
+				Top:    60,
+				Left:   20,
+				Right:  20,
+				Bottom: 60,
+			},
+		},
+		YAxis: chart.YAxis{
+			Name: "RSSI db",
+			Range: &chart.ContinuousRange{
+				Min: y_min,
+				Max: y_max,
+			},
+		},
+		UseBaseValue: true,
+		BaseValue:    y_min,
+		BarSpacing:   4,
+		Bars:         bars,
+	}
+
+	return settings.SaveGraph(artifact, graph)
+}
@@ -0,0 +1,216 @@
+package analysis
+
+/*
+ * Helium Analysis
+ * Copyright (c) 2021-2022 Aaron Turner  <aturner at synfin dot net>
+ *
+ * This program is free software: you can redistribute it
+ * and/or modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or with the authors permission any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/wcharczuk/go-chart/v2"
+)
+
+var COMPASS_POINTS []string = []string{
+	"N", "NNE", "NE", "ENE", "E", "ESE", "SE", "SSE",
+	"S", "SSW", "SW", "WSW", "W", "WNW", "NW", "NNW",
+}
+
+// Witness stats for all the peers in a compass sector
+type SectorStats struct {
+	Sector         string  `json:"sector"`
+	Start          float64 `json:"start"` // degrees
+	End            float64 `json:"end"`   // degrees
+	Peers          int     `json:"peers"`
+	TxCount        int     `json:"tx_count"`
+	RxCount        int     `json:"rx_count"`
+	ValidCount     int     `json:"valid_count"`
+	Invalid        int     `json:"invalid_count"`
+	WitnessSuccess float64 `json:"witness_success"` // fraction of our beacons witnessed by this sector
+	RssiMean       float64 `json:"rssi_mean"`
+	MaxKm          float64 `json:"max_km"`
+}
+
+// returns true if the number of sectors is supported
+func ValidSectors(sectors int) bool {
+	return sectors == 4 || sectors == 8 || sectors == 16
+}
+
+// returns the sector index for the given bearing
+func bearingSector(bearing float64, sectors int) int {
+	width := 360.0 / float64(sectors)
+	return int(math.Floor(math.Mod(bearing+width/2.0, 360.0)/width)) % sectors
+}
+
+// Calculate the directional coverage of our hotspot by grouping peers by bearing
+func (b *BoltDB) GetCoverage(address string, challenges []Challenges, sectors int) ([]SectorStats, error) {
+	if !ValidSectors(sectors) {
+		return []SectorStats{}, fmt.Errorf("Invalid number of sectors: %d.  Must be 4, 8 or 16", sectors)
+	}
+
+	width := 360.0 / float64(sectors)
+	coverage := make([]SectorStats, sectors)
+	for i := range coverage {
+		coverage[i].Sector = COMPASS_POINTS[i*len(COMPASS_POINTS)/sectors]
+		coverage[i].Start = math.Mod(float64(i)*width-width/2.0+360.0, 360.0)
+		coverage[i].End = float64(i)*width + width/2.0
+	}
+
+	peers, err := b.GetPeerStats(address, challenges)
+	if err != nil {
+		return coverage, err
+	}
+
+	peerSector := map[string]int{}
+	rssiSum := make([]float64, sectors)
+	for _, p := range peers {
+		// unknown or unasserted peers have no direction
+		if !p.HasBearing {
+			continue
+		}
+		s := bearingSector(p.Bearing, sectors)
+		peerSector[p.Address] = s
+
+		c := &coverage[s]
+		c.Peers += 1
+		c.TxCount += p.TxCount
+		c.RxCount += p.RxCount
+		c.ValidCount += p.ValidCount
+		c.Invalid += p.Invalid
+		rssiSum[s] += p.RssiMean * float64(p.TxCount+p.RxCount)
+		c.MaxKm = math.Max(c.MaxKm, p.Km)
+	}
+
+	// which sectors heard each of our beacons?
+	beacons := 0
+	heard := make([]int, sectors)
+	for _, challenge := range challenges {
+		if challenge.Path == nil {
+			continue
+		}
+		path := *challenge.Path
+		if path[0].Challengee != address || path[0].Witnesses == nil {
+			continue
+		}
+		beacons += 1
+		seen := map[int]bool{}
+		for _, witness := range *path[0].Witnesses {
+			if s, ok := peerSector[witness.Gateway]; ok {
+				seen[s] = true
+			}
+		}
+		for s := range seen {
+			heard[s] += 1
+		}
+	}
+
+	for i := range coverage {
+		total := coverage[i].TxCount + coverage[i].RxCount
+		if total > 0 {
+			coverage[i].RssiMean = rssiSum[i] / float64(total)
+		}
+		if beacons > 0 {
+			coverage[i].WitnessSuccess = float64(heard[i]) / float64(beacons)
+		}
+	}
+	return coverage, nil
+}
+
+// Creates the polar PNGs for witness success, max distance & mean RSSI per sector
+func (b *BoltDB) GenerateCoverageGraphs(address string, coverage []SectorStats, settings GraphSettings) error {
+	hotspotName, err := b.GetHotspotName(address)
+	if err != nil {
+		return err
+	}
+
+	labels := []string{}
+	maxKm := 0.0
+	for _, c := range coverage {
+		labels = append(labels, c.Sector)
+		maxKm = math.Max(maxKm, c.MaxKm)
+	}
+	if maxKm == 0.0 {
+		return fmt.Errorf("No peers with a known location")
+	}
+
+	// RSSI is scaled to the RSSI axis of the rssi graph
+	style := styleFor("coverage-rssi")
+	success := []float64{}
+	distance := []float64{}
+	rssi := []float64{}
+	for _, c := range coverage {
+		success = append(success, c.WitnessSuccess)
+		distance = append(distance, c.MaxKm/maxKm)
+		if c.TxCount+c.RxCount > 0 {
+			rssi = append(rssi, (c.RssiMean-style.YMin)/(style.YMax-style.YMin))
+		} else {
+			rssi = append(rssi, 0.0)
+		}
+	}
+
+	ringLabels := func(min, max float64, format string) []string {
+		ret := []string{}
+		for ring := 1; ring <= POLAR_RINGS; ring++ {
+			ret = append(ret, fmt.Sprintf(format, min+(max-min)*float64(ring)/float64(POLAR_RINGS)))
+		}
+		return ret
+	}
+
+	charts := []struct {
+		graph string
+		chart PolarChart
+	}{
+		{
+			"coverage-success",
+			PolarChart{
+				Title:      fmt.Sprintf("Beacon Witness Success by Direction for %s", hotspotName),
+				Values:     success,
+				RingLabels: ringLabels(0.0, 100.0, "%.0f%%"),
+				Color:      chart.ColorGreen,
+			},
+		},
+		{
+			"coverage-distance",
+			PolarChart{
+				Title:      fmt.Sprintf("Max Peer Distance by Direction for %s", hotspotName),
+				Values:     distance,
+				RingLabels: ringLabels(0.0, maxKm, "%.1fkm"),
+				Color:      chart.ColorBlue,
+			},
+		},
+		{
+			"coverage-rssi",
+			PolarChart{
+				Title:      fmt.Sprintf("Mean RSSI by Direction for %s", hotspotName),
+				Values:     rssi,
+				RingLabels: ringLabels(style.YMin, style.YMax, "%.0fdB"),
+				Color:      chart.ColorOrange,
+			},
+		},
+	}
+
+	for _, c := range charts {
+		c.chart.Labels = labels
+		size := styleFor(c.graph).Height * 3 / 2
+		c.chart.Width = size
+		c.chart.Height = size
+		if err := settings.SaveGraph(Artifact{Hotspot: hotspotName, Address: address, Graph: c.graph}, c.chart); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package analysis
+
+/*
+ * Helium Analysis
+ * Copyright (c) 2021-2022 Aaron Turner  <aturner at synfin dot net>
+ *
+ * This program is free software: you can redistribute it
+ * and/or modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or with the authors permission any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+import (
+	"testing"
+)
+
+func TestBearingSector(t *testing.T) {
+	tests := []struct {
+		bearing  float64
+		sectors  int
+		expected int
+	}{
+		{0.0, 4, 0},
+		{44.9, 4, 0},
+		{45.0, 4, 1},
+		{180.0, 4, 2},
+		{315.0, 4, 0},
+		{314.9, 4, 3},
+		{359.9, 4, 0},
+		{22.5, 8, 1},
+		{90.0, 8, 2},
+		{337.4, 8, 7},
+		{337.5, 8, 0},
+		{11.25, 16, 1},
+		{348.75, 16, 0},
+	}
+	for _, test := range tests {
+		if got := bearingSector(test.bearing, test.sectors); got != test.expected {
+			t.Errorf("bearingSector(%g, %d) = %d, expected %d",
+				test.bearing, test.sectors, got, test.expected)
+		}
+	}
+}
+
+func TestValidSectors(t *testing.T) {
+	for _, sectors := range []int{4, 8, 16} {
+		if !ValidSectors(sectors) {
+			t.Errorf("Expected %d sectors to be valid", sectors)
+		}
+	}
+	for _, sectors := range []int{0, 1, 3, 6, 32} {
+		if ValidSectors(sectors) {
+			t.Errorf("Expected %d sectors to be invalid", sectors)
+		}
+	}
+}
@@ -24,6 +24,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"sort"
 	"strings"
 	"time"
 
@@ -46,6 +47,7 @@ type BoltDB struct {
 	db             *bolt.DB
 	hotspotCache   map[string]Hotspot
 	hotspotAddress map[string]string
+	aliases        map[string]string // alias => hotspot name or address
 }
 
 func OpenDB(filename string, init bool) (*BoltDB, error) {
@@ -66,6 +68,7 @@ func OpenDB(filename string, init bool) (*BoltDB, error) {
 		db:             x,
 		hotspotCache:   map[string]Hotspot{},
 		hotspotAddress: map[string]string{},
+		aliases:        map[string]string{},
 	}
 
 	// initialize
@@ -185,6 +188,32 @@ func (b *BoltDB) GetHotspots() ([]Hotspot, error) {
 	return hotspots, err
 }
 
+// Get all the hotspots in the DB owned by any of the given wallets, sorted by name
+func (b *BoltDB) GetHotspotsByOwner(owners []string) ([]Hotspot, error) {
+	ret := []Hotspot{}
+	hotspots, err := b.GetHotspots()
+	if err != nil {
+		return ret, err
+	}
+
+	wanted := map[string]bool{}
+	for _, owner := range owners {
+		wanted[owner] = true
+	}
+	for _, h := range hotspots {
+		if wanted[h.Owner] {
+			ret = append(ret, h)
+		}
+	}
+	if len(ret) == 0 {
+		return ret, fmt.Errorf("No hotspots owned by %s.  Refresh hotspot cache?", strings.Join(owners, ", "))
+	}
+	sort.SliceStable(ret, func(i, j int) bool {
+		return ret[i].Name < ret[j].Name
+	})
+	return ret, nil
+}
+
 // Write a list of hotspots to the database under the address and name
 func (b *BoltDB) SetHotspots(hotspots []Hotspot) error {
 	err := b.db.Update(func(tx *bolt.Tx) error {
@@ -432,10 +461,14 @@ func (b *BoltDB) LoadChallenges(address string, first, last time.Time, holddown
 func (b *BoltDB) GetChallenges(address string, first time.Time, last time.Time) ([]Challenges, error) {
 	challenges := []Challenges{}
 
-	err := b.db.Update(func(tx *bolt.Tx) error {
-		buck, err := ChallengeBucket(tx, address)
-		if err != nil {
-			return err
+	if len(strings.Split(address, "-")) == 3 {
+		return challenges, fmt.Errorf("Invalid address: %s", address)
+	}
+	// read only so looking up an unknown hotspot doesn't create its bucket
+	err := b.db.View(func(tx *bolt.Tx) error {
+		buck := tx.Bucket(CHALLENGES_BUCKET).Bucket([]byte(address))
+		if buck == nil {
+			return nil
 		}
 		cursor := buck.Cursor()
 		minKey := make([]byte, 8)
@@ -456,11 +489,21 @@ func (b *BoltDB) GetChallenges(address string, first time.Time, last time.Time)
 	return challenges, err
 }
 
-// Returns the address of the hotspot given an address or name
+// Set short aliases which can be used instead of a hotspot name or address
+func (b *BoltDB) SetAliases(aliases map[string]string) {
+	b.aliases = aliases
+}
+
+// Returns the address of the hotspot given an address, name or alias
 func (b *BoltDB) GetHotspotByUnknown(addressOrName string) (string, error) {
 	var hotspotAddress string
 	var err error
 
+	if hotspot, ok := b.aliases[addressOrName]; ok {
+		log.Debugf("Alias %s => %s", addressOrName, hotspot)
+		addressOrName = hotspot
+	}
+
 	x := strings.Split(addressOrName, "-")
 
 	if len(x) == 3 {
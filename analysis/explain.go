@@ -0,0 +1,257 @@
+package analysis
+
+/*
+ * Helium Analysis
+ * Copyright (c) 2021-2022 Aaron Turner  <aturner at synfin dot net>
+ *
+ * This program is free software: you can redistribute it
+ * and/or modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or with the authors permission any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+type InvalidReason int
+
+const (
+	REASON_UNKNOWN InvalidReason = iota
+	REASON_TOO_STRONG
+	REASON_BELOW_SNR_FLOOR
+	REASON_TOO_CLOSE
+)
+
+const (
+	POC_PARENT_RES      = 11  // witnesses are checked for distance in res 11 hexes
+	POC_EXCLUSION_CELLS = 8   // witnesses this many res 11 hexes away are too close
+	EXPLAIN_DOMINANT    = 0.5 // fraction of invalid witnesses to call a reason dominant
+	EXPLAIN_MANY_PEERS  = 3   // number of peers with the same problem before we blame ourselves
+)
+
+// approximately 345m
+var MIN_WITNESS_DISTANCE float64 = POC_EXCLUSION_CELLS * math.Sqrt(3.0) * H3EdgeLengthKm(POC_PARENT_RES)
+
+func (r InvalidReason) String() string {
+	switch r {
+	case REASON_TOO_STRONG:
+		return "too strong"
+	case REASON_BELOW_SNR_FLOOR:
+		return "below SNR floor"
+	case REASON_TOO_CLOSE:
+		return "too close"
+	}
+	return "unknown"
+}
+
+// Classify why the given witness result is invalid.  sameHex should be true
+// if both hotspots are asserted in the same POC_PARENT_RES hex.
+func classifyInvalid(r WitnessResult, sameHex bool) InvalidReason {
+	if sameHex || r.Km < MIN_WITNESS_DISTANCE {
+		return REASON_TOO_CLOSE
+	} else if float64(r.Signal) > maxRssi(r.Km) {
+		return REASON_TOO_STRONG
+	} else if float64(r.Signal) < r.ValidThreshold {
+		return REASON_BELOW_SNR_FLOOR
+	}
+	return REASON_UNKNOWN
+}
+
+// Invalid witness reasons for a single peer
+type ExplainPeer struct {
+	Address   string  `json:"address"`
+	Name      string  `json:"name"`
+	Km        float64 `json:"km"`
+	Total     int     `json:"total"`
+	Invalid   int     `json:"invalid"`
+	TxInvalid int     `json:"tx_invalid"`
+	RxInvalid int     `json:"rx_invalid"`
+	TooStrong int     `json:"too_strong"`
+	BelowSnr  int     `json:"below_snr_floor"`
+	TooClose  int     `json:"too_close"`
+	Unknown   int     `json:"unknown"`
+}
+
+func (e *ExplainPeer) add(reason InvalidReason) {
+	switch reason {
+	case REASON_TOO_STRONG:
+		e.TooStrong += 1
+	case REASON_BELOW_SNR_FLOOR:
+		e.BelowSnr += 1
+	case REASON_TOO_CLOSE:
+		e.TooClose += 1
+	default:
+		e.Unknown += 1
+	}
+}
+
+// returns the reason with the most invalid witnesses if it is dominant
+func (e ExplainPeer) dominant() (InvalidReason, bool) {
+	counts := map[InvalidReason]int{
+		REASON_TOO_STRONG:      e.TooStrong,
+		REASON_BELOW_SNR_FLOOR: e.BelowSnr,
+		REASON_TOO_CLOSE:       e.TooClose,
+		REASON_UNKNOWN:         e.Unknown,
+	}
+	best := REASON_UNKNOWN
+	for _, reason := range []InvalidReason{REASON_TOO_CLOSE, REASON_TOO_STRONG, REASON_BELOW_SNR_FLOOR} {
+		if counts[reason] > counts[best] {
+			best = reason
+		}
+	}
+	if e.Invalid == 0 || float64(counts[best])/float64(e.Invalid) < EXPLAIN_DOMINANT {
+		return best, false
+	}
+	return best, true
+}
+
+type ExplainReport struct {
+	Address     string        `json:"address"`
+	Peers       []ExplainPeer `json:"peers"`
+	Totals      ExplainPeer   `json:"totals"`
+	Suggestions []string      `json:"suggestions"`
+}
+
+// Classify all the invalid witnesses between the hotspot and the given peer.
+// If peer is empty, all peers are included.
+func (b *BoltDB) Explain(address, peer string, challenges []Challenges) (ExplainReport, error) {
+	report := ExplainReport{
+		Address:     address,
+		Peers:       []ExplainPeer{},
+		Suggestions: []string{},
+		Totals: ExplainPeer{
+			Address: address,
+			Name:    "Total",
+		},
+	}
+
+	aHost, err := b.GetHotspot(address)
+	if err != nil {
+		return report, err
+	}
+
+	peers, results, err := b.getAllWitnessResults(address, challenges)
+	if err != nil {
+		return report, err
+	}
+	if peer != "" {
+		if _, ok := results[peer]; !ok {
+			return report, fmt.Errorf("No witnesses between %s and %s", address, peer)
+		}
+		peers = []string{peer}
+	}
+
+	for _, p := range peers {
+		pHost, err := b.GetHotspot(p)
+		if err != nil {
+			return report, err
+		}
+		sameHex, err := SameHex(aHost.Location, pHost.Location, POC_PARENT_RES)
+		if err != nil {
+			sameHex = aHost.Location != "" && aHost.Location == pHost.Location
+		}
+
+		e := ExplainPeer{
+			Address: p,
+			Name:    p,
+			Km:      results[p][0].Km,
+		}
+		if pHost.Name != "" {
+			e.Name = pHost.Name
+		}
+		for _, r := range results[p] {
+			e.Total += 1
+			if r.Valid {
+				continue
+			}
+			e.Invalid += 1
+			if r.Type == TX {
+				e.TxInvalid += 1
+			} else {
+				e.RxInvalid += 1
+			}
+			reason := classifyInvalid(r, sameHex)
+			e.add(reason)
+			report.Totals.add(reason)
+		}
+		report.Totals.Total += e.Total
+		report.Totals.Invalid += e.Invalid
+		report.Totals.TxInvalid += e.TxInvalid
+		report.Totals.RxInvalid += e.RxInvalid
+		report.Peers = append(report.Peers, e)
+	}
+
+	sort.SliceStable(report.Peers, func(i, j int) bool {
+		return report.Peers[i].Invalid > report.Peers[j].Invalid
+	})
+	report.Suggestions = suggestCauses(report)
+	return report, nil
+}
+
+// Come up with some human readable likely causes for the invalid witnesses
+func suggestCauses(report ExplainReport) []string {
+	suggestions := []string{}
+	if report.Totals.Invalid == 0 {
+		return append(suggestions, "No invalid witnesses.  Nothing to explain!")
+	}
+
+	// which peers have a dominant reason?
+	byReason := map[InvalidReason][]string{}
+	for _, p := range report.Peers {
+		if reason, ok := p.dominant(); ok {
+			byReason[reason] = append(byReason[reason], p.Name)
+		}
+	}
+
+	if peers := byReason[REASON_TOO_STRONG]; len(peers) > 0 {
+		if len(peers) >= EXPLAIN_MANY_PEERS {
+			suggestions = append(suggestions, fmt.Sprintf(
+				"%d peers see signals too strong for the asserted distance.  Likely causes: "+
+					"excessive antenna gain or TX power, or the asserted location of this hotspot is wrong.",
+				len(peers)))
+		} else {
+			for _, name := range peers {
+				suggestions = append(suggestions, fmt.Sprintf(
+					"Signals with %s are too strong for the asserted distance.  Likely cause: "+
+						"the asserted location of %s is wrong.", name, name))
+			}
+		}
+	}
+
+	if peers := byReason[REASON_BELOW_SNR_FLOOR]; len(peers) > 0 {
+		suggestions = append(suggestions, fmt.Sprintf(
+			"%d peer(s) have RSSI below the floor for the reported SNR.  Likely causes: "+
+				"local RF noise/interference, or a faulty cable, connector or lightning arrestor.",
+			len(peers)))
+	}
+
+	if peers := byReason[REASON_TOO_CLOSE]; len(peers) > 0 {
+		suggestions = append(suggestions, fmt.Sprintf(
+			"%d peer(s) are in the same hex or closer than %.0fm and will always be invalid.  "+
+				"Consider moving one of the hotspots.",
+			len(peers), MIN_WITNESS_DISTANCE*1000.0))
+	}
+
+	if peers := byReason[REASON_UNKNOWN]; len(peers) > 0 {
+		suggestions = append(suggestions, fmt.Sprintf(
+			"%d peer(s) are invalid for reasons not explained by RSSI, SNR or distance.  "+
+				"Possible causes: time sync, packet forwarder/firmware issues or a denylisted hotspot.",
+			len(peers)))
+	}
+
+	if len(suggestions) == 0 {
+		suggestions = append(suggestions, "No single cause stands out.  Check the per-peer breakdown.")
+	}
+	return suggestions
+}
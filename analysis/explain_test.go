@@ -0,0 +1,67 @@
+package analysis
+
+/*
+ * Helium Analysis
+ * Copyright (c) 2021-2022 Aaron Turner  <aturner at synfin dot net>
+ *
+ * This program is free software: you can redistribute it
+ * and/or modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or with the authors permission any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+import (
+	"testing"
+)
+
+func TestClassifyInvalid(t *testing.T) {
+	// maxRssi(1km) = -60.07dBm, maxRssi(10km) = -80.07dBm
+	tests := []struct {
+		name     string
+		result   WitnessResult
+		sameHex  bool
+		expected InvalidReason
+	}{
+		{"same hex", WitnessResult{Km: 5.0, Signal: -100, ValidThreshold: -120}, true, REASON_TOO_CLOSE},
+		{"too close", WitnessResult{Km: 0.2, Signal: -100, ValidThreshold: -120}, false, REASON_TOO_CLOSE},
+		{"too strong", WitnessResult{Km: 1.0, Signal: -55, ValidThreshold: -120}, false, REASON_TOO_STRONG},
+		{"barely too strong", WitnessResult{Km: 1.0, Signal: -60, ValidThreshold: -120}, false, REASON_TOO_STRONG},
+		{"barely ok", WitnessResult{Km: 1.0, Signal: -61, ValidThreshold: -120}, false, REASON_UNKNOWN},
+		{"below snr floor", WitnessResult{Km: 10.0, Signal: -125, ValidThreshold: -118}, false, REASON_BELOW_SNR_FLOOR},
+		{"unknown", WitnessResult{Km: 10.0, Signal: -100, ValidThreshold: -118}, false, REASON_UNKNOWN},
+	}
+	for _, test := range tests {
+		if got := classifyInvalid(test.result, test.sameHex); got != test.expected {
+			t.Errorf("%s: classifyInvalid() = %s, expected %s", test.name, got, test.expected)
+		}
+	}
+}
+
+func TestExplainPeerDominant(t *testing.T) {
+	tests := []struct {
+		peer     ExplainPeer
+		expected InvalidReason
+		dominant bool
+	}{
+		{ExplainPeer{}, REASON_UNKNOWN, false},
+		{ExplainPeer{Invalid: 4, TooStrong: 3, BelowSnr: 1}, REASON_TOO_STRONG, true},
+		{ExplainPeer{Invalid: 4, TooClose: 2, BelowSnr: 1, Unknown: 1}, REASON_TOO_CLOSE, true},
+		{ExplainPeer{Invalid: 5, TooStrong: 2, BelowSnr: 2, Unknown: 1}, REASON_TOO_STRONG, false},
+		{ExplainPeer{Invalid: 4, Unknown: 3, BelowSnr: 1}, REASON_UNKNOWN, true},
+	}
+	for i, test := range tests {
+		reason, dominant := test.peer.dominant()
+		if reason != test.expected || dominant != test.dominant {
+			t.Errorf("%d: dominant() = %s, %v, expected %s, %v",
+				i, reason, dominant, test.expected, test.dominant)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package analysis
+
+/*
+ * Helium Analysis
+ * Copyright (c) 2021-2022 Aaron Turner  <aturner at synfin dot net>
+ *
+ * This program is free software: you can redistribute it
+ * and/or modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or with the authors permission any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+// Summary of a single hotspot in a fleet
+type FleetStats struct {
+	Address         string  `json:"address"`
+	Name            string  `json:"name"`
+	Owner           string  `json:"owner"`
+	Online          string  `json:"online"`
+	Beacons         int     `json:"beacons"`
+	BeaconWitnesses int     `json:"beacon_witnesses"` // number of times our beacons were witnessed
+	Witnessed       int     `json:"witnessed"`        // number of beacons we witnessed
+	Valid           int     `json:"valid"`            // of the beacons we witnessed
+	Invalid         int     `json:"invalid"`          // of the beacons we witnessed
+	ValidRatio      float64 `json:"valid_ratio"`
+	LastActivity    int64   `json:"last_activity"` // unix secs, 0 if never
+}
+
+// Calculates the fleet summary stats for a single hotspot
+func GetFleetStats(h Hotspot, challenges []Challenges) FleetStats {
+	stats := FleetStats{
+		Address: h.Address,
+		Name:    h.Name,
+		Owner:   h.Owner,
+	}
+	if h.Status != nil {
+		stats.Online = h.Status.Online
+	}
+
+	for _, challenge := range challenges {
+		if challenge.Path == nil {
+			continue
+		}
+		for _, path := range *challenge.Path {
+			if path.Witnesses == nil {
+				continue
+			}
+			if path.Challengee == h.Address {
+				stats.Beacons += 1
+				if challenge.Time > stats.LastActivity {
+					stats.LastActivity = challenge.Time
+				}
+			}
+			for _, wit := range *path.Witnesses {
+				if path.Challengee == h.Address && wit.Gateway != h.Address {
+					stats.BeaconWitnesses += 1
+				} else if wit.Gateway == h.Address && path.Challengee != h.Address {
+					stats.Witnessed += 1
+					if wit.IsValid {
+						stats.Valid += 1
+					} else {
+						stats.Invalid += 1
+					}
+				} else {
+					continue
+				}
+				if challenge.Time > stats.LastActivity {
+					stats.LastActivity = challenge.Time
+				}
+			}
+		}
+	}
+
+	if stats.Witnessed > 0 {
+		stats.ValidRatio = float64(stats.Valid) / float64(stats.Witnessed)
+	}
+	return stats
+}
@@ -19,10 +19,8 @@ package analysis
  */
 
 import (
-	"encoding/json"
 	"fmt"
-	"io/ioutil"
-	"os"
+	"time"
 
 	"github.com/wcharczuk/go-chart/v2"
 
@@ -32,9 +30,13 @@ import (
 type RXTX int
 
 type GraphSettings struct {
-	Min  int  // minimum challenges
-	Zoom bool // zoom in
-	Json bool // generate json for each pair
+	Min        int              // minimum challenges
+	Zoom       bool             // zoom in
+	Json       bool             // generate json for each pair
+	Formats    []string         // png, svg and/or pdf.  Default is png
+	Pdf        *PdfDocument     // required for pdf
+	Collection *GraphCollection // keep graphs in memory instead of writing files
+	Output     *Output          // file names & manifest.  Default is the current directory
 }
 
 const (
@@ -42,24 +44,14 @@ const (
 	TX
 )
 
-const (
-	HEIGHT   = 512
-	WIDTH    = 1024
-	Y_MIN    = -130.0
-	Y_MAX    = -70.0
-	DOT_SIZE = 3
-	SNR_MIN  = -20.0
-	SNR_MAX  = 16.0
-)
-
-// Creates the PNG for the the beacons sent
+// Creates the graph for the the beacons sent
 func (b *BoltDB) GenerateBeaconsGraph(address string, results []Challenges, settings GraphSettings) error {
+	style := styleFor("beacon-totals")
 	hotspotName, err := b.GetHotspotName(address)
 	if err != nil {
 		return err
 	}
-	filename := fmt.Sprintf("%s/beacon-totals.png", hotspotName)
-	jsonFilename := fmt.Sprintf("%s/beacon-totals.json", hotspotName)
+	artifact := Artifact{Hotspot: hotspotName, Address: address, Graph: "beacon-totals"}
 
 	x_data := []float64{}
 	valid_data := []float64{}
@@ -92,11 +84,8 @@ func (b *BoltDB) GenerateBeaconsGraph(address string, results []Challenges, sett
 	}
 
 	if settings.Json {
-		jdata, err := json.MarshalIndent(matchChallenges, "", "  ")
-		if err != nil {
-			log.WithError(err).Errorf("Unable to generate %s", jsonFilename)
-		} else {
-			ioutil.WriteFile(jsonFilename, jdata, 0644)
+		if err = settings.SaveJson(artifact, matchChallenges); err != nil {
+			log.WithError(err).Errorf("Unable to generate %s JSON", artifact.key())
 		}
 	}
 
@@ -107,7 +96,7 @@ func (b *BoltDB) GenerateBeaconsGraph(address string, results []Challenges, sett
 	validSeries := chart.ContinuousSeries{
 		Name: "Valid",
 		Style: chart.Style{
-			StrokeColor: chart.ColorGreen,
+			StrokeColor: style.Colors.Valid,
 		},
 		XValues: x_data,
 		YValues: valid_data,
@@ -117,7 +106,7 @@ func (b *BoltDB) GenerateBeaconsGraph(address string, results []Challenges, sett
 		Style: chart.Style{
 			StrokeWidth:     1,
 			DotWidth:        chart.Disabled,
-			StrokeColor:     chart.ColorGreen,
+			StrokeColor:     style.Colors.Valid,
 			StrokeDashArray: []float64{5.0, 5.0},
 		},
 		InnerSeries: validSeries,
@@ -126,7 +115,7 @@ func (b *BoltDB) GenerateBeaconsGraph(address string, results []Challenges, sett
 	invalidSeries := chart.ContinuousSeries{
 		Name: "Invalid",
 		Style: chart.Style{
-			StrokeColor: chart.ColorRed,
+			StrokeColor: style.Colors.Invalid,
 		},
 		XValues: x_data,
 		YValues: invalid_data,
@@ -136,7 +125,7 @@ func (b *BoltDB) GenerateBeaconsGraph(address string, results []Challenges, sett
 		Style: chart.Style{
 			StrokeWidth:     1,
 			DotWidth:        chart.Disabled,
-			StrokeColor:     chart.ColorRed,
+			StrokeColor:     style.Colors.Invalid,
 			StrokeDashArray: []float64{5.0, 5.0},
 		},
 		InnerSeries: invalidSeries,
@@ -148,14 +137,11 @@ func (b *BoltDB) GenerateBeaconsGraph(address string, results []Challenges, sett
 		invalidSeries,
 		invalidSma,
 	}
-	x_range := chart.ContinuousRange{
-		Min: x_data[0],
-		Max: x_data[len(x_data)-1],
-	}
+	x_range := NewTimeAxisRange(time.Second, x_data[0], x_data[len(x_data)-1])
 	graph := chart.Chart{
 		Title:  fmt.Sprintf("Beacon Totals for %s", hotspotName),
-		Height: HEIGHT,
-		Width:  WIDTH,
+		Height: style.Height,
+		Width:  style.Width,
 		Series: series,
 		Background: chart.Style{
 			Padding: chart.Box{
@@ -167,7 +153,7 @@ func (b *BoltDB) GenerateBeaconsGraph(address string, results []Challenges, sett
 		},
 		XAxis: chart.XAxis{
 			ValueFormatter: XValueFormatterUnix,
-			Range:          &x_range,
+			Range:          x_range,
 		},
 		YAxis: chart.YAxis{
 			Name: "total witnesses",
@@ -177,24 +163,17 @@ func (b *BoltDB) GenerateBeaconsGraph(address string, results []Challenges, sett
 	graph.Elements = []chart.Renderable{
 		chart.LegendThin(&graph),
 	}
-	f, err := os.Create(filename)
-	if err != nil {
-		log.WithError(err).Fatalf("Unable to crate %s", filename)
-	}
-	defer f.Close()
-	graph.Render(chart.PNG, f)
-	log.Infof("Created %s", filename)
-	return nil
+	return settings.SaveGraph(artifact, graph)
 }
 
-// Creates the PNG for the the witnesses
+// Creates the graph for the the witnesses
 func (b *BoltDB) GenerateWitnessesGraph(address string, results []Challenges, settings GraphSettings) error {
+	style := styleFor("witness-distance")
 	hotspotName, err := b.GetHotspotName(address)
 	if err != nil {
 		return err
 	}
-	filename := fmt.Sprintf("%s/witness-distance.png", hotspotName)
-	jsonFilename := fmt.Sprintf("%s/witness-distance.json", hotspotName)
+	artifact := Artifact{Hotspot: hotspotName, Address: address, Graph: "witness-distance"}
 	host, err := b.GetHotspot(address)
 	if err != nil {
 		return err
@@ -234,11 +213,8 @@ func (b *BoltDB) GenerateWitnessesGraph(address string, results []Challenges, se
 	}
 
 	if settings.Json {
-		jdata, err := json.MarshalIndent(matchChallenges, "", "  ")
-		if err != nil {
-			log.WithError(err).Errorf("Unable to generate %s", jsonFilename)
-		} else {
-			ioutil.WriteFile(jsonFilename, jdata, 0644)
+		if err = settings.SaveJson(artifact, matchChallenges); err != nil {
+			log.WithError(err).Errorf("Unable to generate %s JSON", artifact.key())
 		}
 	}
 
@@ -251,8 +227,8 @@ func (b *BoltDB) GenerateWitnessesGraph(address string, results []Challenges, se
 		Style: chart.Style{
 			StrokeWidth: chart.Disabled,
 			DotWidth:    2,
-			StrokeColor: chart.ColorGreen,
-			DotColor:    chart.ColorGreen,
+			StrokeColor: style.Colors.Valid,
+			DotColor:    style.Colors.Valid,
 		},
 		XValues: x_valid,
 		YValues: valid_data,
@@ -263,8 +239,8 @@ func (b *BoltDB) GenerateWitnessesGraph(address string, results []Challenges, se
 		Style: chart.Style{
 			StrokeWidth: chart.Disabled,
 			DotWidth:    2,
-			DotColor:    chart.ColorRed,
-			StrokeColor: chart.ColorRed,
+			DotColor:    style.Colors.Invalid,
+			StrokeColor: style.Colors.Invalid,
 		},
 		XValues: x_invalid,
 		YValues: invalid_data,
@@ -276,8 +252,8 @@ func (b *BoltDB) GenerateWitnessesGraph(address string, results []Challenges, se
 	}
 	graph := chart.Chart{
 		Title:  fmt.Sprintf("Witness Result for %s", hotspotName),
-		Height: HEIGHT,
-		Width:  WIDTH,
+		Height: style.Height,
+		Width:  style.Width,
 		Series: series,
 		Background: chart.Style{
 			Padding: chart.Box{
@@ -289,6 +265,7 @@ func (b *BoltDB) GenerateWitnessesGraph(address string, results []Challenges, se
 		},
 		XAxis: chart.XAxis{
 			ValueFormatter: XValueFormatterUnix,
+			Range:          NewTimeAxisRange(time.Second, 0, 0),
 		},
 		YAxis: chart.YAxis{
 			Name: "km",
@@ -298,12 +275,5 @@ func (b *BoltDB) GenerateWitnessesGraph(address string, results []Challenges, se
 	graph.Elements = []chart.Renderable{
 		chart.LegendThin(&graph),
 	}
-	f, err := os.Create(filename)
-	if err != nil {
-		log.WithError(err).Fatalf("Unable to crate %s", filename)
-	}
-	defer f.Close()
-	graph.Render(chart.PNG, f)
-	log.Infof("Created %s", filename)
-	return nil
+	return settings.SaveGraph(artifact, graph)
 }
@@ -0,0 +1,174 @@
+package analysis
+
+/*
+ * Helium Analysis
+ * Copyright (c) 2021-2022 Aaron Turner  <aturner at synfin dot net>
+ *
+ * This program is free software: you can redistribute it
+ * and/or modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or with the authors permission any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+)
+
+/*
+ * Minimal pure Go support for H3 cell indexes so we don't need cgo (which
+ * breaks cross compiling).  We can decode an index & find its parents, but
+ * not convert between lat/lng and cells.  Since Helium gives us the lat/lng
+ * of every hotspot, adjacency is approximated using the distance between
+ * the hotspots and the average hex edge length at that resolution.
+ *
+ * Index layout (high to low bits):
+ *   1 reserved, 4 mode, 3 reserved, 4 resolution, 7 base cell,
+ *   15 x 3 bit digits (one per resolution, unused digits are 7)
+ */
+
+type H3Index uint64
+
+const (
+	H3_MODE_CELL    = 1
+	H3_MAX_RES      = 15
+	H3_BASE_CELLS   = 122
+	H3_MODE_OFFSET  = 59
+	H3_RES_OFFSET   = 52
+	H3_BC_OFFSET    = 45
+	H3_DIGIT_BITS   = 3
+	H3_DIGIT_MASK   = 7
+	H3_INVALID_HEX  = H3Index(0)
+	H3_ADJACENT_MAX = 2.0 // max distance in edge lengths to consider hexes adjacent
+)
+
+// average hex edge length in km for each resolution
+var H3_EDGE_LENGTH_KM []float64 = []float64{
+	1107.712591, 418.6760055, 158.2446558, 59.81085794,
+	22.6063794, 8.544408276, 3.229482772, 1.220629759,
+	0.461354684, 0.174375668, 0.065907807, 0.024910561,
+	0.009415526, 0.003559893, 0.001348575, 0.000509713,
+}
+
+// Parses & validates the hex string version of an H3 cell index
+func ParseH3(location string) (H3Index, error) {
+	v, err := strconv.ParseUint(location, 16, 64)
+	if err != nil {
+		return H3_INVALID_HEX, fmt.Errorf("Invalid H3 index '%s': %s", location, err)
+	}
+	h := H3Index(v)
+	if h.Mode() != H3_MODE_CELL {
+		return H3_INVALID_HEX, fmt.Errorf("Invalid H3 index '%s': mode %d is not a cell", location, h.Mode())
+	}
+	if h.BaseCell() >= H3_BASE_CELLS {
+		return H3_INVALID_HEX, fmt.Errorf("Invalid H3 index '%s': base cell %d", location, h.BaseCell())
+	}
+	for r := 1; r <= H3_MAX_RES; r++ {
+		d := h.Digit(r)
+		if r <= h.Resolution() && d == H3_DIGIT_MASK {
+			return H3_INVALID_HEX, fmt.Errorf("Invalid H3 index '%s': digit %d is unused", location, r)
+		} else if r > h.Resolution() && d != H3_DIGIT_MASK {
+			return H3_INVALID_HEX, fmt.Errorf("Invalid H3 index '%s': digit %d should be unused", location, r)
+		}
+	}
+	return h, nil
+}
+
+func (h H3Index) Mode() int {
+	return int(h>>H3_MODE_OFFSET) & 0xf
+}
+
+func (h H3Index) Resolution() int {
+	return int(h>>H3_RES_OFFSET) & 0xf
+}
+
+func (h H3Index) BaseCell() int {
+	return int(h>>H3_BC_OFFSET) & 0x7f
+}
+
+// returns the digit (0-6) for the given resolution (1-15)
+func (h H3Index) Digit(res int) int {
+	return int(h>>uint((H3_MAX_RES-res)*H3_DIGIT_BITS)) & H3_DIGIT_MASK
+}
+
+// returns the digits for resolutions 1 through our resolution
+func (h H3Index) Digits() []int {
+	digits := []int{}
+	for r := 1; r <= h.Resolution(); r++ {
+		digits = append(digits, h.Digit(r))
+	}
+	return digits
+}
+
+// returns the parent hex at the given resolution
+func (h H3Index) Parent(res int) (H3Index, error) {
+	if res < 0 || res > h.Resolution() {
+		return H3_INVALID_HEX, fmt.Errorf("Invalid parent resolution %d for resolution %d hex", res, h.Resolution())
+	}
+	p := h &^ (H3Index(0xf) << H3_RES_OFFSET)
+	p |= H3Index(res) << H3_RES_OFFSET
+	for r := res + 1; r <= H3_MAX_RES; r++ {
+		p |= H3Index(H3_DIGIT_MASK) << uint((H3_MAX_RES-r)*H3_DIGIT_BITS)
+	}
+	return p, nil
+}
+
+func (h H3Index) String() string {
+	return fmt.Sprintf("%x", uint64(h))
+}
+
+// returns the average edge length in km for the resolution
+func H3EdgeLengthKm(res int) float64 {
+	if res < 0 || res > H3_MAX_RES {
+		return 0.0
+	}
+	return H3_EDGE_LENGTH_KM[res]
+}
+
+// returns the parent hex of the location at the given resolution
+func hexParent(location string, res int) (H3Index, error) {
+	h, err := ParseH3(location)
+	if err != nil {
+		return H3_INVALID_HEX, err
+	}
+	return h.Parent(res)
+}
+
+// returns true if both locations are in the same hex at the given resolution
+func SameHex(a, b string, res int) (bool, error) {
+	pa, err := hexParent(a, res)
+	if err != nil {
+		return false, err
+	}
+	pb, err := hexParent(b, res)
+	if err != nil {
+		return false, err
+	}
+	return pa == pb, nil
+}
+
+// returns true if the hotspots are in different, but probably adjacent hexes
+// at the given resolution.  This is an approximation based on distance.
+func adjacentHex(a, b Hotspot, res int) bool {
+	same, err := SameHex(a.Location, b.Location, res)
+	if err != nil || same {
+		return false
+	}
+	km, _, _ := getDistance(a, b)
+	return adjacentDistance(km, res)
+}
+
+// returns true if hexes at the given resolution whose centers are km apart
+// are probably adjacent
+func adjacentDistance(km float64, res int) bool {
+	return km <= H3_ADJACENT_MAX*math.Sqrt(3.0)*H3EdgeLengthKm(res)
+}
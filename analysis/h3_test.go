@@ -0,0 +1,122 @@
+package analysis
+
+/*
+ * Helium Analysis
+ * Copyright (c) 2021-2022 Aaron Turner  <aturner at synfin dot net>
+ *
+ * This program is free software: you can redistribute it
+ * and/or modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or with the authors permission any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+import (
+	"testing"
+)
+
+func TestParseH3(t *testing.T) {
+	tests := []struct {
+		location   string
+		resolution int
+		baseCell   int
+		digits     []int
+	}{
+		{"8928308280fffff", 9, 20, []int{0, 6, 0, 4, 0, 5, 0, 0, 3}},
+		{"85283473fffffff", 5, 20, []int{0, 6, 4, 3, 4}},
+		{"8029fffffffffff", 0, 20, []int{}},
+		{"80f3fffffffffff", 0, 121, []int{}},
+		{"8c2830828001dff", 12, 20, []int{0, 6, 0, 4, 0, 5, 0, 0, 0, 0, 1, 6}},
+	}
+	for _, test := range tests {
+		h, err := ParseH3(test.location)
+		if err != nil {
+			t.Errorf("ParseH3(%s): %s", test.location, err)
+			continue
+		}
+		if h.Resolution() != test.resolution || h.BaseCell() != test.baseCell {
+			t.Errorf("ParseH3(%s) = resolution %d, base cell %d, expected %d, %d",
+				test.location, h.Resolution(), h.BaseCell(), test.resolution, test.baseCell)
+		}
+		digits := h.Digits()
+		if len(digits) != len(test.digits) {
+			t.Errorf("%s: Digits() = %v, expected %v", test.location, digits, test.digits)
+			continue
+		}
+		for i := range digits {
+			if digits[i] != test.digits[i] {
+				t.Errorf("%s: Digits() = %v, expected %v", test.location, digits, test.digits)
+				break
+			}
+		}
+		if h.String() != test.location {
+			t.Errorf("%s: String() = %s", test.location, h.String())
+		}
+	}
+
+	invalid := []string{
+		"",
+		"not-a-hex",
+		"0",
+		"1128308280fffff", // mode 2 is a directed edge
+		"80f5fffffffffff", // base cell 122
+		"892830828ffffff", // resolution 9 with an unused 9th digit
+		"8828308280fffff", // resolution 8 with a 9th digit
+	}
+	for _, location := range invalid {
+		if _, err := ParseH3(location); err == nil {
+			t.Errorf("Expected ParseH3(%s) to fail", location)
+		}
+	}
+}
+
+func TestH3Parent(t *testing.T) {
+	h, err := ParseH3("8928308280fffff")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		"8029fffffffffff",
+		"81283ffffffffff",
+		"822837fffffffff",
+		"832830fffffffff",
+		"8428309ffffffff",
+		"85283083fffffff",
+		"86283082fffffff",
+		"872830828ffffff",
+		"8828308281fffff",
+		"8928308280fffff",
+	}
+	for res, e := range expected {
+		p, err := h.Parent(res)
+		if err != nil {
+			t.Errorf("Parent(%d): %s", res, err)
+		} else if p.String() != e {
+			t.Errorf("Parent(%d) = %s, expected %s", res, p, e)
+		} else if _, err := ParseH3(p.String()); err != nil {
+			t.Errorf("Parent(%d) is not a valid hex: %s", res, err)
+		}
+	}
+
+	for _, res := range []int{-1, 10, 15} {
+		if _, err := h.Parent(res); err == nil {
+			t.Errorf("Expected Parent(%d) of a resolution 9 hex to fail", res)
+		}
+	}
+
+	same, err := SameHex("8928308280fffff", "89283082807ffff", 8)
+	if err != nil || !same {
+		t.Errorf("Expected siblings to be in the same resolution 8 hex")
+	}
+	same, err = SameHex("8928308280fffff", "89283082807ffff", 9)
+	if err != nil || same {
+		t.Errorf("Expected siblings to be in different resolution 9 hexes")
+	}
+}
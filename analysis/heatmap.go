@@ -0,0 +1,163 @@
+package analysis
+
+/*
+ * Helium Analysis
+ * Copyright (c) 2021-2022 Aaron Turner  <aturner at synfin dot net>
+ *
+ * This program is free software: you can redistribute it
+ * and/or modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or with the authors permission any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+import (
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/wcharczuk/go-chart/v2"
+	"github.com/wcharczuk/go-chart/v2/drawing"
+)
+
+const (
+	HEATMAP_CELL_MIN = 64  // min pixels per cell
+	HEATMAP_LABELS   = 160 // pixels reserved for the row & column labels
+)
+
+// go-chart doesn't have a heatmap so we draw our own using the go-chart
+// Renderer so it works for both PNG & SVG.
+type Heatmap struct {
+	Title      string
+	Width      int
+	Height     int
+	XLabels    []string    // one per column
+	YLabels    []string    // one per row
+	Values     [][]float64 // [row][column], NaN cells are left blank
+	Min        float64     // value drawn as white
+	Max        float64     // value drawn as Color
+	CellLabels [][]string  // optional text for each cell
+	Color      drawing.Color
+}
+
+// returns how far the value is between Min & Max from 0.0 to 1.0
+func (hm Heatmap) fraction(v float64) float64 {
+	if hm.Max <= hm.Min {
+		return 0.0
+	}
+	return math.Max(0.0, math.Min((v-hm.Min)/(hm.Max-hm.Min), 1.0))
+}
+
+// returns the color for the value by blending from white to hm.Color
+func (hm Heatmap) cellColor(v float64) drawing.Color {
+	frac := hm.fraction(v)
+	blend := func(a, b uint8) uint8 {
+		return uint8(float64(a) + (float64(b)-float64(a))*frac)
+	}
+	white := chart.ColorWhite
+	return drawing.Color{
+		R: blend(white.R, hm.Color.R),
+		G: blend(white.G, hm.Color.G),
+		B: blend(white.B, hm.Color.B),
+		A: 255,
+	}
+}
+
+// fills the given rectangle with the current fill & stroke colors
+func fillBox(r chart.Renderer, x1, y1, x2, y2 int) {
+	r.MoveTo(x1, y1)
+	r.LineTo(x2, y1)
+	r.LineTo(x2, y2)
+	r.LineTo(x1, y2)
+	r.Close()
+	r.FillStroke()
+}
+
+// Render the heatmap using the given RendererProvider (chart.PNG or chart.SVG)
+func (hm Heatmap) Render(rp chart.RendererProvider, w io.Writer) error {
+	rows := len(hm.YLabels)
+	cols := len(hm.XLabels)
+	if rows == 0 || cols == 0 || len(hm.Values) != rows {
+		return fmt.Errorf("Invalid heatmap: %d row labels, %d column labels and %d rows",
+			rows, cols, len(hm.Values))
+	}
+
+	r, err := rp(hm.Width, hm.Height)
+	if err != nil {
+		return err
+	}
+	font, err := chart.GetDefaultFont()
+	if err != nil {
+		return err
+	}
+	r.SetFont(font)
+
+	// background
+	r.SetFillColor(chart.ColorWhite)
+	r.SetStrokeColor(chart.ColorWhite)
+	fillBox(r, 0, 0, hm.Width, hm.Height)
+
+	// title
+	r.SetFontColor(chart.ColorBlack)
+	r.SetFontSize(16.0)
+	tb := r.MeasureText(hm.Title)
+	r.Text(hm.Title, (hm.Width-tb.Width())/2, 10+tb.Height())
+
+	top := tb.Height() + 20 + HEATMAP_LABELS
+	left := HEATMAP_LABELS
+	cellW := (hm.Width - left - 20) / cols
+	cellH := (hm.Height - top - 20) / rows
+
+	// cells
+	r.SetStrokeWidth(1.0)
+	r.SetFontSize(9.0)
+	for y, row := range hm.Values {
+		for x := 0; x < cols && x < len(row); x++ {
+			x1 := left + x*cellW
+			y1 := top + y*cellH
+			if math.IsNaN(row[x]) {
+				r.SetFillColor(chart.ColorWhite)
+			} else {
+				r.SetFillColor(hm.cellColor(row[x]))
+			}
+			r.SetStrokeColor(chart.ColorAlternateGray)
+			fillBox(r, x1, y1, x1+cellW, y1+cellH)
+
+			if y < len(hm.CellLabels) && x < len(hm.CellLabels[y]) && hm.CellLabels[y][x] != "" {
+				label := hm.CellLabels[y][x]
+				lb := r.MeasureText(label)
+				if !math.IsNaN(row[x]) && hm.fraction(row[x]) > 0.5 {
+					r.SetFontColor(chart.ColorWhite)
+				} else {
+					r.SetFontColor(chart.ColorBlack)
+				}
+				r.Text(label, x1+(cellW-lb.Width())/2, y1+(cellH+lb.Height())/2)
+			}
+		}
+	}
+
+	// row labels
+	r.SetFontColor(chart.ColorBlack)
+	r.SetFontSize(10.0)
+	for y, label := range hm.YLabels {
+		lb := r.MeasureText(label)
+		r.Text(label, left-lb.Width()-5, top+y*cellH+(cellH+lb.Height())/2)
+	}
+
+	// column labels are rotated so long names fit
+	for x, label := range hm.XLabels {
+		lb := r.MeasureText(label)
+		r.SetTextRotation(-math.Pi / 2.0)
+		r.Text(label, left+x*cellW+(cellW+lb.Height())/2, top-5)
+		r.ClearTextRotation()
+	}
+
+	return r.Save(w)
+}
@@ -22,6 +22,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"net/url"
+	"strings"
 	"time"
 
 	"github.com/go-resty/resty/v2"
@@ -29,19 +31,27 @@ import (
 )
 
 const (
-	/*
-		HOTSPOT_URL    = "https://helium-api.stakejoy.com/v1/hotspots/%s"
-		HOTSPOTS_URL   = "https://helium-api.stakejoy.com/v1/hotspots"
-		HEIGHT_URL     = "https://helium-api.stakejoy.com/v1/blocks/height"
-		CHALLENGE_URL  = "https://helium-api.stakejoy.com/v1/hotspots/%s/challenges"
-	*/
-	HOTSPOT_URL    = "https://api.helium.io/v1/hotspots/%s"
-	HOTSPOTS_URL   = "https://api.helium.io/v1/hotspots"
-	HEIGHT_URL     = "https://api.helium.io/v1/blocks/height"
-	CHALLENGE_URL  = "https://api.helium.io/v1/hotspots/%s/challenges"
-	RETRY_ATTEMPTS = 10
+	DEFAULT_API_URL = "https://api.helium.io/v1" // or another API server like https://helium-api.stakejoy.com/v1
+	HOTSPOT_PATH    = "/hotspots/%s"
+	HOTSPOTS_PATH   = "/hotspots"
+	HEIGHT_PATH     = "/blocks/height"
+	BLOCK_PATH      = "/blocks/%d"
+	CHALLENGE_PATH  = "/hotspots/%s/challenges"
+	RETRY_ATTEMPTS  = 10
 )
 
+var apiUrl string = DEFAULT_API_URL
+
+// Set the base URL of the Helium API server
+func SetApiUrl(baseUrl string) error {
+	u, err := url.Parse(baseUrl)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("Invalid API URL: %s", baseUrl)
+	}
+	apiUrl = strings.TrimSuffix(baseUrl, "/")
+	return nil
+}
+
 type HotspotResponse struct {
 	Data Hotspot `json:"data"`
 }
@@ -55,6 +65,13 @@ type HeightResponse struct {
 	Data map[string]int64 `json:"data"`
 }
 
+type BlockResponse struct {
+	Data struct {
+		Height int64 `json:"height"`
+		Time   int64 `json:"time"`
+	} `json:"data"`
+}
+
 type TooBusyError struct {
 	Error    string        `json:"error"`
 	ComeBack time.Duration `json:"come_back_in_ms"`
@@ -95,7 +112,7 @@ func GetCurrentHeight() (int64, error) {
 	resp, err = client.R().
 		SetHeader("Accept", "application/json").
 		SetResult(&HeightResponse{}).
-		Get(HEIGHT_URL)
+		Get(apiUrl + HEIGHT_PATH)
 
 	if err != nil {
 		return 0, err
@@ -110,6 +127,27 @@ func GetCurrentHeight() (int64, error) {
 	return 0, fmt.Errorf("Missing height in API reponse")
 }
 
+// Gets the time of the given block height
+func FetchBlockTime(height int64) (time.Time, error) {
+	client := NewRestyClient()
+	resp, err := client.R().
+		SetHeader("Accept", "application/json").
+		SetResult(&BlockResponse{}).
+		Get(apiUrl + fmt.Sprintf(BLOCK_PATH, height))
+
+	if err != nil {
+		return time.Unix(0, 0), err
+	}
+	if resp.IsError() {
+		return time.Unix(0, 0), fmt.Errorf("Error %d: %s", resp.StatusCode(), resp.String())
+	}
+	result := (resp.Result().(*BlockResponse))
+	if result.Data.Time == 0 {
+		return time.Unix(0, 0), fmt.Errorf("Missing time for block %d in API response", height)
+	}
+	return time.Unix(result.Data.Time, 0).UTC(), nil
+}
+
 // Download hotspot data from helium.api servers
 func FetchHotspots() ([]Hotspot, error) {
 	hotspots := []Hotspot{}
@@ -225,7 +263,7 @@ func getHotspotResponse(client *resty.Client, cursor string) ([]Hotspot, string,
 		resp, err = client.R().
 			SetHeader("Accept", "application/json").
 			SetResult(&HotspotsResponse{}).
-			Get(HOTSPOTS_URL)
+			Get(apiUrl + HOTSPOTS_PATH)
 	} else {
 		log.Debugf("Using Hotspot Helium API Cursor: %s", cursor)
 		resp, err = client.R().
@@ -234,7 +272,7 @@ func getHotspotResponse(client *resty.Client, cursor string) ([]Hotspot, string,
 			SetQueryParams(map[string]string{
 				"cursor": cursor,
 			}).
-			Get(HOTSPOTS_URL)
+			Get(apiUrl + HOTSPOTS_PATH)
 	}
 	if err != nil {
 		return []Hotspot{}, "", err
@@ -258,7 +296,7 @@ func getChallengeResponse(client *resty.Client, address string, cursor string) (
 		resp, err = client.R().
 			SetHeader("Accept", "application/json").
 			SetResult(&ChallengeResponse{}).
-			Get(fmt.Sprintf(CHALLENGE_URL, address))
+			Get(apiUrl + fmt.Sprintf(CHALLENGE_PATH, address))
 	} else {
 		log.Debugf("Using Challenge Helium API Cursor: %s", cursor)
 		resp, err = client.R().
@@ -267,7 +305,7 @@ func getChallengeResponse(client *resty.Client, address string, cursor string) (
 			SetQueryParams(map[string]string{
 				"cursor": cursor,
 			}).
-			Get(fmt.Sprintf(CHALLENGE_URL, address))
+			Get(apiUrl + fmt.Sprintf(CHALLENGE_PATH, address))
 	}
 	if err != nil {
 		return []Challenges{}, "", err
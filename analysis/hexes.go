@@ -0,0 +1,191 @@
+package analysis
+
+/*
+ * Helium Analysis
+ * Copyright (c) 2021-2022 Aaron Turner  <aturner at synfin dot net>
+ *
+ * This program is free software: you can redistribute it
+ * and/or modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or with the authors permission any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+import (
+	"fmt"
+	"sort"
+)
+
+// HIP17 hex density chain variables for each resolution
+type Hip17Params struct {
+	Resolution int `json:"resolution"`
+	Neighbors  int `json:"neighbors"` // occupied neighbors before the density target applies
+	Target     int `json:"target"`    // target number of hotspots per hex
+	Max        int `json:"max"`       // max number of hotspots per hex
+}
+
+var HIP17_PARAMS []Hip17Params = []Hip17Params{
+	{4, 1, 250, 800},
+	{5, 1, 100, 400},
+	{6, 1, 25, 100},
+	{7, 2, 5, 20},
+	{8, 2, 1, 4},
+	{9, 2, 1, 2},
+	{10, 2, 1, 1},
+}
+
+const (
+	HEX_SAME     = "same"
+	HEX_ADJACENT = "adjacent"
+)
+
+// Number of hotspots in & around our hex at a single resolution
+type HexDensity struct {
+	Resolution int     `json:"resolution"`
+	Hex        string  `json:"hex"`
+	Hotspots   int     `json:"hotspots"` // in our hex, including us
+	Adjacent   int     `json:"adjacent"` // in the approximately adjacent hexes
+	Target     int     `json:"target"`
+	Max        int     `json:"max"`
+	Saturation float64 `json:"saturation"` // Hotspots / Target
+	Saturated  bool    `json:"saturated"`  // Hotspots > Max
+}
+
+// A peer in the same or an adjacent hex
+type HexPeer struct {
+	Address  string  `json:"address"`
+	Name     string  `json:"name"`
+	Km       float64 `json:"km"`
+	Relation string  `json:"relation"` // same or adjacent
+	Valid    int     `json:"valid"`
+	Invalid  int     `json:"invalid"`
+}
+
+type HexReport struct {
+	Address    string       `json:"address"`
+	Location   string       `json:"location"`
+	Resolution int          `json:"resolution"` // used for Peers
+	Density    []HexDensity `json:"density"`
+	Peers      []HexPeer    `json:"peers"`
+}
+
+// Calculates the hex density around the hotspot for each of the HIP17
+// resolutions and finds the peers in the same or adjacent hex at the given
+// resolution.  Requires the hotspot cache to be loaded for the whole network.
+func (b *BoltDB) GetHexReport(address string, challenges []Challenges, res int) (HexReport, error) {
+	report := HexReport{
+		Address:    address,
+		Resolution: res,
+		Density:    []HexDensity{},
+		Peers:      []HexPeer{},
+	}
+	if res < 0 || res > H3_MAX_RES {
+		return report, fmt.Errorf("Invalid H3 resolution: %d", res)
+	}
+
+	aHost, err := b.GetHotspot(address)
+	if err != nil {
+		return report, err
+	}
+	report.Location = aHost.Location
+	hex, err := ParseH3(aHost.Location)
+	if err != nil {
+		return report, err
+	}
+	if res > hex.Resolution() {
+		return report, fmt.Errorf("Resolution %d is finer than the asserted resolution %d", res, hex.Resolution())
+	}
+
+	hotspots, err := b.GetHotspots()
+	if err != nil {
+		return report, err
+	}
+
+	// parse every location once and bucket the hotspots by their parent hex
+	// at each resolution
+	parents := make([]H3Index, len(HIP17_PARAMS))
+	buckets := make([]map[H3Index]int, len(HIP17_PARAMS))
+	adjacent := make([]int, len(HIP17_PARAMS))
+	for i, p := range HIP17_PARAMS {
+		parents[i], _ = hex.Parent(p.Resolution)
+		buckets[i] = map[H3Index]int{}
+	}
+	for _, h := range hotspots {
+		hHex, err := ParseH3(h.Location)
+		if err != nil {
+			continue
+		}
+		km, _, _ := getDistance(aHost, h)
+		for i, p := range HIP17_PARAMS {
+			parent, err := hHex.Parent(p.Resolution)
+			if err != nil {
+				continue
+			}
+			buckets[i][parent] += 1
+			if parent != parents[i] && adjacentDistance(km, p.Resolution) {
+				adjacent[i] += 1
+			}
+		}
+	}
+
+	for i, p := range HIP17_PARAMS {
+		density := HexDensity{
+			Resolution: p.Resolution,
+			Hex:        parents[i].String(),
+			Hotspots:   buckets[i][parents[i]],
+			Adjacent:   adjacent[i],
+			Target:     p.Target,
+			Max:        p.Max,
+		}
+		density.Saturation = float64(density.Hotspots) / float64(p.Target)
+		density.Saturated = density.Hotspots > p.Max
+		report.Density = append(report.Density, density)
+	}
+
+	peers, results, err := b.getAllWitnessResults(address, challenges)
+	if err != nil {
+		return report, err
+	}
+	for _, peer := range peers {
+		pHost, err := b.GetHotspot(peer)
+		if err != nil {
+			return report, err
+		}
+		relation := ""
+		if same, err := SameHex(aHost.Location, pHost.Location, res); err != nil {
+			continue
+		} else if same {
+			relation = HEX_SAME
+		} else if adjacentHex(aHost, pHost, res) {
+			relation = HEX_ADJACENT
+		} else {
+			continue
+		}
+
+		hp := HexPeer{
+			Address:  peer,
+			Name:     pHost.Name,
+			Km:       results[peer][0].Km,
+			Relation: relation,
+		}
+		for _, r := range results[peer] {
+			if r.Valid {
+				hp.Valid += 1
+			} else {
+				hp.Invalid += 1
+			}
+		}
+		report.Peers = append(report.Peers, hp)
+	}
+	sort.SliceStable(report.Peers, func(i, j int) bool {
+		return report.Peers[i].Km < report.Peers[j].Km
+	})
+	return report, nil
+}
@@ -44,6 +44,11 @@ type Hotspot struct {
 	Status      *StatusType  `json:"status"`
 }
 
+// returns true if the hotspot has an asserted location
+func hasLocation(h Hotspot) bool {
+	return h.Location != "" && !(h.Lat == 0.0 && h.Lng == 0.0)
+}
+
 type StatusType struct {
 	Height int64  `json:"height"`
 	Online string `json:"online"`
@@ -71,7 +76,7 @@ func GetHotspot(address string) (Hotspot, error) {
 	resp, err := client.R().
 		SetHeader("Accept", "application/json").
 		SetResult(&HotspotResponse{}).
-		Get(fmt.Sprintf(HOTSPOT_URL, address))
+		Get(apiUrl + fmt.Sprintf(HOTSPOT_PATH, address))
 
 	if err != nil {
 		return Hotspot{}, err
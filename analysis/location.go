@@ -0,0 +1,377 @@
+package analysis
+
+/*
+ * Helium Analysis
+ * Copyright (c) 2021-2022 Aaron Turner  <aturner at synfin dot net>
+ *
+ * This program is free software: you can redistribute it
+ * and/or modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or with the authors permission any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	log "github.com/sirupsen/logrus"
+)
+
+/*
+ * Estimates where a hotspot really is by multilateration.  The median RSSI of
+ * every link to a hotspot with a known location is turned into a distance
+ * using a path loss model and then we solve for the position which best fits
+ * all those distances with Gauss-Newton on a local flat plane.  The model is
+ * fit only to the links between other hotspots so a bad asserted location
+ * can't hide its own error.
+ */
+
+const (
+	LOCATION_MIN_ANCHORS = 3
+	LOCATION_MAX_ITER    = 50
+	LOCATION_CONVERGED   = 0.001 // km
+	LOCATION_MAX_STEP    = 1.0   // km
+	EARTH_RADIUS_KM      = 6371.0
+)
+
+type LocationCheck struct {
+	Address      string  `json:"address"`
+	Name         string  `json:"name"`
+	AssertedLat  float64 `json:"asserted_lat"`
+	AssertedLng  float64 `json:"asserted_lng"`
+	EstimatedLat float64 `json:"estimated_lat"`
+	EstimatedLng float64 `json:"estimated_lng"`
+	Anchors      int     `json:"anchors"`  // number of hotspots used to estimate the location
+	ErrorKm      float64 `json:"error_km"` // distance between asserted & estimated location
+	RmsKm        float64 `json:"rms_km"`   // RMS error of the fitted distances
+	Flagged      bool    `json:"flagged"`
+}
+
+// a hotspot with a known location and the estimated distance to it
+type locationAnchor struct {
+	Lat float64
+	Lng float64
+	Km  float64
+}
+
+// a witnessed beacon between two hotspots with known locations
+type hotspotLink struct {
+	Beaconer string
+	Witness  string
+	Result   WitnessResult
+}
+
+// converts lat/lng into km east/north of the reference point
+func toPlane(lat0, lng0, lat, lng float64) (float64, float64) {
+	x := (lng - lng0) * math.Pi / 180.0 * EARTH_RADIUS_KM * math.Cos(lat0*math.Pi/180.0)
+	y := (lat - lat0) * math.Pi / 180.0 * EARTH_RADIUS_KM
+	return x, y
+}
+
+// converts km east/north of the reference point back into lat/lng
+func fromPlane(lat0, lng0, x, y float64) (float64, float64) {
+	lat := lat0 + y/EARTH_RADIUS_KM*180.0/math.Pi
+	lng := lng0 + x/(EARTH_RADIUS_KM*math.Cos(lat0*math.Pi/180.0))*180.0/math.Pi
+	return lat, lng
+}
+
+// Solve for the lat/lng which best fits the distances to the anchors.  Closer
+// anchors are weighted higher since the RSSI error grows with distance.  The
+// solver starts at the weighted centroid of the anchors.  Returns the lat, lng
+// & RMS error in km.
+func multilaterate(anchors []locationAnchor) (float64, float64, float64, error) {
+	if len(anchors) < LOCATION_MIN_ANCHORS {
+		return 0.0, 0.0, 0.0, fmt.Errorf("Only %d anchors.  Need at least %d", len(anchors), LOCATION_MIN_ANCHORS)
+	}
+
+	lat0 := 0.0
+	lng0 := 0.0
+	for _, a := range anchors {
+		lat0 += a.Lat
+		lng0 += a.Lng
+	}
+	lat0 /= float64(len(anchors))
+	lng0 /= float64(len(anchors))
+
+	xs := make([]float64, len(anchors))
+	ys := make([]float64, len(anchors))
+	ws := make([]float64, len(anchors))
+	cx := 0.0
+	cy := 0.0
+	wsum := 0.0
+	for i, a := range anchors {
+		xs[i], ys[i] = toPlane(lat0, lng0, a.Lat, a.Lng)
+		ws[i] = 1.0 / math.Max(a.Km*a.Km, PATH_LOSS_MIN_KM)
+		cx += xs[i] * ws[i]
+		cy += ys[i] * ws[i]
+		wsum += ws[i]
+	}
+
+	px, py := solveLocation(xs, ys, ws, anchors, cx/wsum, cy/wsum)
+	sq := 0.0
+	for i, a := range anchors {
+		r := math.Hypot(px-xs[i], py-ys[i]) - a.Km
+		sq += r * r
+	}
+	rms := math.Sqrt(sq / float64(len(anchors)))
+
+	lat, lng := fromPlane(lat0, lng0, px, py)
+	return lat, lng, rms, nil
+}
+
+// Gauss-Newton weighted least squares starting at px, py on the local plane
+func solveLocation(xs, ys, ws []float64, anchors []locationAnchor, px, py float64) (float64, float64) {
+	for iter := 0; iter < LOCATION_MAX_ITER; iter++ {
+		// normal equations: (J^T W J) delta = -J^T W r
+		var a11, a12, a22, b1, b2 float64
+		for i, a := range anchors {
+			dx := px - xs[i]
+			dy := py - ys[i]
+			dist := math.Max(math.Hypot(dx, dy), PATH_LOSS_MIN_KM)
+			jx := dx / dist
+			jy := dy / dist
+			r := dist - a.Km
+			a11 += ws[i] * jx * jx
+			a12 += ws[i] * jx * jy
+			a22 += ws[i] * jy * jy
+			b1 -= ws[i] * jx * r
+			b2 -= ws[i] * jy * r
+		}
+		det := a11*a22 - a12*a12
+		if math.Abs(det) < 1e-12 {
+			break
+		}
+		stepX := (a22*b1 - a12*b2) / det
+		stepY := (a11*b2 - a12*b1) / det
+		// poor anchor geometry can send us flying off, so limit the step size
+		if step := math.Hypot(stepX, stepY); step > LOCATION_MAX_STEP {
+			stepX *= LOCATION_MAX_STEP / step
+			stepY *= LOCATION_MAX_STEP / step
+		}
+		px += stepX
+		py += stepY
+		if math.Hypot(stepX, stepY) < LOCATION_CONVERGED {
+			break
+		}
+	}
+	return px, py
+}
+
+// turns the RSSI samples for each anchor address into anchors with a distance
+func (b *BoltDB) makeAnchors(model PathLossModel, signals map[string][]float64) ([]locationAnchor, error) {
+	anchors := []locationAnchor{}
+	for address, rssi := range signals {
+		h, err := b.GetHotspot(address)
+		if err != nil || !hasLocation(h) {
+			continue
+		}
+		km := model.Distance(median(rssi))
+		if math.IsNaN(km) || math.IsInf(km, 0) || km <= 0.0 {
+			log.Debugf("Skipping anchor %s with invalid distance %f", address, km)
+			continue
+		}
+		anchors = append(anchors, locationAnchor{
+			Lat: h.Lat,
+			Lng: h.Lng,
+			Km:  km,
+		})
+	}
+	if len(anchors) < LOCATION_MIN_ANCHORS {
+		return anchors, fmt.Errorf("Only %d anchors.  Need at least %d", len(anchors), LOCATION_MIN_ANCHORS)
+	}
+	return anchors, nil
+}
+
+// returns every valid witness of a beacon between two hotspots with known locations
+func (b *BoltDB) getLinks(challenges []Challenges) []hotspotLink {
+	links := []hotspotLink{}
+	hotspots := map[string]Hotspot{}
+	lookup := func(address string) (Hotspot, bool) {
+		h, ok := hotspots[address]
+		if !ok {
+			h, _ = b.GetHotspot(address)
+			hotspots[address] = h
+		}
+		return h, hasLocation(h)
+	}
+
+	for _, challenge := range challenges {
+		if challenge.Path == nil {
+			continue
+		}
+		for _, path := range *challenge.Path {
+			if path.Witnesses == nil {
+				continue
+			}
+			beaconer, ok := lookup(path.Challengee)
+			if !ok {
+				continue
+			}
+			for _, wit := range *path.Witnesses {
+				if !wit.IsValid || wit.Gateway == path.Challengee {
+					continue
+				}
+				witness, ok := lookup(wit.Gateway)
+				if !ok {
+					continue
+				}
+				km, _, err := getDistance(beaconer, witness)
+				if err != nil {
+					continue
+				}
+				links = append(links, hotspotLink{
+					Beaconer: path.Challengee,
+					Witness:  wit.Gateway,
+					Result: WitnessResult{
+						Timestamp: wit.Timestamp,
+						Signal:    wit.Signal,
+						Valid:     wit.IsValid,
+						Km:        km,
+					},
+				})
+			}
+		}
+	}
+	return links
+}
+
+// fits the path loss model to the links which don't include the given hotspot
+func fitExcluding(links []hotspotLink, address string) (PathLossModel, error) {
+	results := map[string][]WitnessResult{}
+	for _, link := range links {
+		if link.Beaconer == address || link.Witness == address {
+			continue
+		}
+		key := fmt.Sprintf("%s/%s", link.Beaconer, link.Witness)
+		results[key] = append(results[key], link.Result)
+	}
+	return FitPathLoss("all", results)
+}
+
+// Estimate the location of the given hotspot using the anchors
+func (b *BoltDB) checkLocation(address string, anchors []locationAnchor, thresholdKm float64) (LocationCheck, error) {
+	h, err := b.GetHotspot(address)
+	if err != nil {
+		return LocationCheck{}, err
+	}
+	check := LocationCheck{
+		Address:     address,
+		Name:        h.Name,
+		AssertedLat: h.Lat,
+		AssertedLng: h.Lng,
+		Anchors:     len(anchors),
+	}
+	if check.Name == "" {
+		check.Name = address
+	}
+
+	lat, lng, rms, err := multilaterate(anchors)
+	if err != nil {
+		return check, err
+	}
+	check.EstimatedLat = lat
+	check.EstimatedLng = lng
+	check.RmsKm = rms
+	if hasLocation(h) {
+		check.ErrorKm, _, _ = getDistance(h, Hotspot{Lat: lat, Lng: lng})
+		check.Flagged = check.ErrorKm > thresholdKm
+	}
+	return check, nil
+}
+
+// Check the asserted location of the given hotspot and all of its peers.
+// Our hotspot is always first, followed by the peers sorted by error.
+func (b *BoltDB) CheckLocations(address string, challenges []Challenges, thresholdKm float64) ([]LocationCheck, error) {
+	checks := []LocationCheck{}
+
+	links := b.getLinks(challenges)
+	model, err := fitExcluding(links, address)
+	if err != nil {
+		return checks, fmt.Errorf("Unable to fit path loss model without %s: %s", address, err)
+	}
+
+	// our location is estimated from every valid link to a peer
+	peers, results, err := b.getAllWitnessResults(address, challenges)
+	if err != nil {
+		return checks, err
+	}
+	signals := map[string][]float64{}
+	for _, peer := range peers {
+		for _, r := range results[peer] {
+			if r.Valid {
+				signals[peer] = append(signals[peer], float64(r.Signal))
+			}
+		}
+	}
+	anchors, err := b.makeAnchors(model, signals)
+	if err != nil {
+		return checks, fmt.Errorf("Unable to estimate location of %s: %s", address, err)
+	}
+	ours, err := b.checkLocation(address, anchors, thresholdKm)
+	if err != nil {
+		return checks, fmt.Errorf("Unable to estimate location of %s: %s", address, err)
+	}
+	checks = append(checks, ours)
+
+	// peers are estimated from everyone who validly witnessed their beacons
+	// and any of our beacons they validly witnessed
+	peerSignals := map[string]map[string][]float64{}
+	for _, peer := range peers {
+		peerSignals[peer] = map[string][]float64{}
+	}
+	for _, challenge := range challenges {
+		if challenge.Path == nil {
+			continue
+		}
+		for _, path := range *challenge.Path {
+			if path.Witnesses == nil {
+				continue
+			}
+			for _, wit := range *path.Witnesses {
+				if !wit.IsValid {
+					continue
+				}
+				if path.Challengee == address {
+					if ps, ok := peerSignals[wit.Gateway]; ok {
+						ps[address] = append(ps[address], float64(wit.Signal))
+					}
+				} else if ps, ok := peerSignals[path.Challengee]; ok && wit.Gateway != path.Challengee {
+					ps[wit.Gateway] = append(ps[wit.Gateway], float64(wit.Signal))
+				}
+			}
+		}
+	}
+
+	peerChecks := []LocationCheck{}
+	for _, peer := range peers {
+		peerModel, err := fitExcluding(links, peer)
+		if err != nil {
+			log.WithError(err).Debugf("Skipping location check of %s", peer)
+			continue
+		}
+		anchors, err := b.makeAnchors(peerModel, peerSignals[peer])
+		if err != nil {
+			log.WithError(err).Debugf("Skipping location check of %s", peer)
+			continue
+		}
+		check, err := b.checkLocation(peer, anchors, thresholdKm)
+		if err != nil {
+			log.WithError(err).Debugf("Skipping location check of %s", peer)
+			continue
+		}
+		peerChecks = append(peerChecks, check)
+	}
+	sort.SliceStable(peerChecks, func(i, j int) bool {
+		return peerChecks[i].ErrorKm > peerChecks[j].ErrorKm
+	})
+	return append(checks, peerChecks...), nil
+}
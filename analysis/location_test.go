@@ -0,0 +1,61 @@
+package analysis
+
+/*
+ * Helium Analysis
+ * Copyright (c) 2021-2022 Aaron Turner  <aturner at synfin dot net>
+ *
+ * This program is free software: you can redistribute it
+ * and/or modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or with the authors permission any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+import (
+	"testing"
+)
+
+func TestMultilaterate(t *testing.T) {
+	tests := []struct {
+		name    string
+		lat     float64
+		lng     float64
+		offsets [][2]float64 // lat/lng offsets of the anchors
+	}{
+		{"inside", 37.7749, -122.4194, [][2]float64{{0.03, 0.0}, {0.0, 0.04}, {-0.02, -0.03}, {0.01, -0.05}}},
+		{"three anchors", 51.5074, -0.1278, [][2]float64{{0.05, 0.05}, {-0.05, 0.02}, {0.01, -0.06}}},
+		{"off center", -33.8688, 151.2093, [][2]float64{{0.01, -0.01}, {0.06, 0.0}, {0.0, 0.07}, {-0.01, 0.01}}},
+	}
+	for _, test := range tests {
+		truth := Hotspot{Lat: test.lat, Lng: test.lng}
+		anchors := []locationAnchor{}
+		for _, o := range test.offsets {
+			a := Hotspot{Lat: test.lat + o[0], Lng: test.lng + o[1]}
+			km, _, _ := getDistance(truth, a)
+			anchors = append(anchors, locationAnchor{Lat: a.Lat, Lng: a.Lng, Km: km})
+		}
+
+		lat, lng, rms, err := multilaterate(anchors)
+		if err != nil {
+			t.Errorf("%s: %s", test.name, err)
+			continue
+		}
+		errKm, _, _ := getDistance(truth, Hotspot{Lat: lat, Lng: lng})
+		if errKm > 0.05 || rms > 0.05 {
+			t.Errorf("%s: multilaterate() = %f, %f (rms %f), expected %f, %f",
+				test.name, lat, lng, rms, test.lat, test.lng)
+		}
+	}
+
+	_, _, _, err := multilaterate([]locationAnchor{{Lat: 1.0, Lng: 1.0, Km: 1.0}, {Lat: 2.0, Lng: 2.0, Km: 1.0}})
+	if err == nil {
+		t.Errorf("Expected an error with only 2 anchors")
+	}
+}
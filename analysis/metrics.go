@@ -0,0 +1,291 @@
+package analysis
+
+/*
+ * Helium Analysis
+ * Copyright (c) 2021-2022 Aaron Turner  <aturner at synfin dot net>
+ *
+ * This program is free software: you can redistribute it
+ * and/or modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or with the authors permission any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+const (
+	METRIC_GAUGE   = "gauge"
+	METRIC_COUNTER = "counter"
+)
+
+type MetricSample struct {
+	Labels map[string]string
+	Value  float64
+}
+
+// A Prometheus metric with all of its samples
+type MetricFamily struct {
+	Name    string
+	Help    string
+	Type    string
+	Samples []MetricSample
+}
+
+func (m *MetricFamily) add(value float64, labels ...string) {
+	l := map[string]string{}
+	for i := 0; i+1 < len(labels); i += 2 {
+		l[labels[i]] = labels[i+1]
+	}
+	m.Samples = append(m.Samples, MetricSample{Labels: l, Value: value})
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// Write the metrics in the Prometheus text exposition format
+func WritePrometheus(w io.Writer, families []MetricFamily) error {
+	buf := bufio.NewWriter(w)
+	for _, m := range families {
+		fmt.Fprintf(buf, "# HELP %s %s\n", m.Name, m.Help)
+		fmt.Fprintf(buf, "# TYPE %s %s\n", m.Name, m.Type)
+		for _, s := range m.Samples {
+			keys := []string{}
+			for k := range s.Labels {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			labels := []string{}
+			for _, k := range keys {
+				labels = append(labels, fmt.Sprintf(`%s="%s"`, k, labelEscaper.Replace(s.Labels[k])))
+			}
+			name := m.Name
+			if len(labels) > 0 {
+				name = fmt.Sprintf("%s{%s}", name, strings.Join(labels, ","))
+			}
+
+			var value string
+			switch {
+			case math.IsNaN(s.Value):
+				value = "NaN"
+			case math.IsInf(s.Value, 1):
+				value = "+Inf"
+			case math.IsInf(s.Value, -1):
+				value = "-Inf"
+			default:
+				value = strconv.FormatFloat(s.Value, 'g', -1, 64)
+			}
+			fmt.Fprintf(buf, "%s %s\n", name, value)
+		}
+	}
+	return buf.Flush()
+}
+
+// Returns the addresses of every hotspot with challenges in the DB
+func (b *BoltDB) GetChallengeHotspots() ([]string, error) {
+	addresses := []string{}
+	err := b.db.View(func(tx *bolt.Tx) error {
+		cursor := tx.Bucket(CHALLENGES_BUCKET).Cursor()
+		for k, _ := cursor.First(); k != nil; k, _ = cursor.Next() {
+			addresses = append(addresses, string(k))
+		}
+		return nil
+	})
+	return addresses, err
+}
+
+// Calculate the metrics for the given hotspots using the challenges in the
+// window before now
+func (b *BoltDB) GetMetrics(addresses []string, window time.Duration, now time.Time) ([]MetricFamily, error) {
+	beacons := MetricFamily{
+		Name: "helium_hotspot_beacons",
+		Help: fmt.Sprintf("Beacons sent in the last %s", window),
+		Type: METRIC_GAUGE,
+	}
+	witnesses := MetricFamily{
+		Name: "helium_hotspot_witnesses",
+		Help: fmt.Sprintf("Witnesses in the last %s.  direction=tx: peers heard us, rx: we heard peers", window),
+		Type: METRIC_GAUGE,
+	}
+	invalid := MetricFamily{
+		Name: "helium_hotspot_invalid_witnesses",
+		Help: fmt.Sprintf("Invalid witnesses in the last %s", window),
+		Type: METRIC_GAUGE,
+	}
+	lastBeacon := MetricFamily{
+		Name: "helium_hotspot_last_beacon_timestamp_seconds",
+		Help: "Unix time of the last beacon in the database",
+		Type: METRIC_GAUGE,
+	}
+	online := MetricFamily{
+		Name: "helium_hotspot_online",
+		Help: "1 if the hotspot is online",
+		Type: METRIC_GAUGE,
+	}
+	rewardScale := MetricFamily{
+		Name: "helium_hotspot_reward_scale",
+		Help: "Reward scale of the hotspot",
+		Type: METRIC_GAUGE,
+	}
+	challenges := MetricFamily{
+		Name: "helium_hotspot_challenges_stored",
+		Help: "Challenges currently stored in the database",
+		Type: METRIC_GAUGE,
+	}
+	lastHeight := MetricFamily{
+		Name: "helium_hotspot_last_challenge_height",
+		Help: "Block height of the last challenge in the database",
+		Type: METRIC_GAUGE,
+	}
+	peerRssi := MetricFamily{
+		Name: "helium_peer_rssi_mean_dbm",
+		Help: fmt.Sprintf("Mean RSSI of the witnesses with each peer in the last %s", window),
+		Type: METRIC_GAUGE,
+	}
+	peerSnr := MetricFamily{
+		Name: "helium_peer_snr_mean_db",
+		Help: fmt.Sprintf("Mean SNR of the witnesses with each peer in the last %s", window),
+		Type: METRIC_GAUGE,
+	}
+
+	for _, address := range addresses {
+		host, err := b.GetHotspot(address)
+		if err != nil {
+			return []MetricFamily{}, err
+		}
+		name := host.Name
+
+		isOnline := 0.0
+		if host.Status != nil && host.Status.Online == "online" {
+			isOnline = 1.0
+		}
+		online.add(isOnline, "address", address, "name", name)
+		rewardScale.add(host.RewardScale, "address", address, "name", name)
+
+		count, last, height, err := b.challengeStats(address)
+		if err != nil {
+			return []MetricFamily{}, err
+		}
+		challenges.add(float64(count), "address", address, "name", name)
+		lastHeight.add(float64(height), "address", address, "name", name)
+		if last > 0 {
+			lastBeacon.add(float64(last), "address", address, "name", name)
+		}
+
+		recent, err := b.GetChallenges(address, now.Add(-window), now)
+		if err != nil {
+			return []MetricFamily{}, err
+		}
+		beacons.add(float64(len(beaconTimes(address, recent))), "address", address, "name", name)
+
+		peers, results, err := b.getAllWitnessResults(address, recent)
+		if err != nil {
+			return []MetricFamily{}, err
+		}
+		counts := map[RXTX]int{TX: 0, RX: 0}
+		invalids := map[RXTX]int{TX: 0, RX: 0}
+		for _, peer := range peers {
+			for _, wr := range results[peer] {
+				counts[wr.Type] += 1
+				if !wr.Valid {
+					invalids[wr.Type] += 1
+				}
+			}
+		}
+		for _, dir := range []RXTX{TX, RX} {
+			d := "tx"
+			if dir == RX {
+				d = "rx"
+			}
+			witnesses.add(float64(counts[dir]), "address", address, "name", name, "direction", d)
+			invalid.add(float64(invalids[dir]), "address", address, "name", name, "direction", d)
+		}
+
+		stats, err := b.GetPeerStats(address, recent)
+		if err != nil {
+			return []MetricFamily{}, err
+		}
+		for _, s := range stats {
+			peerRssi.add(s.RssiMean, "address", address, "name", name, "peer", s.Address, "peer_name", s.Name)
+			peerSnr.add(s.SnrMean, "address", address, "name", name, "peer", s.Address, "peer_name", s.Name)
+		}
+	}
+
+	dbSize := MetricFamily{
+		Name: "helium_db_size_bytes",
+		Help: "Size of the database",
+		Type: METRIC_GAUGE,
+	}
+	err := b.db.View(func(tx *bolt.Tx) error {
+		dbSize.add(float64(tx.Size()))
+		return nil
+	})
+	if err != nil {
+		return []MetricFamily{}, err
+	}
+
+	cacheHeight := MetricFamily{
+		Name: "helium_hotspots_cache_height",
+		Help: "Block height when the hotspot cache was last refreshed",
+		Type: METRIC_GAUGE,
+	}
+	height, err := b.GetHotspotsCacheHeight()
+	if err != nil {
+		return []MetricFamily{}, err
+	}
+	cacheHeight.add(float64(height))
+
+	return []MetricFamily{
+		beacons, witnesses, invalid, lastBeacon, online, rewardScale, challenges,
+		lastHeight, peerRssi, peerSnr, dbSize, cacheHeight,
+	}, nil
+}
+
+// returns the number of challenges, the unix time of the last beacon and
+// the block height of the last challenge for the hotspot
+func (b *BoltDB) challengeStats(address string) (int, int64, int64, error) {
+	count := 0
+	var lastBeacon int64 = 0
+	var height int64 = 0
+	err := b.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(CHALLENGES_BUCKET).Bucket([]byte(address))
+		if bucket == nil {
+			return nil
+		}
+		count = bucket.Stats().KeyN
+
+		// walk backwards until we find our last beacon
+		cursor := bucket.Cursor()
+		for k, v := cursor.Last(); k != nil && lastBeacon == 0; k, v = cursor.Prev() {
+			c := Challenges{}
+			if err := json.Unmarshal(v, &c); err != nil {
+				return err
+			}
+			if height == 0 {
+				height = c.Height
+			}
+			if c.Path != nil && len(*c.Path) > 0 && (*c.Path)[0].Challengee == address {
+				lastBeacon = c.Time
+			}
+		}
+		return nil
+	})
+	return count, lastBeacon, height, err
+}
@@ -0,0 +1,131 @@
+package analysis
+
+/*
+ * Helium Analysis
+ * Copyright (c) 2021-2022 Aaron Turner  <aturner at synfin dot net>
+ *
+ * This program is free software: you can redistribute it
+ * and/or modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or with the authors permission any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	NEARBY_PEER    = "peer"    // appears in our challenges
+	NEARBY_SILENT  = "silent"  // online, but never witnessed us or we them
+	NEARBY_OFFLINE = "offline" // offline and never witnessed
+	KM_PER_MILE    = 1.609344
+)
+
+// A hotspot within range of our hotspot
+type NearbyHotspot struct {
+	Address string  `json:"address"`
+	Name    string  `json:"name"`
+	Km      float64 `json:"km"`
+	Mi      float64 `json:"mi"`
+	Bearing float64 `json:"bearing"`
+	Status  string  `json:"status"` // peer, silent or offline
+	Online  string  `json:"online"`
+	Owner   string  `json:"owner"`
+	TxCount int     `json:"tx_count"`
+	RxCount int     `json:"rx_count"`
+}
+
+// Parses a distance like 10km, 6mi or 10 (km) and returns the value in km
+func ParseDistance(distance string) (float64, error) {
+	d := strings.ToLower(strings.TrimSpace(distance))
+	multiplier := 1.0
+	if strings.HasSuffix(d, "km") {
+		d = strings.TrimSuffix(d, "km")
+	} else if strings.HasSuffix(d, "mi") {
+		d = strings.TrimSuffix(d, "mi")
+		multiplier = KM_PER_MILE
+	}
+	value, err := strconv.ParseFloat(strings.TrimSpace(d), 64)
+	if err != nil || value <= 0.0 {
+		return 0.0, fmt.Errorf("Invalid distance '%s'.  Must be a positive number of km or mi", distance)
+	}
+	return value * multiplier, nil
+}
+
+// Returns every hotspot within radiusKm of our hotspot sorted by distance
+// and whether they are one of our peers in the given challenges
+func (b *BoltDB) GetNearby(address string, challenges []Challenges, radiusKm float64) ([]NearbyHotspot, error) {
+	nearby := []NearbyHotspot{}
+
+	aHost, err := b.GetHotspot(address)
+	if err != nil {
+		return nearby, err
+	}
+	if !hasLocation(aHost) {
+		return nearby, fmt.Errorf("%s has no asserted location", address)
+	}
+
+	_, results, err := b.getAllWitnessResults(address, challenges)
+	if err != nil {
+		return nearby, err
+	}
+
+	hotspots, err := b.GetHotspots()
+	if err != nil {
+		return nearby, err
+	}
+
+	for _, h := range hotspots {
+		if h.Address == address || !hasLocation(h) {
+			continue
+		}
+		km, mi, _ := getDistance(aHost, h)
+		if km > radiusKm {
+			continue
+		}
+
+		n := NearbyHotspot{
+			Address: h.Address,
+			Name:    h.Name,
+			Km:      km,
+			Mi:      mi,
+			Bearing: getBearing(aHost, h),
+			Owner:   h.Owner,
+		}
+		if h.Status != nil {
+			n.Online = h.Status.Online
+		}
+		for _, r := range results[h.Address] {
+			if r.Type == TX {
+				n.TxCount += 1
+			} else {
+				n.RxCount += 1
+			}
+		}
+
+		if n.TxCount+n.RxCount > 0 {
+			n.Status = NEARBY_PEER
+		} else if n.Online == "offline" {
+			n.Status = NEARBY_OFFLINE
+		} else {
+			n.Status = NEARBY_SILENT
+		}
+		nearby = append(nearby, n)
+	}
+
+	sort.SliceStable(nearby, func(i, j int) bool {
+		return nearby[i].Km < nearby[j].Km
+	})
+	return nearby, nil
+}
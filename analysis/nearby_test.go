@@ -0,0 +1,51 @@
+package analysis
+
+/*
+ * Helium Analysis
+ * Copyright (c) 2021-2022 Aaron Turner  <aturner at synfin dot net>
+ *
+ * This program is free software: you can redistribute it
+ * and/or modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or with the authors permission any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+import (
+	"testing"
+)
+
+func TestParseDistance(t *testing.T) {
+	tests := []struct {
+		distance string
+		expected float64
+	}{
+		{"5", 5.0},
+		{"2.5km", 2.5},
+		{" 10KM ", 10.0},
+		{"1mi", 1.609344},
+		{"3.5 mi", 5.632704},
+		{"0.25Mi", 0.402336},
+	}
+	for _, test := range tests {
+		got, err := ParseDistance(test.distance)
+		if err != nil {
+			t.Errorf("ParseDistance(%q): %s", test.distance, err)
+		} else if !near(got, test.expected, 1e-9) {
+			t.Errorf("ParseDistance(%q) = %f, expected %f", test.distance, got, test.expected)
+		}
+	}
+
+	for _, distance := range []string{"", "km", "0", "-1km", "5ft", "five", "5 miles"} {
+		if _, err := ParseDistance(distance); err == nil {
+			t.Errorf("Expected ParseDistance(%q) to fail", distance)
+		}
+	}
+}
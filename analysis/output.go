@@ -0,0 +1,254 @@
+package analysis
+
+/*
+ * Helium Analysis
+ * Copyright (c) 2021-2022 Aaron Turner  <aturner at synfin dot net>
+ *
+ * This program is free software: you can redistribute it
+ * and/or modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or with the authors permission any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	DEFAULT_FILE_TEMPLATE = "{hotspot}/{graph}.{ext}"
+	DEFAULT_PEER_TEMPLATE = "{hotspot}/{peer}.{ext}"
+	MANIFEST_FILE         = "index.json"
+)
+
+// Artifact types
+const (
+	ARTIFACT_GRAPH  = "graph"
+	ARTIFACT_JSON   = "json"
+	ARTIFACT_PDF    = "pdf"
+	ARTIFACT_REPORT = "report"
+)
+
+var templateVar = regexp.MustCompile(`\{([a-z_]*)\}`)
+
+// variables which can be used in the file name templates
+var TEMPLATE_VARS []string = []string{"hotspot", "address", "graph", "peer", "peer_address", "date", "ext"}
+
+// A file generated by a run
+type Artifact struct {
+	File        string `json:"file"` // relative to the output directory
+	Type        string `json:"type"` // graph, json, pdf or report
+	Format      string `json:"format"`
+	Hotspot     string `json:"hotspot"` // hotspot name
+	Address     string `json:"address,omitempty"`
+	Graph       string `json:"graph"`
+	Peer        string `json:"peer,omitempty"` // peer name for peer graphs
+	PeerAddress string `json:"peer_address,omitempty"`
+}
+
+// The index.json written into the output directory
+type Manifest struct {
+	Generated string     `json:"generated"`
+	OutputDir string     `json:"output_dir"`
+	Artifacts []Artifact `json:"artifacts"`
+}
+
+// Names the generated files and keeps track of them for the manifest
+type Output struct {
+	Dir          string
+	FileTemplate string
+	PeerTemplate string
+	Now          time.Time // used for {date}
+	lock         sync.Mutex
+	artifacts    []Artifact
+}
+
+// Returns a new Output after validating the templates
+func NewOutput(dir, fileTemplate, peerTemplate string) (*Output, error) {
+	if dir == "" {
+		dir = "."
+	}
+	if fileTemplate == "" {
+		fileTemplate = DEFAULT_FILE_TEMPLATE
+	}
+	if peerTemplate == "" {
+		peerTemplate = DEFAULT_PEER_TEMPLATE
+	}
+	if err := validateTemplate(fileTemplate, "graph"); err != nil {
+		return nil, err
+	}
+	if err := validateTemplate(peerTemplate, "peer", "peer_address"); err != nil {
+		return nil, err
+	}
+	return &Output{
+		Dir:          dir,
+		FileTemplate: fileTemplate,
+		PeerTemplate: peerTemplate,
+		Now:          time.Now(),
+		artifacts:    []Artifact{},
+	}, nil
+}
+
+// Make sure the template only uses known variables, includes {ext} and
+// at least one of the given variables so files don't overwrite each other
+func validateTemplate(template string, oneOf ...string) error {
+	vars := map[string]bool{}
+	for _, match := range templateVar.FindAllStringSubmatch(template, -1) {
+		valid := false
+		for _, v := range TEMPLATE_VARS {
+			if match[1] == v {
+				valid = true
+			}
+		}
+		if !valid {
+			return fmt.Errorf("Invalid variable %s in template '%s'.  Valid: {%s}",
+				match[0], template, strings.Join(TEMPLATE_VARS, "}, {"))
+		}
+		vars[match[1]] = true
+	}
+
+	if !vars["ext"] {
+		return fmt.Errorf("Template '%s' must include {ext}", template)
+	}
+	for _, v := range oneOf {
+		if vars[v] {
+			return nil
+		}
+	}
+	return fmt.Errorf("Template '%s' must include {%s}", template, strings.Join(oneOf, "} or {"))
+}
+
+// Returns the file name for the artifact relative to the output directory
+func (o *Output) Path(a Artifact) string {
+	template := o.FileTemplate
+	if a.Peer != "" {
+		template = o.PeerTemplate
+	}
+	values := map[string]string{
+		"hotspot":      a.Hotspot,
+		"address":      a.Address,
+		"graph":        a.Graph,
+		"peer":         a.Peer,
+		"peer_address": a.PeerAddress,
+		"date":         FormatDate(o.Now),
+		"ext":          a.Format,
+	}
+	path := templateVar.ReplaceAllStringFunc(template, func(v string) string {
+		// values must not add directories
+		return strings.ReplaceAll(values[strings.Trim(v, "{}")], string(os.PathSeparator), "_")
+	})
+	return filepath.Clean(path)
+}
+
+// Returns the full path for the artifact after creating its directory
+func (o *Output) Prepare(a Artifact) (string, Artifact, error) {
+	a.File = o.Path(a)
+	filename := filepath.Join(o.Dir, a.File)
+	if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+		return filename, a, fmt.Errorf("Unable to create directory for %s: %s", filename, err)
+	}
+	return filename, a, nil
+}
+
+// Create the file for the artifact and add it to the manifest
+func (o *Output) Create(a Artifact) (*os.File, error) {
+	filename, a, err := o.Prepare(a)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Create(filename)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to create %s: %s", filename, err)
+	}
+	o.Add(a)
+	return f, nil
+}
+
+// Write the artifact and add it to the manifest
+func (o *Output) WriteFile(a Artifact, data []byte) error {
+	filename, a, err := o.Prepare(a)
+	if err != nil {
+		return err
+	}
+	if err = ioutil.WriteFile(filename, data, 0644); err != nil {
+		return err
+	}
+	o.Add(a)
+	return nil
+}
+
+// Add the artifact to the manifest, replacing any older one with the same file
+func (o *Output) Add(a Artifact) {
+	if a.File == "" {
+		a.File = o.Path(a)
+	}
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	for i, artifact := range o.artifacts {
+		if artifact.File == a.File {
+			o.artifacts[i] = a
+			return
+		}
+	}
+	o.artifacts = append(o.artifacts, a)
+}
+
+// Returns all the artifacts generated so far
+func (o *Output) Artifacts() []Artifact {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	return append([]Artifact{}, o.artifacts...)
+}
+
+// Write the manifest of every artifact into the output directory.  Does
+// nothing if no artifacts were generated.
+func (o *Output) WriteManifest() error {
+	artifacts := o.Artifacts()
+	if len(artifacts) == 0 {
+		return nil
+	}
+	dir, err := filepath.Abs(o.Dir)
+	if err != nil {
+		return err
+	}
+	manifest := Manifest{
+		Generated: time.Now().UTC().Format(time.RFC3339),
+		OutputDir: dir,
+		Artifacts: artifacts,
+	}
+	jdata, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	filename := filepath.Join(o.Dir, MANIFEST_FILE)
+	if err = ioutil.WriteFile(filename, jdata, 0644); err != nil {
+		return fmt.Errorf("Unable to create %s: %s", filename, err)
+	}
+	log.Debugf("Created %s with %d artifacts", filename, len(artifacts))
+	return nil
+}
+
+// key used for the GraphCollection & PDF bookmarks
+func (a Artifact) key() string {
+	if a.Peer != "" {
+		return fmt.Sprintf("%s/%s", a.Hotspot, a.Peer)
+	}
+	return fmt.Sprintf("%s/%s", a.Hotspot, a.Graph)
+}
@@ -0,0 +1,80 @@
+package analysis
+
+/*
+ * Helium Analysis
+ * Copyright (c) 2021-2022 Aaron Turner  <aturner at synfin dot net>
+ *
+ * This program is free software: you can redistribute it
+ * and/or modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or with the authors permission any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+import (
+	"testing"
+	"time"
+)
+
+func TestValidateTemplate(t *testing.T) {
+	tests := []struct {
+		template string
+		oneOf    []string
+		valid    bool
+	}{
+		{DEFAULT_FILE_TEMPLATE, []string{"graph"}, true},
+		{DEFAULT_PEER_TEMPLATE, []string{"peer", "peer_address"}, true},
+		{"{date}/{address}-{peer_address}.{ext}", []string{"peer", "peer_address"}, true},
+		{"{hotspot}_{graph}_{date}.{ext}", []string{"graph"}, true},
+		{"{hotspot}/{graph}.png", []string{"graph"}, false},   // no {ext}
+		{"{hotspot}/beacons.{ext}", []string{"graph"}, false}, // files overwrite each other
+		{"{hotspot}/{name}.{ext}", []string{"graph"}, false},  // unknown variable
+		{"{hotspot}/{peer}.{ext}", []string{"graph"}, false},  // peer isn't enough for graphs
+		{"{hotspot}/{graph}.{ext}", []string{"peer", "peer_address"}, false},
+	}
+	for _, test := range tests {
+		err := validateTemplate(test.template, test.oneOf...)
+		if test.valid && err != nil {
+			t.Errorf("validateTemplate(%s): %s", test.template, err)
+		} else if !test.valid && err == nil {
+			t.Errorf("Expected validateTemplate(%s) to fail", test.template)
+		}
+	}
+}
+
+func TestOutputPath(t *testing.T) {
+	graph := Artifact{Hotspot: "red-fast-hotspot", Address: "11abc", Graph: "beacon-totals", Format: "png"}
+	peer := Artifact{Hotspot: "red-fast-hotspot", Address: "11abc", Graph: "peer",
+		Peer: "blue-slow-hotspot", PeerAddress: "11def", Format: "svg"}
+
+	tests := []struct {
+		fileTemplate string
+		peerTemplate string
+		artifact     Artifact
+		expected     string
+	}{
+		{"", "", graph, "red-fast-hotspot/beacon-totals.png"},
+		{"", "", peer, "red-fast-hotspot/blue-slow-hotspot.svg"},
+		{"{date}/{address}-{graph}.{ext}", "", graph, "2022-01-15/11abc-beacon-totals.png"},
+		{"", "{address}/peers/{peer_address}.{ext}", peer, "11abc/peers/11def.svg"},
+		{"", "", Artifact{Hotspot: "a/b", Graph: "compare", Format: "pdf"}, "a_b/compare.pdf"},
+		{"./{hotspot}//{graph}.{ext}", "", graph, "red-fast-hotspot/beacon-totals.png"},
+	}
+	for _, test := range tests {
+		o, err := NewOutput("out", test.fileTemplate, test.peerTemplate)
+		if err != nil {
+			t.Fatal(err)
+		}
+		o.Now = time.Date(2022, 1, 15, 12, 0, 0, 0, time.UTC)
+		if got := o.Path(test.artifact); got != test.expected {
+			t.Errorf("Path() with '%s' = %s, expected %s", o.FileTemplate, got, test.expected)
+		}
+	}
+}
@@ -0,0 +1,215 @@
+package analysis
+
+/*
+ * Helium Analysis
+ * Copyright (c) 2021-2022 Aaron Turner  <aturner at synfin dot net>
+ *
+ * This program is free software: you can redistribute it
+ * and/or modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or with the authors permission any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/wcharczuk/go-chart/v2"
+)
+
+const (
+	FLEET_REDUNDANT_OVERLAP = 0.75 // shared witnesses to call a pair redundant
+)
+
+// Overlap between two hotspots in a fleet
+type FleetPair struct {
+	A         string  `json:"a"` // name
+	B         string  `json:"b"` // name
+	Km        float64 `json:"km"`
+	Shared    int     `json:"shared"`       // witnesses of both A's & B's beacons
+	Overlap   float64 `json:"overlap"`      // Shared / the smaller of the two witness sets
+	AHeardByB int     `json:"a_heard_by_b"` // number of A's beacons B witnessed
+	BHeardByA int     `json:"b_heard_by_a"` // number of B's beacons A witnessed
+	Mutual    bool    `json:"mutual"`       // they witness each other
+	Redundant bool    `json:"redundant"`
+	i         int     // index of A
+	j         int     // index of B
+}
+
+type FleetOverlap struct {
+	Names     []string    `json:"names"`
+	Addresses []string    `json:"addresses"`
+	Witnesses []int       `json:"witnesses"` // number of unique witnesses for each hotspot
+	Pairs     []FleetPair `json:"pairs"`     // sorted by overlap
+	Overlap   [][]float64 `json:"overlap"`   // [i][j] matrix of FleetPair.Overlap
+}
+
+// returns the number of times each gateway witnessed our beacons
+func beaconWitnesses(address string, challenges []Challenges) map[string]int {
+	witnesses := map[string]int{}
+	for _, challenge := range challenges {
+		if challenge.Path == nil {
+			continue
+		}
+		for _, path := range *challenge.Path {
+			if path.Challengee != address || path.Witnesses == nil {
+				continue
+			}
+			for _, wit := range *path.Witnesses {
+				if wit.Gateway != address {
+					witnesses[wit.Gateway] += 1
+				}
+			}
+		}
+	}
+	return witnesses
+}
+
+// Calculate the shared witnesses & mutual witnessing between every pair of
+// hotspots in the fleet.  challenges is keyed by hotspot address and hotspots
+// without any beacons are skipped.
+func GetFleetOverlap(hotspots []Hotspot, challenges map[string][]Challenges) (FleetOverlap, error) {
+	overlap := FleetOverlap{
+		Names:     []string{},
+		Addresses: []string{},
+		Witnesses: []int{},
+		Pairs:     []FleetPair{},
+		Overlap:   [][]float64{},
+	}
+
+	fleet := []Hotspot{}
+	witnesses := []map[string]int{}
+	skipped := 0
+	for _, h := range hotspots {
+		w := beaconWitnesses(h.Address, challenges[h.Address])
+		if len(beaconTimes(h.Address, challenges[h.Address])) == 0 {
+			log.Debugf("Skipping %s: no beacons", h.Name)
+			skipped += 1
+			continue
+		}
+		fleet = append(fleet, h)
+		witnesses = append(witnesses, w)
+		overlap.Names = append(overlap.Names, h.Name)
+		overlap.Addresses = append(overlap.Addresses, h.Address)
+		overlap.Witnesses = append(overlap.Witnesses, len(w))
+	}
+	if skipped > 0 {
+		log.Warnf("Skipping %d hotspots without any beacons", skipped)
+	}
+	if len(fleet) < 2 {
+		return overlap, fmt.Errorf("Need at least 2 hotspots with beacons.  Have %d", len(fleet))
+	}
+
+	for i := range fleet {
+		overlap.Overlap = append(overlap.Overlap, make([]float64, len(fleet)))
+		overlap.Overlap[i][i] = 1.0
+	}
+
+	for i := 0; i < len(fleet); i++ {
+		for j := i + 1; j < len(fleet); j++ {
+			a := fleet[i]
+			b := fleet[j]
+			pair := FleetPair{
+				A:         a.Name,
+				B:         b.Name,
+				i:         i,
+				j:         j,
+				AHeardByB: witnesses[i][b.Address],
+				BHeardByA: witnesses[j][a.Address],
+			}
+			if hasLocation(a) && hasLocation(b) {
+				pair.Km, _, _ = getDistance(a, b)
+			}
+			for gw := range witnesses[i] {
+				if gw == b.Address {
+					continue
+				}
+				if _, ok := witnesses[j][gw]; ok {
+					pair.Shared += 1
+				}
+			}
+			// don't count each other as a possible shared witness
+			aWitnesses := len(witnesses[i])
+			if pair.AHeardByB > 0 {
+				aWitnesses -= 1
+			}
+			bWitnesses := len(witnesses[j])
+			if pair.BHeardByA > 0 {
+				bWitnesses -= 1
+			}
+			smaller := math.Min(float64(aWitnesses), float64(bWitnesses))
+			if smaller > 0 {
+				pair.Overlap = math.Min(float64(pair.Shared)/smaller, 1.0)
+			}
+			pair.Mutual = pair.AHeardByB > 0 && pair.BHeardByA > 0
+			pair.Redundant = pair.Overlap >= FLEET_REDUNDANT_OVERLAP
+
+			overlap.Overlap[i][j] = pair.Overlap
+			overlap.Overlap[j][i] = pair.Overlap
+			overlap.Pairs = append(overlap.Pairs, pair)
+		}
+	}
+
+	sort.SliceStable(overlap.Pairs, func(i, j int) bool {
+		return overlap.Pairs[i].Overlap > overlap.Pairs[j].Overlap
+	})
+	return overlap, nil
+}
+
+// Creates the heatmap of the fleet overlap using dir as the hotspot name
+func GenerateFleetOverlapGraph(dir string, overlap FleetOverlap, settings GraphSettings) error {
+	style := styleFor("fleet-overlap")
+	artifact := Artifact{Hotspot: dir, Graph: "fleet-overlap"}
+
+	// leave the diagonal blank
+	values := make([][]float64, len(overlap.Overlap))
+	for i, row := range overlap.Overlap {
+		values[i] = append([]float64{}, row...)
+		values[i][i] = math.NaN()
+	}
+
+	// each cell shows the shared witnesses, * if they witness each other and the distance
+	labels := make([][]string, len(overlap.Names))
+	for i := range labels {
+		labels[i] = make([]string, len(overlap.Names))
+	}
+	for _, p := range overlap.Pairs {
+		i, j := p.i, p.j
+		label := fmt.Sprintf("%d", p.Shared)
+		if p.Mutual {
+			label += "*"
+		}
+		label += fmt.Sprintf(" %.1fkm", p.Km)
+		labels[i][j] = label
+		labels[j][i] = label
+	}
+
+	size := len(overlap.Names)*HEATMAP_CELL_MIN + HEATMAP_LABELS + 80
+	if size < style.Height*3/2 {
+		size = style.Height * 3 / 2
+	}
+	hm := Heatmap{
+		Title:      "Fleet Shared Witnesses (* = witness each other)",
+		Width:      size,
+		Height:     size,
+		XLabels:    overlap.Names,
+		YLabels:    overlap.Names,
+		Values:     values,
+		Min:        0.0,
+		Max:        1.0,
+		CellLabels: labels,
+		Color:      chart.ColorRed,
+	}
+
+	return settings.SaveGraph(artifact, hm)
+}
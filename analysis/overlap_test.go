@@ -0,0 +1,92 @@
+package analysis
+
+/*
+ * Helium Analysis
+ * Copyright (c) 2021-2022 Aaron Turner  <aturner at synfin dot net>
+ *
+ * This program is free software: you can redistribute it
+ * and/or modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or with the authors permission any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+import (
+	"testing"
+)
+
+// returns a challenge where address beaconed and was heard by the witnesses
+func testBeacon(address string, time int64, witnesses ...string) Challenges {
+	wits := []WitnessType{}
+	for _, w := range witnesses {
+		wits = append(wits, WitnessType{Gateway: w, IsValid: true})
+	}
+	return Challenges{
+		Time: time,
+		Path: &[]PathType{{Challengee: address, Witnesses: &wits}},
+	}
+}
+
+func TestGetFleetOverlap(t *testing.T) {
+	hotspots := []Hotspot{
+		{Address: "a", Name: "alpha"},
+		{Address: "b", Name: "bravo"},
+		{Address: "c", Name: "charlie"},
+		{Address: "d", Name: "delta"}, // never beacons
+	}
+	challenges := map[string][]Challenges{
+		"a": {testBeacon("a", 1, "b", "w1", "w2"), testBeacon("a", 2, "w3")},
+		"b": {testBeacon("b", 3, "w1", "w2", "w4")},
+		"c": {testBeacon("c", 4, "a", "w1")},
+	}
+
+	overlap, err := GetFleetOverlap(hotspots, challenges)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(overlap.Names) != 3 || overlap.Names[2] != "charlie" {
+		t.Errorf("Expected delta to be skipped: %v", overlap.Names)
+	}
+
+	tests := []struct {
+		a, b      string
+		shared    int
+		overlap   float64
+		redundant bool
+	}{
+		// c heard a, so a is not one of c's possible shared witnesses
+		{"alpha", "charlie", 1, 1.0, true},
+		// b heard a, but a never heard b so b keeps all 3 of its witnesses
+		{"alpha", "bravo", 2, 2.0 / 3.0, false},
+		{"bravo", "charlie", 1, 0.5, false},
+	}
+	if len(overlap.Pairs) != len(tests) {
+		t.Fatalf("Expected %d pairs, got %d", len(tests), len(overlap.Pairs))
+	}
+	for i, test := range tests {
+		p := overlap.Pairs[i]
+		if p.A != test.a || p.B != test.b || p.Shared != test.shared ||
+			!near(p.Overlap, test.overlap, 1e-9) || p.Redundant != test.redundant {
+			t.Errorf("%d: got %s/%s shared %d overlap %f redundant %v, expected %s/%s shared %d overlap %f redundant %v",
+				i, p.A, p.B, p.Shared, p.Overlap, p.Redundant,
+				test.a, test.b, test.shared, test.overlap, test.redundant)
+		}
+	}
+	if overlap.Pairs[1].AHeardByB != 1 || overlap.Pairs[1].BHeardByA != 0 || overlap.Pairs[1].Mutual {
+		t.Errorf("Expected bravo to hear alpha once and not be mutual: %+v", overlap.Pairs[1])
+	}
+	if !near(overlap.Overlap[0][1], 2.0/3.0, 1e-9) || overlap.Overlap[1][0] != overlap.Overlap[0][1] {
+		t.Errorf("Invalid overlap matrix: %v", overlap.Overlap)
+	}
+
+	if _, err := GetFleetOverlap(hotspots[2:], challenges); err == nil {
+		t.Errorf("Expected an error with only 1 hotspot with beacons")
+	}
+}
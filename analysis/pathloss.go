@@ -0,0 +1,172 @@
+package analysis
+
+/*
+ * Helium Analysis
+ * Copyright (c) 2021-2022 Aaron Turner  <aturner at synfin dot net>
+ *
+ * This program is free software: you can redistribute it
+ * and/or modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or with the authors permission any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+import (
+	"fmt"
+	"math"
+
+	log "github.com/sirupsen/logrus"
+)
+
+/*
+ * Fits witness RSSI vs. distance to a log-distance path loss model:
+ *
+ *   RSSI = RSSI(1km) - 10 * n * log10(km)
+ *
+ * where n = 2.0 is free space.  The fitted RSSI at 1km is converted into the
+ * effective TX+RX antenna gain by backing out the TX power and FSPL at 1km
+ * which is directly comparable to the 2 * 1.8dBi that Helium assumes.
+ */
+
+const (
+	PATH_LOSS_MIN_SAMPLES  = 10
+	PATH_LOSS_MIN_KM       = 0.001
+	PATH_LOSS_MIN_EXPONENT = 1.5 // below this the fit isn't physical
+	PATH_LOSS_MIN_RSQUARED = 0.1 // below this distance doesn't explain RSSI
+)
+
+type PathLossModel struct {
+	Direction     string  `json:"direction"` // all, tx or rx
+	Samples       int     `json:"samples"`
+	Peers         int     `json:"peers"`
+	Exponent      float64 `json:"exponent"`       // path loss exponent (n)
+	RssiAt1Km     float64 `json:"rssi_at_1km"`    // fitted RSSI at 1km
+	EffectiveGain float64 `json:"effective_gain"` // TX+RX gain implied by the log-distance fit
+	FsplGain      float64 `json:"fspl_gain"`      // TX+RX gain when fit to pure FSPL (n = 2.0)
+	Score         float64 `json:"score"`          // FsplGain relative to Helium's model
+	RSquared      float64 `json:"r_squared"`
+	StdDev        float64 `json:"stddev"` // std dev of the residuals
+}
+
+// Returns the predicted RSSI at the given distance
+func (m PathLossModel) Rssi(km float64) float64 {
+	return m.RssiAt1Km - 10.0*m.Exponent*math.Log10(km)
+}
+
+// Returns the predicted distance in km for the given RSSI
+func (m PathLossModel) Distance(rssi float64) float64 {
+	return math.Pow(10.0, (m.RssiAt1Km-rssi)/(10.0*m.Exponent))
+}
+
+// Fit the given witness results (peer address => results) to the path loss model
+func FitPathLoss(direction string, results map[string][]WitnessResult) (PathLossModel, error) {
+	model := PathLossModel{
+		Direction: direction,
+	}
+
+	x := []float64{}
+	y := []float64{}
+	gains := []float64{}
+	for _, wr := range results {
+		samples := 0
+		for _, r := range wr {
+			if !r.Valid || r.Km < PATH_LOSS_MIN_KM {
+				continue
+			}
+			x = append(x, math.Log10(r.Km))
+			y = append(y, float64(r.Signal))
+			gains = append(gains, float64(r.Signal)+fspl(r.Km)-TX_POWER)
+			samples += 1
+		}
+		if samples > 0 {
+			model.Peers += 1
+		}
+	}
+	model.Samples = len(x)
+
+	if model.Samples < PATH_LOSS_MIN_SAMPLES {
+		return model, fmt.Errorf("Only %d %s samples available.  Need at least %d",
+			model.Samples, direction, PATH_LOSS_MIN_SAMPLES)
+	}
+
+	slope, intercept, r2, err := linearRegression(x, y)
+	if err != nil {
+		return model, err
+	}
+	model.Exponent = -slope / 10.0
+	model.RssiAt1Km = intercept
+	model.EffectiveGain = intercept + fspl(1.0) - TX_POWER
+	model.FsplGain = mean(gains)
+	model.Score = model.FsplGain - (ANTENNA_GAIN * 2)
+	model.RSquared = r2
+
+	residuals := []float64{}
+	for i := range x {
+		residuals = append(residuals, y[i]-(intercept+slope*x[i]))
+	}
+	model.StdDev = stddev(residuals)
+
+	// a flat or rising RSSI with distance would predict distances which are
+	// infinite or backwards
+	if model.Exponent < PATH_LOSS_MIN_EXPONENT {
+		return model, fmt.Errorf("Fitted %s path loss exponent %.2f is below %.1f.  Need more distinct peer distances",
+			direction, model.Exponent, PATH_LOSS_MIN_EXPONENT)
+	}
+	if model.RSquared < PATH_LOSS_MIN_RSQUARED {
+		return model, fmt.Errorf("Fitted %s path loss R-squared %.2f is below %.2f.  RSSI is too noisy to fit",
+			direction, model.RSquared, PATH_LOSS_MIN_RSQUARED)
+	}
+	return model, nil
+}
+
+// Returns the path loss models for all, TX (they hear us) and RX (we hear them)
+// witnesses of the given hotspot.  Directions without enough data are skipped.
+func (b *BoltDB) GetPathLoss(address string, challenges []Challenges) ([]PathLossModel, error) {
+	models := []PathLossModel{}
+
+	peers, results, err := b.getAllWitnessResults(address, challenges)
+	if err != nil {
+		return models, err
+	}
+
+	all := map[string][]WitnessResult{}
+	tx := map[string][]WitnessResult{}
+	rx := map[string][]WitnessResult{}
+	for _, peer := range peers {
+		for _, r := range results[peer] {
+			all[peer] = append(all[peer], r)
+			if r.Type == TX {
+				tx[peer] = append(tx[peer], r)
+			} else {
+				rx[peer] = append(rx[peer], r)
+			}
+		}
+	}
+
+	for _, d := range []struct {
+		direction string
+		results   map[string][]WitnessResult
+	}{
+		{"all", all},
+		{"tx", tx},
+		{"rx", rx},
+	} {
+		model, err := FitPathLoss(d.direction, d.results)
+		if err != nil {
+			if d.direction == "all" {
+				return models, err
+			}
+			log.WithError(err).Warnf("Skipping %s path loss model", d.direction)
+			continue
+		}
+		models = append(models, model)
+	}
+	return models, nil
+}
@@ -0,0 +1,105 @@
+package analysis
+
+/*
+ * Helium Analysis
+ * Copyright (c) 2021-2022 Aaron Turner  <aturner at synfin dot net>
+ *
+ * This program is free software: you can redistribute it
+ * and/or modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or with the authors permission any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+import (
+	"fmt"
+	"math"
+	"testing"
+)
+
+// returns witness results which exactly follow RSSI = rssi1Km - 10 * n * log10(km)
+// at 1, 2.15, 4.64, 10 & 100km for each peer
+func pathLossResults(peers int, rssi1Km, n float64) map[string][]WitnessResult {
+	results := map[string][]WitnessResult{}
+	for p := 0; p < peers; p++ {
+		peer := fmt.Sprintf("peer%d", p)
+		for _, exp := range []float64{0.0, 1.0 / 3.0, 2.0 / 3.0, 1.0, 2.0} {
+			km := math.Pow(10.0, exp)
+			results[peer] = append(results[peer], WitnessResult{
+				Signal: int(math.Round(rssi1Km - 10.0*n*exp)),
+				Valid:  true,
+				Km:     km,
+			})
+		}
+	}
+	return results
+}
+
+func TestFitPathLoss(t *testing.T) {
+	// exact fit with n = 3.0: -60, -70, -80, -90 & -120dBm
+	results := pathLossResults(3, -60.0, 3.0)
+	// invalid witnesses which would ruin the fit are ignored
+	results["peer0"] = append(results["peer0"], WitnessResult{Signal: -20, Valid: false, Km: 100.0})
+
+	model, err := FitPathLoss("all", results)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if model.Samples != 15 || model.Peers != 3 {
+		t.Errorf("Expected 15 samples from 3 peers, got %d from %d", model.Samples, model.Peers)
+	}
+	if !near(model.Exponent, 3.0, 1e-9) {
+		t.Errorf("Exponent = %g, expected 3.0", model.Exponent)
+	}
+	if !near(model.RssiAt1Km, -60.0, 1e-9) {
+		t.Errorf("RssiAt1Km = %g, expected -60.0", model.RssiAt1Km)
+	}
+	// -60dBm + 91.669dB FSPL at 1km - 28dBm TX power
+	if !near(model.EffectiveGain, 3.669, 0.001) {
+		t.Errorf("EffectiveGain = %g, expected 3.669", model.EffectiveGain)
+	}
+	if !near(model.RSquared, 1.0, 1e-9) || !near(model.StdDev, 0.0, 1e-9) {
+		t.Errorf("Expected a perfect fit, got R^2 = %g, stddev = %g", model.RSquared, model.StdDev)
+	}
+	if !near(model.Distance(-90.0), 10.0, 1e-9) || !near(model.Rssi(100.0), -120.0, 1e-9) {
+		t.Errorf("Distance(-90) = %g, Rssi(100) = %g, expected 10 & -120",
+			model.Distance(-90.0), model.Rssi(100.0))
+	}
+}
+
+func TestFitPathLossErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		results map[string][]WitnessResult
+	}{
+		{"too few samples", pathLossResults(1, -60.0, 3.0)},
+		{"RSSI rises with distance", pathLossResults(3, -120.0, -2.0)},
+		{"flat RSSI", pathLossResults(3, -90.0, 0.0)},
+		{"exponent below 1.5", pathLossResults(3, -60.0, 1.0)},
+	}
+	for _, test := range tests {
+		if model, err := FitPathLoss("all", test.results); err == nil {
+			t.Errorf("%s: expected an error, got exponent %g", test.name, model.Exponent)
+		}
+	}
+
+	// noise which swamps the distance
+	noisy := map[string][]WitnessResult{}
+	for i := 0; i < 20; i++ {
+		noisy["peer"] = append(noisy["peer"], WitnessResult{
+			Signal: -90 + 30*(i%2) - 15*(i%4/2),
+			Valid:  true,
+			Km:     1.0 + float64(i%5),
+		})
+	}
+	if model, err := FitPathLoss("all", noisy); err == nil {
+		t.Errorf("noisy: expected an error, got R^2 %g", model.RSquared)
+	}
+}
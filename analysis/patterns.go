@@ -0,0 +1,231 @@
+package analysis
+
+/*
+ * Helium Analysis
+ * Copyright (c) 2021-2022 Aaron Turner  <aturner at synfin dot net>
+ *
+ * This program is free software: you can redistribute it
+ * and/or modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or with the authors permission any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/wcharczuk/go-chart/v2"
+	"github.com/wcharczuk/go-chart/v2/drawing"
+)
+
+// Witness RSSI & SNR for a single hour of the day
+type HourlyRssi struct {
+	Hour       int     `json:"hour"`
+	Samples    int     `json:"samples"`
+	RssiMean   float64 `json:"rssi_mean"`
+	RssiMedian float64 `json:"rssi_median"`
+	SnrMean    float64 `json:"snr_mean"`
+}
+
+// Activity of a hotspot by day of week & hour of day in the given timezone
+type ActivityPatterns struct {
+	Address   string         `json:"address"`
+	Timezone  string         `json:"timezone"`
+	Beacons   [7][24]int     `json:"beacons"`   // [weekday][hour], Sunday = 0
+	Witnesses [7][24]int     `json:"witnesses"` // beacons of other hotspots we witnessed
+	Rssi      [24]HourlyRssi `json:"rssi"`      // all witnesses to & from us
+}
+
+var WEEKDAYS []string = []string{"Sun", "Mon", "Tue", "Wed", "Thu", "Fri", "Sat"}
+
+// Count the beacons & witnesses of the given hotspot by weekday & hour and
+// calculate the witness RSSI for each hour of the day
+func (b *BoltDB) GetActivityPatterns(address string, challenges []Challenges, tz *time.Location) (ActivityPatterns, error) {
+	patterns := ActivityPatterns{
+		Address:  address,
+		Timezone: tz.String(),
+	}
+
+	for _, challenge := range challenges {
+		if challenge.Path == nil || len(*challenge.Path) == 0 {
+			continue
+		}
+		t, err := challenge.GetTime()
+		if err != nil {
+			log.WithError(err).Debugf("Skipping challenge")
+			continue
+		}
+		t = t.In(tz)
+		day, hour := int(t.Weekday()), t.Hour()
+
+		path := (*challenge.Path)[0]
+		if path.Challengee == address {
+			patterns.Beacons[day][hour] += 1
+		} else if path.Witnesses != nil {
+			for _, witness := range *path.Witnesses {
+				if witness.Gateway == address {
+					patterns.Witnesses[day][hour] += 1
+					break
+				}
+			}
+		}
+	}
+
+	peers, results, err := b.getAllWitnessResults(address, challenges)
+	if err != nil {
+		return patterns, err
+	}
+	rssi := [24][]float64{}
+	snr := [24][]float64{}
+	for _, peer := range peers {
+		for _, r := range results[peer] {
+			hour := time.Unix(0, r.Timestamp).In(tz).Hour()
+			rssi[hour] = append(rssi[hour], float64(r.Signal))
+			snr[hour] = append(snr[hour], r.Snr)
+		}
+	}
+	for hour := 0; hour < 24; hour++ {
+		patterns.Rssi[hour] = HourlyRssi{
+			Hour:       hour,
+			Samples:    len(rssi[hour]),
+			RssiMean:   mean(rssi[hour]),
+			RssiMedian: median(rssi[hour]),
+			SnrMean:    mean(snr[hour]),
+		}
+	}
+	return patterns, nil
+}
+
+// returns the heatmap for counts by [weekday][hour]
+func patternHeatmap(graph, title string, counts [7][24]int, color drawing.Color) Heatmap {
+	style := styleFor(graph)
+	hours := []string{}
+	for hour := 0; hour < 24; hour++ {
+		hours = append(hours, fmt.Sprintf("%02d", hour))
+	}
+
+	values := make([][]float64, 7)
+	labels := make([][]string, 7)
+	maxCount := 0.0
+	for day := 0; day < 7; day++ {
+		values[day] = make([]float64, 24)
+		labels[day] = make([]string, 24)
+		for hour := 0; hour < 24; hour++ {
+			values[day][hour] = float64(counts[day][hour])
+			maxCount = math.Max(maxCount, values[day][hour])
+			if counts[day][hour] > 0 {
+				labels[day][hour] = fmt.Sprintf("%d", counts[day][hour])
+			}
+		}
+	}
+
+	return Heatmap{
+		Title:      title,
+		Width:      style.Width,
+		Height:     style.Height,
+		XLabels:    hours,
+		YLabels:    WEEKDAYS,
+		Values:     values,
+		Min:        0.0,
+		Max:        maxCount,
+		CellLabels: labels,
+		Color:      color,
+	}
+}
+
+// Creates the beacon & witness heatmaps and the RSSI by hour graph
+func (b *BoltDB) GeneratePatternsGraphs(address string, patterns ActivityPatterns, settings GraphSettings) error {
+	hotspotName, err := b.GetHotspotName(address)
+	if err != nil {
+		return err
+	}
+
+	hm := patternHeatmap("patterns-beacons", fmt.Sprintf("Beacons by Hour for %s (%s)", hotspotName, patterns.Timezone),
+		patterns.Beacons, chart.ColorBlue)
+	if err = settings.SaveGraph(Artifact{Hotspot: hotspotName, Address: address, Graph: "patterns-beacons"}, hm); err != nil {
+		return err
+	}
+
+	hm = patternHeatmap("patterns-witnesses", fmt.Sprintf("Witnesses by Hour for %s (%s)", hotspotName, patterns.Timezone),
+		patterns.Witnesses, chart.ColorGreen)
+	if err = settings.SaveGraph(Artifact{Hotspot: hotspotName, Address: address, Graph: "patterns-witnesses"}, hm); err != nil {
+		return err
+	}
+
+	return b.generateHourlyRssiGraph(hotspotName, patterns, settings)
+}
+
+// Creates the bar graph of the mean witness RSSI for each hour of the day
+func (b *BoltDB) generateHourlyRssiGraph(hotspotName string, patterns ActivityPatterns, settings GraphSettings) error {
+	style := styleFor("patterns-rssi")
+	artifact := Artifact{Hotspot: hotspotName, Address: patterns.Address, Graph: "patterns-rssi"}
+
+	y_min := style.YMax
+	y_max := style.YMin
+	samples := 0
+	for _, h := range patterns.Rssi {
+		if h.Samples == 0 {
+			continue
+		}
+		samples += h.Samples
+		y_min = math.Min(y_min, h.RssiMean-5.0)
+		y_max = math.Max(y_max, h.RssiMean+5.0)
+	}
+	if samples < settings.Min || samples == 0 {
+		return fmt.Errorf("Only %d datapoints available", samples)
+	}
+
+	bars := []chart.Value{}
+	for _, h := range patterns.Rssi {
+		value := h.RssiMean
+		if h.Samples == 0 {
+			value = y_min
+		}
+		bars = append(bars, chart.Value{
+			Label: fmt.Sprintf("%02d", h.Hour),
+			Value: value,
+			Style: chart.Style{
+				FillColor:   chart.ColorBlue,
+				StrokeColor: chart.ColorBlue,
+			},
+		})
+	}
+
+	graph := chart.BarChart{
+		Title:  fmt.Sprintf("Mean Witness RSSI by Hour for %s (%s)", hotspotName, patterns.Timezone),
+		Height: style.Height,
+		Width:  style.Width,
+		Background: chart.Style{
+			Padding: chart.Box{
+				Top:    60,
+				Left:   20,
+				Right:  20,
+				Bottom: 20,
+			},
+		},
+		YAxis: chart.YAxis{
+			Name: "RSSI db",
+			Range: &chart.ContinuousRange{
+				Min: y_min,
+				Max: y_max,
+			},
+		},
+		UseBaseValue: true,
+		BaseValue:    y_min,
+		BarSpacing:   2,
+		BarWidth:     (style.Width - 80) / 24,
+		Bars:         bars,
+	}
+
+	return settings.SaveGraph(artifact, graph)
+}
@@ -0,0 +1,243 @@
+package analysis
+
+/*
+ * Helium Analysis
+ * Copyright (c) 2021-2022 Aaron Turner  <aturner at synfin dot net>
+ *
+ * This program is free software: you can redistribute it
+ * and/or modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or with the authors permission any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+
+	"github.com/golang/freetype/truetype"
+	"github.com/jung-kurt/gofpdf"
+	log "github.com/sirupsen/logrus"
+	"github.com/wcharczuk/go-chart/v2"
+	"github.com/wcharczuk/go-chart/v2/drawing"
+)
+
+/*
+ * go-chart only renders PNG & SVG, so we implement the chart.Renderer
+ * interface on top of gofpdf.  Every graph gets its own page the same size
+ * as the graph with 1px = 1pt so the graphs stay vector all the way to the
+ * printer.  Text uses the built in Helvetica font instead of go-chart's
+ * Roboto, which has nearly identical metrics.
+ */
+
+const (
+	PDF_FONT       = "Helvetica"
+	PDF_CAP_HEIGHT = 0.7     // Helvetica cap height relative to the font size
+	PDF_MAX_COORD  = 32767.0 // largest coordinate allowed by the PDF spec
+)
+
+// go-chart draws unused axes at math.MinInt64 which is just off the canvas
+// for PNG, but an invalid number in a PDF
+func pdfCoord(v int) float64 {
+	return math.Max(-PDF_MAX_COORD, math.Min(float64(v), PDF_MAX_COORD))
+}
+
+// A multi-page PDF with one graph per page
+type PdfDocument struct {
+	pdf   *gofpdf.Fpdf
+	tr    func(string) string
+	Pages int
+}
+
+func NewPdfDocument(title string) *PdfDocument {
+	pdf := gofpdf.NewCustom(&gofpdf.InitType{
+		UnitStr: "pt",
+		Size:    gofpdf.SizeType{Wd: float64(style.Width), Ht: float64(style.Height)},
+	})
+	pdf.SetTitle(title, true)
+	pdf.SetCreator("helium-analysis", true)
+	pdf.SetMargins(0, 0, 0)
+	pdf.SetAutoPageBreak(false, 0)
+	return &PdfDocument{
+		pdf: pdf,
+		tr:  pdf.UnicodeTranslatorFromDescriptor(""),
+	}
+}
+
+// Render the graph on a new page with the given bookmark title
+func (d *PdfDocument) AddGraph(title string, graph Renderable) error {
+	rp := func(width, height int) (chart.Renderer, error) {
+		d.pdf.AddPageFormat("P", gofpdf.SizeType{Wd: float64(width), Ht: float64(height)})
+		d.pdf.Bookmark(d.tr(title), 0, 0)
+		return newPdfRenderer(d.pdf, d.tr), nil
+	}
+	if err := graph.Render(rp, ioutil.Discard); err != nil {
+		return fmt.Errorf("Unable to render %s: %s", title, err)
+	}
+	if err := d.pdf.Error(); err != nil {
+		return err
+	}
+	d.Pages += 1
+	return nil
+}
+
+// Write the PDF to the given file
+func (d *PdfDocument) Save(filename string) error {
+	if d.Pages == 0 {
+		return fmt.Errorf("No graphs to write to %s", filename)
+	}
+	if err := d.pdf.OutputFileAndClose(filename); err != nil {
+		return fmt.Errorf("Unable to create %s: %s", filename, err)
+	}
+	log.Infof("Created %s", filename)
+	return nil
+}
+
+// Implements chart.Renderer for a single page of a PdfDocument
+type pdfRenderer struct {
+	pdf         *gofpdf.Fpdf
+	tr          func(string) string
+	dpi         float64
+	strokeColor drawing.Color
+	fillColor   drawing.Color
+	strokeWidth float64
+	dashArray   []float64
+	fontColor   drawing.Color
+	fontSize    float64
+	rotation    float64
+	pdfFontSize float64 // last font size sent to gofpdf
+}
+
+func newPdfRenderer(pdf *gofpdf.Fpdf, tr func(string) string) *pdfRenderer {
+	r := &pdfRenderer{
+		pdf: pdf,
+		tr:  tr,
+		dpi: chart.DefaultDPI,
+	}
+	r.ResetStyle()
+	return r
+}
+
+func (r *pdfRenderer) ResetStyle() {
+	r.strokeColor = chart.ColorBlack
+	r.fillColor = chart.ColorWhite
+	r.strokeWidth = chart.DefaultStrokeWidth
+	r.dashArray = nil
+	r.fontColor = chart.ColorBlack
+	r.fontSize = chart.DefaultFontSize
+	r.rotation = 0.0
+}
+
+func (r *pdfRenderer) GetDPI() float64                   { return r.dpi }
+func (r *pdfRenderer) SetDPI(dpi float64)                { r.dpi = dpi }
+func (r *pdfRenderer) SetClassName(string)               {}
+func (r *pdfRenderer) SetStrokeColor(c drawing.Color)    { r.strokeColor = c }
+func (r *pdfRenderer) SetFillColor(c drawing.Color)      { r.fillColor = c }
+func (r *pdfRenderer) SetStrokeWidth(width float64)      { r.strokeWidth = width }
+func (r *pdfRenderer) SetStrokeDashArray(dash []float64) { r.dashArray = dash }
+func (r *pdfRenderer) SetFont(*truetype.Font)            {}
+func (r *pdfRenderer) SetFontColor(c drawing.Color)      { r.fontColor = c }
+func (r *pdfRenderer) SetFontSize(size float64)          { r.fontSize = size }
+func (r *pdfRenderer) SetTextRotation(radians float64)   { r.rotation = radians }
+func (r *pdfRenderer) ClearTextRotation()                { r.rotation = 0.0 }
+func (r *pdfRenderer) MoveTo(x, y int)                   { r.pdf.MoveTo(pdfCoord(x), pdfCoord(y)) }
+func (r *pdfRenderer) LineTo(x, y int)                   { r.pdf.LineTo(pdfCoord(x), pdfCoord(y)) }
+func (r *pdfRenderer) QuadCurveTo(cx, cy, x, y int) {
+	r.pdf.CurveTo(pdfCoord(cx), pdfCoord(cy), pdfCoord(x), pdfCoord(y))
+}
+func (r *pdfRenderer) Close()                 { r.pdf.ClosePath() }
+func (r *pdfRenderer) Save(w io.Writer) error { return r.pdf.Error() }
+func (r *pdfRenderer) Stroke()                { r.draw(false, true) }
+func (r *pdfRenderer) Fill()                  { r.draw(true, false) }
+func (r *pdfRenderer) FillStroke()            { r.draw(true, true) }
+
+// go-chart angles are clockwise since y increases down the page, while gofpdf
+// angles are in degrees counter-clockwise
+func (r *pdfRenderer) ArcTo(cx, cy int, rx, ry, startAngle, delta float64) {
+	start := -startAngle * 180.0 / math.Pi
+	end := -(startAngle + delta) * 180.0 / math.Pi
+	r.pdf.ArcTo(pdfCoord(cx), pdfCoord(cy), rx, ry, 0.0, start, end)
+}
+
+func (r *pdfRenderer) Circle(radius float64, x, y int) {
+	r.pdf.MoveTo(pdfCoord(x)+radius, pdfCoord(y))
+	r.pdf.ArcTo(pdfCoord(x), pdfCoord(y), radius, radius, 0.0, 0.0, 360.0)
+}
+
+// fills and/or strokes the current path, skipping transparent colors
+func (r *pdfRenderer) draw(fill, stroke bool) {
+	fill = fill && r.fillColor.A > 0
+	stroke = stroke && r.strokeColor.A > 0 && r.strokeWidth > 0
+	style := "n" // end the path without painting
+	alpha := uint8(255)
+	switch {
+	case fill && stroke:
+		style = "FD"
+	case fill:
+		style = "F"
+	case stroke:
+		style = "D"
+	}
+
+	if stroke {
+		c := r.strokeColor
+		r.pdf.SetDrawColor(int(c.R), int(c.G), int(c.B))
+		r.pdf.SetLineWidth(r.strokeWidth)
+		r.pdf.SetDashPattern(r.dashArray, 0.0)
+		alpha = c.A
+	}
+	if fill {
+		c := r.fillColor
+		r.pdf.SetFillColor(int(c.R), int(c.G), int(c.B))
+		alpha = c.A
+	}
+	if alpha < 255 {
+		r.pdf.SetAlpha(float64(alpha)/255.0, "Normal")
+		defer r.pdf.SetAlpha(1.0, "Normal")
+	}
+	r.pdf.DrawPath(style)
+}
+
+// go-chart font sizes are in points at the renderer DPI while our page
+// is in pixels
+func (r *pdfRenderer) setFont() float64 {
+	size := r.fontSize * r.dpi / 72.0
+	if size != r.pdfFontSize {
+		r.pdf.SetFont(PDF_FONT, "", size)
+		r.pdfFontSize = size
+	}
+	return size
+}
+
+func (r *pdfRenderer) Text(body string, x, y int) {
+	r.setFont()
+	c := r.fontColor
+	r.pdf.SetTextColor(int(c.R), int(c.G), int(c.B))
+	if r.rotation != 0.0 {
+		r.pdf.TransformBegin()
+		r.pdf.TransformRotate(-r.rotation*180.0/math.Pi, pdfCoord(x), pdfCoord(y))
+		defer r.pdf.TransformEnd()
+	}
+	r.pdf.Text(pdfCoord(x), pdfCoord(y), r.tr(body))
+}
+
+func (r *pdfRenderer) MeasureText(body string) chart.Box {
+	size := r.setFont()
+	box := chart.Box{
+		Right:  int(math.Ceil(r.pdf.GetStringWidth(r.tr(body)))),
+		Bottom: int(math.Ceil(size * PDF_CAP_HEIGHT)),
+	}
+	if r.rotation == 0.0 {
+		return box
+	}
+	return box.Corners().Rotate(r.rotation * 180.0 / math.Pi).Box()
+}
@@ -19,10 +19,8 @@ package analysis
  */
 
 import (
-	"encoding/json"
 	"fmt"
-	"io/ioutil"
-	"os"
+	"time"
 
 	"github.com/wcharczuk/go-chart/v2"
 
@@ -40,23 +38,7 @@ func (b *BoltDB) GeneratePeerGraphs(address string, challenges []Challenges, set
 		return fmt.Errorf("Only %d datapoints available", len(challenges))
 	}
 
-	x_min := 0.0
-	x_max := 0.0
-	if !settings.Zoom {
-		for i := 0; x_min == 0; i++ {
-			min, err := challenges[i].GetTimestamp()
-			if err == nil {
-				x_min = float64(min)
-			}
-		}
-		for i := len(challenges) - 1; x_max == 0; i-- {
-			max, err := challenges[i].GetTimestamp()
-			if err == nil {
-				x_max = float64(max)
-			}
-		}
-	}
-
+	x_min, x_max := peerGraphRange(challenges, settings)
 	cnt := 0
 	for _, peer := range addresses {
 		wr, err := b.getWitnessResults(address, peer, challenges)
@@ -70,13 +52,8 @@ func (b *BoltDB) GeneratePeerGraphs(address string, challenges []Challenges, set
 			continue
 		}
 
-		var join_time int64 = 0
-		host, err := b.GetHotspot(peer)
-		if err == nil {
-			join_time, err = getTimeForHeight(host.BlockAdded, challenges)
-		}
-
-		generated, err := b.generatePeerGraph(address, peer, wr, settings.Min, x_min, x_max, join_time, settings)
+		generated, err := b.generatePeerGraph(address, peer, wr, settings.Min, x_min, x_max,
+			b.peerJoinTime(peer, challenges), settings)
 		if err != nil {
 			log.WithError(err).Errorf("Unable to generate graph")
 		}
@@ -87,8 +64,61 @@ func (b *BoltDB) GeneratePeerGraphs(address string, challenges []Challenges, set
 	return nil
 }
 
+// Generate the graph for a single peer
+func (b *BoltDB) GeneratePeerGraph(address, peer string, challenges []Challenges, settings GraphSettings) error {
+	if len(challenges) < settings.Min {
+		return fmt.Errorf("Only %d datapoints available", len(challenges))
+	}
+
+	wr, err := b.getWitnessResults(address, peer, challenges)
+	if err != nil {
+		return err
+	}
+	x_min, x_max := peerGraphRange(challenges, settings)
+	generated, err := b.generatePeerGraph(address, peer, wr, settings.Min, x_min, x_max,
+		b.peerJoinTime(peer, challenges), settings)
+	if err != nil {
+		return err
+	} else if !generated {
+		return fmt.Errorf("Only %d witnesses between %s and %s", len(wr), address, peer)
+	}
+	return nil
+}
+
+// returns the time range of the challenges for the x axis or 0.0 if zoomed
+func peerGraphRange(challenges []Challenges, settings GraphSettings) (float64, float64) {
+	x_min := 0.0
+	x_max := 0.0
+	if !settings.Zoom {
+		for i := 0; x_min == 0 && i < len(challenges); i++ {
+			min, err := challenges[i].GetTimestamp()
+			if err == nil {
+				x_min = float64(min)
+			}
+		}
+		for i := len(challenges) - 1; x_max == 0 && i >= 0; i-- {
+			max, err := challenges[i].GetTimestamp()
+			if err == nil {
+				x_max = float64(max)
+			}
+		}
+	}
+	return x_min, x_max
+}
+
+// returns when the peer was added to the blockchain or 0 if unknown
+func (b *BoltDB) peerJoinTime(peer string, challenges []Challenges) int64 {
+	var join_time int64 = 0
+	host, err := b.GetHotspot(peer)
+	if err == nil {
+		join_time, _ = getTimeForHeight(host.BlockAdded, challenges)
+	}
+	return join_time
+}
+
 // Generate each peer graph
 func (b *BoltDB) generatePeerGraph(address, witness string, results []WitnessResult, min int, x_min, x_max float64, join_time int64, settings GraphSettings) (bool, error) {
+	style := styleFor("peer")
 	a, err := b.GetHotspotName(address)
 	if err != nil {
 		return false, err
@@ -97,8 +127,7 @@ func (b *BoltDB) generatePeerGraph(address, witness string, results []WitnessRes
 	if err != nil {
 		return false, err
 	}
-	filename := fmt.Sprintf("%s/%s.png", a, w)
-	jsonFilename := fmt.Sprintf("%s/%s.json", a, w)
+	artifact := Artifact{Hotspot: a, Address: address, Graph: "peer", Peer: w, PeerAddress: witness}
 
 	thresholds_x := []float64{}
 	thresholds_vals := []float64{}
@@ -122,7 +151,7 @@ func (b *BoltDB) generatePeerGraph(address, witness string, results []WitnessRes
 	for _, ret := range results {
 		x := float64(ret.Timestamp)
 		y := float64(ret.Signal)
-		if y < Y_MIN || y > Y_MAX {
+		if y < style.YMin || y > style.YMax {
 			forceYRange = y
 		}
 		if ret.Type == RX {
@@ -145,7 +174,7 @@ func (b *BoltDB) generatePeerGraph(address, witness string, results []WitnessRes
 		thresholds_vals = append(thresholds_vals, ret.ValidThreshold)
 		thresholds_x = append(thresholds_x, x)
 		snr = append(snr, ret.Snr)
-		if ret.Snr < SNR_MIN || ret.Snr > SNR_MAX {
+		if ret.Snr < style.SnrMin || ret.Snr > style.SnrMax {
 			forceSNRRange = ret.Snr
 		}
 	}
@@ -158,9 +187,9 @@ func (b *BoltDB) generatePeerGraph(address, witness string, results []WitnessRes
 			Name: fmt.Sprintf("TX Signal (%d)", len(tx_x)),
 			Style: chart.Style{
 				StrokeWidth: chart.Disabled,
-				DotWidth:    DOT_SIZE,
-				StrokeColor: chart.ColorBlue,
-				DotColor:    chart.ColorBlue,
+				DotWidth:    style.DotSize,
+				StrokeColor: style.Colors.Tx,
+				DotColor:    style.Colors.Tx,
 			},
 			XValues: tx_x,
 			YValues: tx_vals,
@@ -178,8 +207,8 @@ func (b *BoltDB) generatePeerGraph(address, witness string, results []WitnessRes
 			Style: chart.Style{
 				StrokeWidth:     1,
 				DotWidth:        chart.Disabled,
-				StrokeColor:     chart.ColorBlue,
-				DotColor:        chart.ColorBlue,
+				StrokeColor:     style.Colors.Tx,
+				DotColor:        style.Colors.Tx,
 				StrokeDashArray: []float64{5.0, 5.0},
 			},
 			InnerSeries: txHiddenSeries,
@@ -189,9 +218,9 @@ func (b *BoltDB) generatePeerGraph(address, witness string, results []WitnessRes
 			Name: fmt.Sprintf("InvalidTX (%d)", len(tx_invalid_x)),
 			Style: chart.Style{
 				StrokeWidth: chart.Disabled,
-				DotWidth:    DOT_SIZE,
-				DotColor:    chart.ColorRed,
-				StrokeColor: chart.ColorRed,
+				DotWidth:    style.DotSize,
+				DotColor:    style.Colors.TxInvalid,
+				StrokeColor: style.Colors.TxInvalid,
 			},
 			XValues: tx_invalid_x,
 			YValues: tx_invalid_vals,
@@ -205,9 +234,9 @@ func (b *BoltDB) generatePeerGraph(address, witness string, results []WitnessRes
 			Name: fmt.Sprintf("RX Signal (%d)", len(rx_x)),
 			Style: chart.Style{
 				StrokeWidth: chart.Disabled,
-				DotWidth:    DOT_SIZE,
-				StrokeColor: chart.ColorGreen,
-				DotColor:    chart.ColorGreen,
+				DotWidth:    style.DotSize,
+				StrokeColor: style.Colors.Rx,
+				DotColor:    style.Colors.Rx,
 			},
 			XValues: rx_x,
 			YValues: rx_vals,
@@ -226,8 +255,8 @@ func (b *BoltDB) generatePeerGraph(address, witness string, results []WitnessRes
 			Style: chart.Style{
 				StrokeWidth:     1,
 				DotWidth:        chart.Disabled,
-				StrokeColor:     chart.ColorGreen,
-				DotColor:        chart.ColorGreen,
+				StrokeColor:     style.Colors.Rx,
+				DotColor:        style.Colors.Rx,
 				StrokeDashArray: []float64{5.0, 5.0},
 			},
 			InnerSeries: rxHiddenSeries,
@@ -237,9 +266,9 @@ func (b *BoltDB) generatePeerGraph(address, witness string, results []WitnessRes
 			Name: fmt.Sprintf("InvalidRX (%d)", len(rx_invalid_x)),
 			Style: chart.Style{
 				StrokeWidth: chart.Disabled,
-				DotWidth:    DOT_SIZE,
-				DotColor:    chart.ColorYellow,
-				StrokeColor: chart.ColorYellow,
+				DotWidth:    style.DotSize,
+				DotColor:    style.Colors.RxInvalid,
+				StrokeColor: style.Colors.RxInvalid,
 			},
 			XValues: rx_invalid_x,
 			YValues: rx_invalid_vals,
@@ -250,7 +279,7 @@ func (b *BoltDB) generatePeerGraph(address, witness string, results []WitnessRes
 
 	if len(series) == 0 {
 		// no data
-		log.Debugf("Skipping: %s", filename)
+		log.Debugf("Skipping: %s", artifact.key())
 		return false, nil
 	}
 
@@ -264,7 +293,7 @@ func (b *BoltDB) generatePeerGraph(address, witness string, results []WitnessRes
 		Style: chart.Style{
 			StrokeWidth:     1,
 			DotWidth:        chart.Disabled,
-			StrokeColor:     chart.ColorOrange,
+			StrokeColor:     style.Colors.MinValid,
 			StrokeDashArray: []float64{5.0, 5.0},
 		},
 	}
@@ -276,7 +305,7 @@ func (b *BoltDB) generatePeerGraph(address, witness string, results []WitnessRes
 		Style: chart.Style{
 			StrokeWidth:     1,
 			DotWidth:        chart.Disabled,
-			StrokeColor:     chart.ColorRed,
+			StrokeColor:     style.Colors.MaxValid,
 			StrokeDashArray: []float64{5.0, 5.0},
 		},
 	})
@@ -289,7 +318,7 @@ func (b *BoltDB) generatePeerGraph(address, witness string, results []WitnessRes
 		Style: chart.Style{
 			StrokeWidth:     1,
 			DotWidth:        chart.Disabled,
-			StrokeColor:     chart.ColorYellow,
+			StrokeColor:     style.Colors.Snr,
 			StrokeDashArray: []float64{5.0, 5.0},
 		},
 	}
@@ -309,19 +338,19 @@ func (b *BoltDB) generatePeerGraph(address, witness string, results []WitnessRes
 	}
 
 	// Zoom in?
-	x_range := chart.ContinuousRange{}
+	x_range := NewTimeAxisRange(time.Nanosecond, 0, 0)
 	y_range := chart.ContinuousRange{}
 	snr_range := chart.ContinuousRange{}
 	if x_min > 0.0 && x_max > 0.0 {
 		x_range.Min = x_min
 		x_range.Max = x_max
 		if lockYRange {
-			y_range.Min = Y_MIN
-			y_range.Max = Y_MAX
+			y_range.Min = style.YMin
+			y_range.Max = style.YMax
 		}
 		if lockSNRRange {
-			snr_range.Min = SNR_MIN
-			snr_range.Max = SNR_MAX
+			snr_range.Min = style.SnrMin
+			snr_range.Max = style.SnrMax
 		}
 	}
 
@@ -353,7 +382,7 @@ func (b *BoltDB) generatePeerGraph(address, witness string, results []WitnessRes
 		},
 		XAxis: chart.XAxis{
 			ValueFormatter: XValueFormatter,
-			Range:          &x_range,
+			Range:          x_range,
 		},
 		YAxisSecondary: chart.YAxis{
 			Name:  "SNR db",
@@ -363,28 +392,23 @@ func (b *BoltDB) generatePeerGraph(address, witness string, results []WitnessRes
 			Name:  "RSSI db",
 			Range: &y_range,
 		},
-		Height: HEIGHT,
-		Width:  WIDTH,
+		Height: style.Height,
+		Width:  style.Width,
 		Series: series,
 	}
 
 	graph.Elements = []chart.Renderable{
 		chart.LegendThin(&graph),
 	}
-	f, err := os.Create(filename)
-	if err != nil {
-		return false, fmt.Errorf("Unable to crate %s: %s", filename, err)
+	if err = settings.SaveGraph(artifact, graph); err != nil {
+		return false, err
 	}
-	defer f.Close()
-	graph.Render(chart.PNG, f)
-	log.Infof("Created %s with %d data points", filename, dataPoints)
+	log.Debugf("%s has %d data points", artifact.key(), dataPoints)
 
 	if settings.Json {
-		jdata, err := json.MarshalIndent(results, "", "  ")
-		if err != nil {
+		if err = settings.SaveJson(artifact, results); err != nil {
 			return true, err
 		}
-		ioutil.WriteFile(jsonFilename, jdata, 0644)
 	}
 	return true, nil
 }
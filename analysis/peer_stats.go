@@ -0,0 +1,228 @@
+package analysis
+
+/*
+ * Helium Analysis
+ * Copyright (c) 2021-2022 Aaron Turner  <aturner at synfin dot net>
+ *
+ * This program is free software: you can redistribute it
+ * and/or modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or with the authors permission any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Summary of the link between our hotspot and a single peer
+type PeerStats struct {
+	Address     string  `json:"address"`
+	Name        string  `json:"name"`
+	Km          float64 `json:"km"`
+	Mi          float64 `json:"mi"`
+	Bearing     float64 `json:"bearing"`
+	HasBearing  bool    `json:"has_bearing"` // false if either hotspot has no location
+	TxCount     int     `json:"tx_count"`
+	RxCount     int     `json:"rx_count"`
+	ValidCount  int     `json:"valid_count"`
+	Invalid     int     `json:"invalid_count"`
+	RssiMean    float64 `json:"rssi_mean"`
+	RssiMedian  float64 `json:"rssi_median"`
+	RssiP90     float64 `json:"rssi_p90"`
+	SnrMean     float64 `json:"snr_mean"`
+	SnrMedian   float64 `json:"snr_median"`
+	SnrP90      float64 `json:"snr_p90"`
+	LastSeen    int64   `json:"last_seen"` // nanosec
+	RewardScale float64 `json:"reward_scale"`
+	Online      string  `json:"online"`
+}
+
+// sort functions for each of the PeerStats columns
+var peerStatsSort = map[string]func(a, b PeerStats) bool{
+	"name":         func(a, b PeerStats) bool { return a.Name < b.Name },
+	"distance":     func(a, b PeerStats) bool { return a.Km < b.Km },
+	"bearing":      func(a, b PeerStats) bool { return a.Bearing < b.Bearing },
+	"tx":           func(a, b PeerStats) bool { return a.TxCount < b.TxCount },
+	"rx":           func(a, b PeerStats) bool { return a.RxCount < b.RxCount },
+	"valid":        func(a, b PeerStats) bool { return a.ValidCount < b.ValidCount },
+	"invalid":      func(a, b PeerStats) bool { return a.Invalid < b.Invalid },
+	"rssi-mean":    func(a, b PeerStats) bool { return a.RssiMean < b.RssiMean },
+	"rssi-median":  func(a, b PeerStats) bool { return a.RssiMedian < b.RssiMedian },
+	"rssi-p90":     func(a, b PeerStats) bool { return a.RssiP90 < b.RssiP90 },
+	"snr-mean":     func(a, b PeerStats) bool { return a.SnrMean < b.SnrMean },
+	"snr-median":   func(a, b PeerStats) bool { return a.SnrMedian < b.SnrMedian },
+	"snr-p90":      func(a, b PeerStats) bool { return a.SnrP90 < b.SnrP90 },
+	"last-seen":    func(a, b PeerStats) bool { return a.LastSeen < b.LastSeen },
+	"reward-scale": func(a, b PeerStats) bool { return a.RewardScale < b.RewardScale },
+	"online":       func(a, b PeerStats) bool { return a.Online < b.Online },
+}
+
+// Returns the list of valid column names for SortPeerStats()
+func PeerStatsColumns() []string {
+	cols := []string{}
+	for k := range peerStatsSort {
+		cols = append(cols, k)
+	}
+	sort.Strings(cols)
+	return cols
+}
+
+// Sorts the list of PeerStats in place by the given column
+func SortPeerStats(stats []PeerStats, column string, reverse bool) error {
+	less, ok := peerStatsSort[column]
+	if !ok {
+		return fmt.Errorf("Invalid sort column '%s'.  Valid: %s", column,
+			strings.Join(PeerStatsColumns(), ", "))
+	}
+	sort.SliceStable(stats, func(i, j int) bool {
+		if reverse {
+			return less(stats[j], stats[i])
+		}
+		return less(stats[i], stats[j])
+	})
+	return nil
+}
+
+// returns a unique list of every hotspot which witnessed us or we witnessed
+func getPeerAddresses(address string, challenges []Challenges) ([]string, error) {
+	addresses, err := GetListOfAddresses(challenges)
+	if err != nil {
+		return []string{}, err
+	}
+
+	seen := map[string]bool{address: true}
+	peers := []string{}
+	for _, peer := range addresses {
+		if !seen[peer] {
+			seen[peer] = true
+			peers = append(peers, peer)
+		}
+	}
+
+	// Challengee's of beacons we witnessed don't show up as a witness
+	for _, chal := range challenges {
+		if chal.Path == nil {
+			continue
+		}
+		p := *chal.Path
+		if !seen[p[0].Challengee] {
+			seen[p[0].Challengee] = true
+			peers = append(peers, p[0].Challengee)
+		}
+	}
+	return peers, nil
+}
+
+// Returns the witness results for every peer which has at least one result.
+// The list of peers is in the order they were first seen.
+func (b *BoltDB) getAllWitnessResults(address string, challenges []Challenges) ([]string, map[string][]WitnessResult, error) {
+	peers := []string{}
+	results := map[string][]WitnessResult{}
+
+	addresses, err := getPeerAddresses(address, challenges)
+	if err != nil {
+		return peers, results, err
+	}
+
+	for _, peer := range addresses {
+		wr, err := b.getWitnessResults(address, peer, challenges)
+		if err != nil {
+			log.WithError(err).Errorf("Unable to process: %s", peer)
+			continue
+		} else if len(wr) == 0 {
+			log.Debugf("Skipping %s <-> %s", address, peer)
+			continue
+		}
+		peers = append(peers, peer)
+		results[peer] = wr
+	}
+	return peers, results, nil
+}
+
+// Calculate the link statistics for a single peer based on the witness results
+func (b *BoltDB) calcPeerStats(peer string, wr []WitnessResult) PeerStats {
+	stats := PeerStats{
+		Address: peer,
+		Name:    peer,
+		Km:      wr[0].Km,
+		Mi:      wr[0].Mi,
+	}
+
+	rssi := []float64{}
+	snr := []float64{}
+	for _, r := range wr {
+		if r.Type == TX {
+			stats.TxCount += 1
+		} else {
+			stats.RxCount += 1
+		}
+		if r.Valid {
+			stats.ValidCount += 1
+		} else {
+			stats.Invalid += 1
+		}
+		if r.Timestamp > stats.LastSeen {
+			stats.LastSeen = r.Timestamp
+		}
+		rssi = append(rssi, float64(r.Signal))
+		snr = append(snr, r.Snr)
+	}
+	stats.RssiMean = mean(rssi)
+	stats.RssiMedian = median(rssi)
+	stats.RssiP90 = percentile(rssi, 90.0)
+	stats.SnrMean = mean(snr)
+	stats.SnrMedian = median(snr)
+	stats.SnrP90 = percentile(snr, 90.0)
+
+	name, err := b.GetHotspotName(peer)
+	if err == nil && name != "" {
+		stats.Name = name
+	}
+	host, err := b.GetHotspot(peer)
+	if err == nil {
+		stats.RewardScale = host.RewardScale
+		if host.Status != nil {
+			stats.Online = host.Status.Online
+		}
+	}
+	return stats
+}
+
+// Returns the link statistics for every peer of the given hotspot
+func (b *BoltDB) GetPeerStats(address string, challenges []Challenges) ([]PeerStats, error) {
+	ret := []PeerStats{}
+
+	aHost, err := b.GetHotspot(address)
+	if err != nil {
+		return ret, err
+	}
+
+	peers, results, err := b.getAllWitnessResults(address, challenges)
+	if err != nil {
+		return ret, err
+	}
+
+	for _, peer := range peers {
+		stats := b.calcPeerStats(peer, results[peer])
+		pHost, err := b.GetHotspot(peer)
+		if err == nil && hasLocation(aHost) && hasLocation(pHost) {
+			stats.Bearing = getBearing(aHost, pHost)
+			stats.HasBearing = true
+		}
+		ret = append(ret, stats)
+	}
+	log.Debugf("Found %d peers for %s", len(ret), address)
+	return ret, nil
+}
@@ -0,0 +1,147 @@
+package analysis
+
+/*
+ * Helium Analysis
+ * Copyright (c) 2021-2022 Aaron Turner  <aturner at synfin dot net>
+ *
+ * This program is free software: you can redistribute it
+ * and/or modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or with the authors permission any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+import (
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/wcharczuk/go-chart/v2"
+	"github.com/wcharczuk/go-chart/v2/drawing"
+)
+
+const (
+	POLAR_RINGS    = 4
+	POLAR_ARC_STEP = 2.0 // degrees per line segment when drawing arcs
+)
+
+// go-chart doesn't have a polar/radar chart so we draw our own using the
+// go-chart Renderer so it works for both PNG & SVG.
+type PolarChart struct {
+	Title      string
+	Width      int
+	Height     int
+	Labels     []string  // one per sector, clockwise from North
+	Values     []float64 // 0.0 to 1.0 of the outer ring, one per sector
+	RingLabels []string  // one per ring, inner to outer
+	Color      drawing.Color
+}
+
+// returns the x, y screen coordinates for a compass bearing & radius
+func polarPoint(cx, cy int, bearing, radius float64) (int, int) {
+	rad := (bearing - 90.0) * math.Pi / 180.0
+	return cx + int(math.Round(radius*math.Cos(rad))), cy + int(math.Round(radius*math.Sin(rad)))
+}
+
+// draws an arc from start to end bearing as a series of line segments
+func polarArc(r chart.Renderer, cx, cy int, start, end, radius float64) {
+	for deg := start; deg < end; deg += POLAR_ARC_STEP {
+		x, y := polarPoint(cx, cy, deg, radius)
+		r.LineTo(x, y)
+	}
+	x, y := polarPoint(cx, cy, end, radius)
+	r.LineTo(x, y)
+}
+
+// Render the chart using the given RendererProvider (chart.PNG or chart.SVG)
+func (pc PolarChart) Render(rp chart.RendererProvider, w io.Writer) error {
+	if len(pc.Labels) != len(pc.Values) || len(pc.Values) == 0 {
+		return fmt.Errorf("Invalid polar chart: %d labels and %d values", len(pc.Labels), len(pc.Values))
+	}
+
+	r, err := rp(pc.Width, pc.Height)
+	if err != nil {
+		return err
+	}
+	font, err := chart.GetDefaultFont()
+	if err != nil {
+		return err
+	}
+	r.SetFont(font)
+
+	// background
+	r.SetFillColor(chart.ColorWhite)
+	r.SetStrokeColor(chart.ColorWhite)
+	r.MoveTo(0, 0)
+	r.LineTo(pc.Width, 0)
+	r.LineTo(pc.Width, pc.Height)
+	r.LineTo(0, pc.Height)
+	r.Close()
+	r.FillStroke()
+
+	// title
+	r.SetFontColor(chart.ColorBlack)
+	r.SetFontSize(16.0)
+	tb := r.MeasureText(pc.Title)
+	r.Text(pc.Title, (pc.Width-tb.Width())/2, 10+tb.Height())
+
+	cx := pc.Width / 2
+	cy := (pc.Height + tb.Height() + 10) / 2
+	radius := float64(cy-tb.Height()-10) - 30.0
+	sectorWidth := 360.0 / float64(len(pc.Values))
+
+	// wedges
+	r.SetFillColor(pc.Color.WithAlpha(160))
+	r.SetStrokeColor(pc.Color)
+	r.SetStrokeWidth(1.0)
+	for i, v := range pc.Values {
+		v = math.Max(0.0, math.Min(v, 1.0))
+		if v == 0.0 {
+			continue
+		}
+		center := float64(i) * sectorWidth
+		r.MoveTo(cx, cy)
+		polarArc(r, cx, cy, center-sectorWidth/2.0, center+sectorWidth/2.0, radius*v)
+		r.Close()
+		r.FillStroke()
+	}
+
+	// rings & labels
+	r.SetStrokeColor(chart.ColorAlternateGray)
+	r.SetFontColor(chart.ColorAlternateGray)
+	r.SetFontSize(9.0)
+	for ring := 1; ring <= POLAR_RINGS; ring++ {
+		rr := radius * float64(ring) / float64(POLAR_RINGS)
+		x, y := polarPoint(cx, cy, 0.0, rr)
+		r.MoveTo(x, y)
+		polarArc(r, cx, cy, 0.0, 360.0, rr)
+		r.Stroke()
+		if ring <= len(pc.RingLabels) {
+			r.Text(pc.RingLabels[ring-1], x+3, y-3)
+		}
+	}
+
+	// spokes & sector labels
+	r.SetFontColor(chart.ColorBlack)
+	r.SetFontSize(11.0)
+	for i, label := range pc.Labels {
+		edge := float64(i)*sectorWidth - sectorWidth/2.0
+		x, y := polarPoint(cx, cy, edge, radius)
+		r.MoveTo(cx, cy)
+		r.LineTo(x, y)
+		r.Stroke()
+
+		lb := r.MeasureText(label)
+		x, y = polarPoint(cx, cy, float64(i)*sectorWidth, radius+15.0)
+		r.Text(label, x-lb.Width()/2, y+lb.Height()/2)
+	}
+
+	return r.Save(w)
+}
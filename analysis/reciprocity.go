@@ -0,0 +1,173 @@
+package analysis
+
+/*
+ * Helium Analysis
+ * Copyright (c) 2021-2022 Aaron Turner  <aturner at synfin dot net>
+ *
+ * This program is free software: you can redistribute it
+ * and/or modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or with the authors permission any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+import (
+	"math"
+	"sort"
+)
+
+/*
+ * Path loss is the same in both directions, so for a given link:
+ *
+ *   TX RSSI - RX RSSI = our TX power - their TX power (+ receiver differences)
+ *
+ * A delta that is the same for all our peers is a problem with our hotspot,
+ * while a peer whose delta differs from everyone else's is a problem with
+ * that peer.  So we report both the raw delta and the delta relative to the
+ * median of all our peers.
+ *
+ * We only see a peer's beacons when we witness them, so there is no way to
+ * know how many of their beacons we missed.  Hence the witness counts are
+ * only used to detect one-way & lopsided links and TxRate is the fraction of our own
+ * beacons the peer heard.
+ */
+
+type ReciprocityStats struct {
+	Address       string   `json:"address"`
+	Name          string   `json:"name"`
+	Km            float64  `json:"km"`
+	TxCount       int      `json:"tx_count"`       // they heard us
+	RxCount       int      `json:"rx_count"`       // we heard them
+	TxRate        float64  `json:"tx_rate"`        // fraction of our beacons they heard
+	TxRssi        float64  `json:"tx_rssi"`        // median RSSI they heard us at
+	RxRssi        float64  `json:"rx_rssi"`        // median RSSI we heard them at
+	RssiDelta     float64  `json:"rssi_delta"`     // TxRssi - RxRssi
+	RelativeDelta float64  `json:"relative_delta"` // RssiDelta - median RssiDelta of all peers
+	Flags         []string `json:"flags"`
+}
+
+type ReciprocityReport struct {
+	Address     string             `json:"address"`
+	Peers       []ReciprocityStats `json:"peers"`
+	MedianDelta float64            `json:"median_delta"` // median RssiDelta of all two-way peers
+	TwoWayPeers int                `json:"two_way_peers"`
+	Flagged     bool               `json:"flagged"` // true if MedianDelta exceeds the threshold
+}
+
+// returns true if we have enough data in both directions to compare RSSI
+func (r ReciprocityStats) twoWay(minimum int) bool {
+	return r.TxCount >= minimum && r.RxCount >= minimum
+}
+
+// returns the flag for a link which is one-way or where one direction has
+// more than ratio times the witnesses of the other
+func (r ReciprocityStats) countFlag(minimum int, ratio float64) string {
+	switch {
+	case r.TxCount >= minimum && r.RxCount == 0:
+		return "hears us only"
+	case r.RxCount >= minimum && r.TxCount == 0:
+		return "we hear only"
+	case r.TxCount >= minimum && float64(r.TxCount) > ratio*float64(r.RxCount):
+		return "hears us mostly"
+	case r.RxCount >= minimum && float64(r.RxCount) > ratio*float64(r.TxCount):
+		return "we hear mostly"
+	}
+	return ""
+}
+
+// Compare how often & how loudly each peer hears us vs. we hear them.
+// Links with less than minimum witnesses in a direction are not compared
+// and links with a relative RSSI delta larger than thresholdDb are flagged.
+// Links where one direction has more than ratio times the witnesses of the
+// other are flagged as lopsided.
+func (b *BoltDB) GetReciprocity(address string, challenges []Challenges, minimum int, thresholdDb, ratio float64) (ReciprocityReport, error) {
+	report := ReciprocityReport{
+		Address: address,
+		Peers:   []ReciprocityStats{},
+	}
+
+	peers, results, err := b.getAllWitnessResults(address, challenges)
+	if err != nil {
+		return report, err
+	}
+
+	beacons := 0
+	for _, challenge := range challenges {
+		if challenge.Path != nil && (*challenge.Path)[0].Challengee == address {
+			beacons += 1
+		}
+	}
+
+	deltas := []float64{}
+	for _, peer := range peers {
+		r := ReciprocityStats{
+			Address: peer,
+			Name:    peer,
+			Km:      results[peer][0].Km,
+			Flags:   []string{},
+		}
+		if name, err := b.GetHotspotName(peer); err == nil && name != "" {
+			r.Name = name
+		}
+
+		tx := []float64{}
+		rx := []float64{}
+		for _, wr := range results[peer] {
+			if wr.Type == TX {
+				tx = append(tx, float64(wr.Signal))
+			} else {
+				rx = append(rx, float64(wr.Signal))
+			}
+		}
+		r.TxCount = len(tx)
+		r.RxCount = len(rx)
+		if beacons > 0 {
+			r.TxRate = float64(r.TxCount) / float64(beacons)
+		}
+		if r.TxCount > 0 {
+			r.TxRssi = median(tx)
+		}
+		if r.RxCount > 0 {
+			r.RxRssi = median(rx)
+		}
+
+		if r.twoWay(minimum) {
+			r.RssiDelta = r.TxRssi - r.RxRssi
+			deltas = append(deltas, r.RssiDelta)
+		}
+		if flag := r.countFlag(minimum, ratio); flag != "" {
+			r.Flags = append(r.Flags, flag)
+		}
+		report.Peers = append(report.Peers, r)
+	}
+
+	report.TwoWayPeers = len(deltas)
+	if len(deltas) > 0 {
+		report.MedianDelta = median(deltas)
+		report.Flagged = math.Abs(report.MedianDelta) > thresholdDb
+	}
+
+	for i := range report.Peers {
+		r := &report.Peers[i]
+		if !r.twoWay(minimum) {
+			continue
+		}
+		r.RelativeDelta = r.RssiDelta - report.MedianDelta
+		if math.Abs(r.RelativeDelta) > thresholdDb {
+			r.Flags = append(r.Flags, "rssi")
+		}
+	}
+
+	// most asymmetric first
+	sort.SliceStable(report.Peers, func(i, j int) bool {
+		return math.Abs(report.Peers[i].RelativeDelta) > math.Abs(report.Peers[j].RelativeDelta)
+	})
+	return report, nil
+}
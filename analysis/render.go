@@ -0,0 +1,156 @@
+package analysis
+
+/*
+ * Helium Analysis
+ * Copyright (c) 2021-2022 Aaron Turner  <aturner at synfin dot net>
+ *
+ * This program is free software: you can redistribute it
+ * and/or modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or with the authors permission any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/wcharczuk/go-chart/v2"
+)
+
+const (
+	FORMAT_PNG = "png"
+	FORMAT_SVG = "svg"
+	FORMAT_PDF = "pdf"
+)
+
+var GRAPH_FORMATS []string = []string{FORMAT_PNG, FORMAT_SVG, FORMAT_PDF}
+
+// Anything go-chart can draw: chart.Chart, chart.BarChart, PolarChart, Heatmap, etc
+type Renderable interface {
+	Render(rp chart.RendererProvider, w io.Writer) error
+}
+
+// Returns an error if any of the formats are not in GRAPH_FORMATS
+func ValidateGraphFormats(formats []string) error {
+	for _, format := range formats {
+		valid := false
+		for _, f := range GRAPH_FORMATS {
+			if format == f {
+				valid = true
+			}
+		}
+		if !valid {
+			return fmt.Errorf("Invalid graph format '%s'.  Valid: %s", format,
+				strings.Join(GRAPH_FORMATS, ", "))
+		}
+	}
+	return nil
+}
+
+// returns the list of formats to generate, defaulting to PNG
+func (s GraphSettings) formats() []string {
+	if len(s.Formats) == 0 {
+		return []string{FORMAT_PNG}
+	}
+	return s.Formats
+}
+
+// Rendered PNGs kept in memory instead of written to files
+type GraphCollection struct {
+	graphs map[string][]byte // basename => PNG
+}
+
+func NewGraphCollection() *GraphCollection {
+	return &GraphCollection{
+		graphs: map[string][]byte{},
+	}
+}
+
+// Returns the PNG for the given basename
+func (c *GraphCollection) Get(basename string) ([]byte, bool) {
+	png, ok := c.graphs[basename]
+	return png, ok
+}
+
+// returns where to write files, defaulting to the current directory
+func (s GraphSettings) output() *Output {
+	if s.Output == nil {
+		o, _ := NewOutput(".", DEFAULT_FILE_TEMPLATE, DEFAULT_PEER_TEMPLATE)
+		return o
+	}
+	return s.Output
+}
+
+// Save the graph in each of the formats in the settings.  The file names come
+// from settings.Output.  PDF graphs are added as a new page to settings.Pdf
+// which must be written out by the caller.  If settings.Collection is set, a
+// PNG is added to it and no files are written.
+func (s GraphSettings) SaveGraph(a Artifact, graph Renderable) error {
+	if a.Type == "" {
+		a.Type = ARTIFACT_GRAPH
+	}
+	if s.Collection != nil {
+		buf := bytes.Buffer{}
+		if err := graph.Render(chart.PNG, &buf); err != nil {
+			return fmt.Errorf("Unable to render %s: %s", a.key(), err)
+		}
+		s.Collection.graphs[a.key()] = buf.Bytes()
+		return nil
+	}
+
+	for _, format := range s.formats() {
+		var rp chart.RendererProvider
+		switch format {
+		case FORMAT_PNG:
+			rp = chart.PNG
+		case FORMAT_SVG:
+			rp = chart.SVG
+		case FORMAT_PDF:
+			if s.Pdf == nil {
+				return fmt.Errorf("Unable to save %s: no PDF document", a.key())
+			}
+			if err := s.Pdf.AddGraph(a.key(), graph); err != nil {
+				return err
+			}
+			continue
+		default:
+			return fmt.Errorf("Invalid graph format: %s", format)
+		}
+
+		a.Format = format
+		f, err := s.output().Create(a)
+		if err != nil {
+			return err
+		}
+		err = graph.Render(rp, f)
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("Unable to render %s: %s", f.Name(), err)
+		}
+		log.Infof("Created %s", f.Name())
+	}
+	return nil
+}
+
+// Save the data as an indented JSON file
+func (s GraphSettings) SaveJson(a Artifact, data interface{}) error {
+	jdata, err := json.MarshalIndent(data, "", "  ")
+	if err != nil {
+		return err
+	}
+	a.Type = ARTIFACT_JSON
+	a.Format = "json"
+	return s.output().WriteFile(a, jdata)
+}
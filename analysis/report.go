@@ -0,0 +1,257 @@
+package analysis
+
+/*
+ * Helium Analysis
+ * Copyright (c) 2021-2022 Aaron Turner  <aturner at synfin dot net>
+ *
+ * This program is free software: you can redistribute it
+ * and/or modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or with the authors permission any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+import (
+	"encoding/base64"
+	"fmt"
+	"html/template"
+	"io"
+	"sort"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// A single graph inlined in the report
+type ReportGraph struct {
+	Id    string
+	Title string
+	Src   template.URL // data: URL of the PNG
+}
+
+// A row of the peer table
+type ReportPeer struct {
+	PeerStats
+	LastSeen string
+	Graph    string // id of the peer graph or empty if there is none
+}
+
+// Everything in the HTML report
+type HotspotReport struct {
+	Hotspot    Hotspot
+	Generated  string
+	First      string
+	Last       string
+	Challenges int
+	Beacons    int
+	TxCount    int // witnesses of our beacons
+	RxCount    int // beacons we witnessed
+	ValidRatio float64
+	Cadence    *BeaconCadence
+	Graphs     []ReportGraph // beacon & witness graphs
+	Peers      []ReportPeer
+	PeerGraphs []ReportGraph
+}
+
+func (r HotspotReport) ValidPercent() float64 {
+	return r.ValidRatio * 100.0
+}
+
+// returns the graph for the given basename as a data: URL
+func reportGraph(c *GraphCollection, basename, id, title string) (ReportGraph, bool) {
+	png, ok := c.Get(basename)
+	if !ok {
+		return ReportGraph{}, false
+	}
+	return ReportGraph{
+		Id:    id,
+		Title: title,
+		Src:   template.URL("data:image/png;base64," + base64.StdEncoding.EncodeToString(png)),
+	}, true
+}
+
+// Write a self contained HTML report with summary stats, the beacon & witness
+// graphs and a sortable peer table linking to each of the peer graphs.
+// Challenges should be between first & last.
+func (b *BoltDB) GenerateReport(address string, challenges []Challenges, first, last time.Time, settings GraphSettings, w io.Writer) error {
+	host, err := b.GetHotspot(address)
+	if err != nil {
+		return err
+	}
+	hotspotName, err := b.GetHotspotName(address)
+	if err != nil {
+		return err
+	}
+
+	report := HotspotReport{
+		Hotspot:    host,
+		Generated:  FormatTime(time.Now()),
+		First:      FormatTime(first),
+		Last:       FormatTime(last),
+		Challenges: len(challenges),
+		Beacons:    len(beaconTimes(address, challenges)),
+		Graphs:     []ReportGraph{},
+		Peers:      []ReportPeer{},
+		PeerGraphs: []ReportGraph{},
+	}
+
+	cadence, err := GetBeaconCadence(address, challenges, last, NETWORK_BEACON_INTERVAL)
+	if err == nil {
+		report.Cadence = &cadence
+	}
+
+	// render the graphs in memory
+	settings.Collection = NewGraphCollection()
+	settings.Json = false
+	if err = b.GenerateBeaconsGraph(address, challenges, settings); err != nil {
+		log.WithError(err).Warn("Unable to generate beacons graph")
+	}
+	if err = b.GenerateWitnessesGraph(address, challenges, settings); err != nil {
+		log.WithError(err).Warn("Unable to generate witnesses graph")
+	}
+	if err = b.GeneratePeerGraphs(address, challenges, settings); err != nil {
+		log.WithError(err).Warn("Unable to generate peer graphs")
+	}
+
+	for _, g := range []struct{ basename, title string }{
+		{"beacon-totals", "Beacons"},
+		{"witness-distance", "Witnesses"},
+	} {
+		if graph, ok := reportGraph(settings.Collection, fmt.Sprintf("%s/%s", hotspotName, g.basename), g.basename, g.title); ok {
+			report.Graphs = append(report.Graphs, graph)
+		}
+	}
+
+	stats, err := b.GetPeerStats(address, challenges)
+	if err != nil {
+		return err
+	}
+	valid := 0
+	for _, s := range stats {
+		report.TxCount += s.TxCount
+		report.RxCount += s.RxCount
+		valid += s.ValidCount
+	}
+	if report.TxCount+report.RxCount > 0 {
+		report.ValidRatio = float64(valid) / float64(report.TxCount+report.RxCount)
+	}
+
+	sort.SliceStable(stats, func(i, j int) bool {
+		return stats[i].Km < stats[j].Km
+	})
+	for i, s := range stats {
+		p := ReportPeer{
+			PeerStats: s,
+			LastSeen:  FormatTime(time.Unix(0, s.LastSeen)),
+		}
+		id := fmt.Sprintf("peer-%d", i)
+		if graph, ok := reportGraph(settings.Collection, fmt.Sprintf("%s/%s", hotspotName, s.Name), id, s.Name); ok {
+			p.Graph = id
+			report.PeerGraphs = append(report.PeerGraphs, graph)
+		}
+		report.Peers = append(report.Peers, p)
+	}
+
+	tmpl, err := template.New("report").Parse(REPORT_TEMPLATE)
+	if err != nil {
+		return err
+	}
+	return tmpl.Execute(w, report)
+}
+
+const REPORT_TEMPLATE = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Helium Analysis: {{ .Hotspot.Name }}</title>
+<style>
+body { font-family: Helvetica, Arial, sans-serif; margin: 2em; color: #222; }
+table { border-collapse: collapse; margin-bottom: 2em; }
+th, td { border: 1px solid #ccc; padding: 4px 8px; text-align: right; }
+th { background: #eee; cursor: pointer; }
+td:first-child, th:first-child { text-align: left; }
+img { max-width: 100%; }
+.summary td { text-align: left; }
+</style>
+</head>
+<body>
+<h1>{{ .Hotspot.Name }}</h1>
+<table class="summary">
+<tr><td>Address</td><td>{{ .Hotspot.Address }}</td></tr>
+<tr><td>Owner</td><td>{{ .Hotspot.Owner }}</td></tr>
+<tr><td>Location</td><td>{{ printf "%.5f, %.5f" .Hotspot.Lat .Hotspot.Lng }}</td></tr>
+<tr><td>Reward Scale</td><td>{{ printf "%.2f" .Hotspot.RewardScale }}</td></tr>
+{{- if .Hotspot.Status }}
+<tr><td>Status</td><td>{{ .Hotspot.Status.Online }}</td></tr>
+{{- end }}
+<tr><td>Time Range</td><td>{{ .First }} - {{ .Last }}</td></tr>
+<tr><td>Challenges</td><td>{{ .Challenges }}</td></tr>
+<tr><td>Beacons</td><td>{{ .Beacons }}</td></tr>
+{{- with .Cadence }}
+<tr><td>Median Beacon Interval</td><td>{{ printf "%.1fh" .MedianInterval }}</td></tr>
+{{- end }}
+<tr><td>Peers</td><td>{{ len .Peers }}</td></tr>
+<tr><td>Witnesses of our Beacons</td><td>{{ .TxCount }}</td></tr>
+<tr><td>Beacons we Witnessed</td><td>{{ .RxCount }}</td></tr>
+<tr><td>Valid Witnesses</td><td>{{ printf "%.1f%%" .ValidPercent }}</td></tr>
+</table>
+
+{{- range .Graphs }}
+<h2 id="{{ .Id }}">{{ .Title }}</h2>
+<img src="{{ .Src }}" alt="{{ .Title }}">
+{{- end }}
+
+<h2>Peers</h2>
+<table id="peers">
+<thead><tr>
+<th>Name</th><th>Km</th><th>Bearing</th><th>TX</th><th>RX</th><th>Valid</th><th>Invalid</th>
+<th>RSSI Median</th><th>SNR Median</th><th>Last Seen</th><th>Status</th>
+</tr></thead>
+<tbody>
+{{- range .Peers }}
+<tr>
+<td>{{ if .Graph }}<a href="#{{ .Graph }}">{{ .Name }}</a>{{ else }}{{ .Name }}{{ end }}</td>
+<td>{{ printf "%.2f" .Km }}</td><td>{{ if .HasBearing }}{{ printf "%.0f" .Bearing }}{{ else }}n/a{{ end }}</td>
+<td>{{ .TxCount }}</td><td>{{ .RxCount }}</td><td>{{ .ValidCount }}</td><td>{{ .Invalid }}</td>
+<td>{{ printf "%.1f" .RssiMedian }}</td><td>{{ printf "%.1f" .SnrMedian }}</td>
+<td>{{ .LastSeen }}</td><td>{{ .Online }}</td>
+</tr>
+{{- end }}
+</tbody>
+</table>
+
+{{- range .PeerGraphs }}
+<h3 id="{{ .Id }}">{{ .Title }}</h3>
+<img src="{{ .Src }}" alt="{{ .Title }}">
+<p><a href="#peers">Back to peers</a></p>
+{{- end }}
+
+<p>Generated by helium-analysis at {{ .Generated }}</p>
+<script>
+// click a column header to sort by it, click again to reverse
+document.querySelectorAll("#peers th").forEach(function(th, col) {
+	th.addEventListener("click", function() {
+		var tbody = document.querySelector("#peers tbody");
+		var rows = Array.prototype.slice.call(tbody.rows);
+		var asc = th.dataset.order !== "asc";
+		th.dataset.order = asc ? "asc" : "desc";
+		rows.sort(function(a, b) {
+			var x = a.cells[col].innerText, y = b.cells[col].innerText;
+			var nx = parseFloat(x), ny = parseFloat(y);
+			var cmp = (!isNaN(nx) && !isNaN(ny)) ? nx - ny : x.localeCompare(y);
+			return asc ? cmp : -cmp;
+		});
+		rows.forEach(function(row) { tbody.appendChild(row); });
+	});
+});
+</script>
+</body>
+</html>
+`
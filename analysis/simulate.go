@@ -0,0 +1,191 @@
+package analysis
+
+/*
+ * Helium Analysis
+ * Copyright (c) 2021-2022 Aaron Turner  <aturner at synfin dot net>
+ *
+ * This program is free software: you can redistribute it
+ * and/or modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or with the authors permission any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+/*
+ * Predicts the links of a hotspot at a candidate location using the path
+ * loss model fitted to its current witnesses.  Since the fitted model already
+ * includes the current antenna gain & height, only the change is applied:
+ *
+ *   RSSI = model.Rssi(km) + (gain - current gain) + 20 * log10(height / current height)
+ *
+ * The height term is the two-ray ground reflection approximation and is
+ * skipped if either height is unknown.  Antenna gain & height apply equally
+ * to both directions of the link.
+ */
+
+const (
+	SIMULATE_SENSITIVITY = -130.0 // dBm, weakest RSSI we expect to be heard
+)
+
+// Candidate location & antenna of the hotspot
+type SimulateSettings struct {
+	Lat           float64
+	Lng           float64
+	Gain          float64 // dBi
+	Height        float64 // meters above ground, 0 = unknown
+	CurrentGain   float64 // dBi
+	CurrentHeight float64 // meters above ground, 0 = unknown
+	Sensitivity   float64 // dBm
+}
+
+// Predicted link with an existing hotspot
+type SimulatedPeer struct {
+	Address   string   `json:"address"`
+	Name      string   `json:"name"`
+	Km        float64  `json:"km"`
+	Bearing   float64  `json:"bearing"`
+	TxRssi    float64  `json:"tx_rssi"`        // predicted RSSI they hear us at
+	TxChance  float64  `json:"tx_probability"` // probability they hear us
+	RxRssi    float64  `json:"rx_rssi"`        // predicted RSSI we hear them at
+	RxChance  float64  `json:"rx_probability"` // probability we hear them
+	MaxRssi   float64  `json:"max_rssi"`       // strongest valid RSSI at this distance
+	TooStrong bool     `json:"too_strong"`
+	TooClose  bool     `json:"too_close"`
+	Flags     []string `json:"flags"`
+}
+
+type SimulateReport struct {
+	Address    string          `json:"address"`
+	Lat        float64         `json:"lat"`
+	Lng        float64         `json:"lng"`
+	Adjustment float64         `json:"adjustment"` // dB applied to the fitted models
+	TxModel    PathLossModel   `json:"tx_model"`
+	RxModel    PathLossModel   `json:"rx_model"`
+	Peers      []SimulatedPeer `json:"peers"`
+	TxPeers    int             `json:"tx_peers"` // number likely to hear us
+	RxPeers    int             `json:"rx_peers"` // number we likely hear
+}
+
+// returns the probability the actual RSSI is >= sensitivity assuming the
+// residuals of the model are normally distributed
+func heardProbability(rssi, stddev, sensitivity float64) float64 {
+	if stddev <= 0.0 {
+		if rssi >= sensitivity {
+			return 1.0
+		}
+		return 0.0
+	}
+	return 0.5 * math.Erfc((sensitivity-rssi)/(stddev*math.Sqrt2))
+}
+
+// returns the model for the given direction or the combined model if
+// there isn't enough data for that direction
+func pickModel(models []PathLossModel, direction string) PathLossModel {
+	for _, m := range models {
+		if m.Direction == direction {
+			return m
+		}
+	}
+	return models[0]
+}
+
+// Predict which hotspots would hear us & we would hear if the hotspot was
+// moved to the candidate location & antenna.  Only hotspots with at least
+// minProbability of hearing or being heard are returned.
+func (b *BoltDB) Simulate(address string, challenges []Challenges, settings SimulateSettings, minProbability float64) (SimulateReport, error) {
+	report := SimulateReport{
+		Address: address,
+		Lat:     settings.Lat,
+		Lng:     settings.Lng,
+		Peers:   []SimulatedPeer{},
+	}
+	if settings.Height < 0.0 || settings.CurrentHeight < 0.0 {
+		return report, fmt.Errorf("Antenna height must be >= 0")
+	}
+
+	models, err := b.GetPathLoss(address, challenges)
+	if err != nil {
+		return report, fmt.Errorf("Unable to fit path loss model for %s: %s", address, err)
+	}
+	report.TxModel = pickModel(models, "tx")
+	report.RxModel = pickModel(models, "rx")
+
+	report.Adjustment = settings.Gain - settings.CurrentGain
+	if settings.Height > 0.0 && settings.CurrentHeight > 0.0 {
+		report.Adjustment += 20.0 * math.Log10(settings.Height/settings.CurrentHeight)
+	}
+
+	hotspots, err := b.GetHotspots()
+	if err != nil {
+		return report, err
+	}
+	candidate := Hotspot{
+		Address: address,
+		Lat:     settings.Lat,
+		Lng:     settings.Lng,
+	}
+
+	for _, h := range hotspots {
+		if h.Address == address || !hasLocation(h) {
+			continue
+		} else if h.Status != nil && h.Status.Online == "offline" {
+			continue
+		}
+
+		km, _, _ := getDistance(candidate, h)
+		// the model is undefined at 0km
+		km = math.Max(km, PATH_LOSS_MIN_KM)
+		p := SimulatedPeer{
+			Address: h.Address,
+			Name:    h.Name,
+			Km:      km,
+			Bearing: getBearing(candidate, h),
+			TxRssi:  report.TxModel.Rssi(km) + report.Adjustment,
+			RxRssi:  report.RxModel.Rssi(km) + report.Adjustment,
+			MaxRssi: maxRssi(km),
+			Flags:   []string{},
+		}
+		if p.Name == "" {
+			p.Name = h.Address
+		}
+		p.TxChance = heardProbability(p.TxRssi, report.TxModel.StdDev, settings.Sensitivity)
+		p.RxChance = heardProbability(p.RxRssi, report.RxModel.StdDev, settings.Sensitivity)
+		if p.TxChance < minProbability && p.RxChance < minProbability {
+			continue
+		}
+
+		if km < MIN_WITNESS_DISTANCE {
+			p.TooClose = true
+			p.Flags = append(p.Flags, REASON_TOO_CLOSE.String())
+		}
+		if p.TxRssi > p.MaxRssi || p.RxRssi > p.MaxRssi {
+			p.TooStrong = true
+			p.Flags = append(p.Flags, REASON_TOO_STRONG.String())
+		}
+		if p.TxChance >= 0.5 {
+			report.TxPeers += 1
+		}
+		if p.RxChance >= 0.5 {
+			report.RxPeers += 1
+		}
+		report.Peers = append(report.Peers, p)
+	}
+
+	sort.SliceStable(report.Peers, func(i, j int) bool {
+		return report.Peers[i].Km < report.Peers[j].Km
+	})
+	return report, nil
+}
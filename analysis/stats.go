@@ -0,0 +1,222 @@
+package analysis
+
+/*
+ * Helium Analysis
+ * Copyright (c) 2021-2022 Aaron Turner  <aturner at synfin dot net>
+ *
+ * This program is free software: you can redistribute it
+ * and/or modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or with the authors permission any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// returns the arithmetic mean or 0.0 for an empty list
+func mean(vals []float64) float64 {
+	if len(vals) == 0 {
+		return 0.0
+	}
+	sum := 0.0
+	for _, v := range vals {
+		sum += v
+	}
+	return sum / float64(len(vals))
+}
+
+// returns the median or 0.0 for an empty list
+func median(vals []float64) float64 {
+	return percentile(vals, 50.0)
+}
+
+// returns the p'th percentile (0-100) using linear interpolation
+func percentile(vals []float64, p float64) float64 {
+	if len(vals) == 0 {
+		return 0.0
+	}
+	sorted := make([]float64, len(vals))
+	copy(sorted, vals)
+	sort.Float64s(sorted)
+
+	rank := (p / 100.0) * float64(len(sorted)-1)
+	low := int(math.Floor(rank))
+	high := int(math.Ceil(rank))
+	if low == high {
+		return sorted[low]
+	}
+	return sorted[low] + (sorted[high]-sorted[low])*(rank-float64(low))
+}
+
+// returns the sample standard deviation or 0.0 for lists with < 2 values
+func stddev(vals []float64) float64 {
+	if len(vals) < 2 {
+		return 0.0
+	}
+	m := mean(vals)
+	sum := 0.0
+	for _, v := range vals {
+		sum += (v - m) * (v - m)
+	}
+	return math.Sqrt(sum / float64(len(vals)-1))
+}
+
+// CUSUM change point estimate.  Returns the index of the first value after
+// the most likely shift in the mean, which is where the cumulative sum of the
+// deviations from the mean is furthest from zero.  Returns 0 if the list has
+// < 2 values or is constant.
+func cusumChangePoint(vals []float64) int {
+	if len(vals) < 2 {
+		return 0
+	}
+	m := mean(vals)
+	sum := 0.0
+	best := 0.0
+	idx := 0
+	for i := 0; i < len(vals)-1; i++ {
+		sum += vals[i] - m
+		if math.Abs(sum) > best {
+			best = math.Abs(sum)
+			idx = i + 1
+		}
+	}
+	return idx
+}
+
+// Ordinary least squares fit of y = intercept + slope * x.  Returns the
+// slope, intercept and coefficient of determination (R^2)
+func linearRegression(x, y []float64) (float64, float64, float64, error) {
+	if len(x) != len(y) {
+		return 0.0, 0.0, 0.0, fmt.Errorf("x and y must be the same length")
+	}
+	if len(x) < 2 {
+		return 0.0, 0.0, 0.0, fmt.Errorf("Need at least 2 data points, only have %d", len(x))
+	}
+
+	xMean := mean(x)
+	yMean := mean(y)
+	sxx := 0.0
+	sxy := 0.0
+	syy := 0.0
+	for i := range x {
+		sxx += (x[i] - xMean) * (x[i] - xMean)
+		sxy += (x[i] - xMean) * (y[i] - yMean)
+		syy += (y[i] - yMean) * (y[i] - yMean)
+	}
+	if sxx == 0.0 {
+		return 0.0, 0.0, 0.0, fmt.Errorf("Unable to fit: all x values are identical")
+	}
+
+	slope := sxy / sxx
+	intercept := yMean - slope*xMean
+	r2 := 1.0
+	if syy > 0.0 {
+		r2 = (sxy * sxy) / (sxx * syy)
+	}
+	return slope, intercept, r2, nil
+}
+
+// Welch's two sample t-test.  Returns the t statistic, degrees of freedom and
+// the two-sided p-value.  Returns a p-value of NaN if there is not enough data.
+func welchTTest(a, b []float64) (float64, float64, float64) {
+	if len(a) < 2 || len(b) < 2 {
+		return 0.0, 0.0, math.NaN()
+	}
+	na := float64(len(a))
+	nb := float64(len(b))
+	va := math.Pow(stddev(a), 2) / na
+	vb := math.Pow(stddev(b), 2) / nb
+	if va+vb == 0.0 {
+		if mean(a) == mean(b) {
+			return 0.0, na + nb - 2, 1.0
+		}
+		return math.Inf(1), na + nb - 2, 0.0
+	}
+
+	t := (mean(a) - mean(b)) / math.Sqrt(va+vb)
+	df := math.Pow(va+vb, 2) / ((va*va)/(na-1) + (vb*vb)/(nb-1))
+	p := incompleteBeta(df/(df+t*t), df/2.0, 0.5)
+	return t, df, p
+}
+
+// Regularized incomplete beta function I_x(a, b).
+// Based on the continued fraction in Numerical Recipes 6.4
+func incompleteBeta(x, a, b float64) float64 {
+	if x <= 0.0 {
+		return 0.0
+	} else if x >= 1.0 {
+		return 1.0
+	}
+
+	lga, _ := math.Lgamma(a)
+	lgb, _ := math.Lgamma(b)
+	lgab, _ := math.Lgamma(a + b)
+	front := math.Exp(lgab - lga - lgb + a*math.Log(x) + b*math.Log(1.0-x))
+
+	// use the symmetry relation to keep the continued fraction converging quickly
+	if x > (a+1.0)/(a+b+2.0) {
+		return 1.0 - front*betaContinuedFraction(1.0-x, b, a)/b
+	}
+	return front * betaContinuedFraction(x, a, b) / a
+}
+
+func betaContinuedFraction(x, a, b float64) float64 {
+	const maxIterations = 300
+	const epsilon = 3.0e-14
+	const tiny = 1.0e-300
+
+	qab := a + b
+	qap := a + 1.0
+	qam := a - 1.0
+	c := 1.0
+	d := 1.0 - qab*x/qap
+	if math.Abs(d) < tiny {
+		d = tiny
+	}
+	d = 1.0 / d
+	h := d
+
+	for m := 1; m <= maxIterations; m++ {
+		fm := float64(m)
+		m2 := 2.0 * fm
+		aa := fm * (b - fm) * x / ((qam + m2) * (a + m2))
+		d = 1.0 + aa*d
+		if math.Abs(d) < tiny {
+			d = tiny
+		}
+		c = 1.0 + aa/c
+		if math.Abs(c) < tiny {
+			c = tiny
+		}
+		d = 1.0 / d
+		h *= d * c
+
+		aa = -(a + fm) * (qab + fm) * x / ((a + m2) * (qap + m2))
+		d = 1.0 + aa*d
+		if math.Abs(d) < tiny {
+			d = tiny
+		}
+		c = 1.0 + aa/c
+		if math.Abs(c) < tiny {
+			c = tiny
+		}
+		d = 1.0 / d
+		del := d * c
+		h *= del
+		if math.Abs(del-1.0) < epsilon {
+			break
+		}
+	}
+	return h
+}
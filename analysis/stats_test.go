@@ -0,0 +1,194 @@
+package analysis
+
+/*
+ * Helium Analysis
+ * Copyright (c) 2021-2022 Aaron Turner  <aturner at synfin dot net>
+ *
+ * This program is free software: you can redistribute it
+ * and/or modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or with the authors permission any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+import (
+	"math"
+	"testing"
+)
+
+// returns true if a & b are equal within tolerance
+func near(a, b, tolerance float64) bool {
+	return math.Abs(a-b) <= tolerance
+}
+
+func TestMeanMedianPercentile(t *testing.T) {
+	tests := []struct {
+		vals   []float64
+		mean   float64
+		median float64
+		p90    float64
+	}{
+		{[]float64{}, 0.0, 0.0, 0.0},
+		{[]float64{5.0}, 5.0, 5.0, 5.0},
+		{[]float64{3.0, 1.0, 2.0}, 2.0, 2.0, 2.8},
+		{[]float64{-90, -100, -80, -110}, -95.0, -95.0, -83.0},
+		{[]float64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}, 5.5, 5.5, 9.1},
+	}
+	for _, test := range tests {
+		if got := mean(test.vals); !near(got, test.mean, 1e-9) {
+			t.Errorf("mean(%v) = %g, expected %g", test.vals, got, test.mean)
+		}
+		if got := median(test.vals); !near(got, test.median, 1e-9) {
+			t.Errorf("median(%v) = %g, expected %g", test.vals, got, test.median)
+		}
+		if got := percentile(test.vals, 90.0); !near(got, test.p90, 1e-9) {
+			t.Errorf("percentile(%v, 90) = %g, expected %g", test.vals, got, test.p90)
+		}
+	}
+
+	// must not sort the callers slice
+	vals := []float64{3.0, 1.0, 2.0}
+	percentile(vals, 50.0)
+	if vals[0] != 3.0 {
+		t.Errorf("percentile sorted the input: %v", vals)
+	}
+}
+
+func TestStddev(t *testing.T) {
+	tests := []struct {
+		vals   []float64
+		stddev float64
+	}{
+		{[]float64{}, 0.0},
+		{[]float64{4.0}, 0.0},
+		{[]float64{1.0, 1.0, 1.0}, 0.0},
+		{[]float64{2, 4, 4, 4, 5, 5, 7, 9}, math.Sqrt(32.0 / 7.0)},
+	}
+	for _, test := range tests {
+		if got := stddev(test.vals); !near(got, test.stddev, 1e-9) {
+			t.Errorf("stddev(%v) = %g, expected %g", test.vals, got, test.stddev)
+		}
+	}
+}
+
+func TestLinearRegression(t *testing.T) {
+	tests := []struct {
+		x, y      []float64
+		slope     float64
+		intercept float64
+		r2        float64
+		err       bool
+	}{
+		{[]float64{1, 2, 3, 4, 5}, []float64{2, 4, 5, 4, 5}, 0.6, 2.2, 0.6, false},
+		{[]float64{0, 1, 2}, []float64{-60, -90, -120}, -30.0, -60.0, 1.0, false},
+		{[]float64{0, 1, 2}, []float64{5, 5, 5}, 0.0, 5.0, 1.0, false},
+		{[]float64{1, 1, 1}, []float64{1, 2, 3}, 0.0, 0.0, 0.0, true},
+		{[]float64{1}, []float64{1}, 0.0, 0.0, 0.0, true},
+		{[]float64{1, 2}, []float64{1}, 0.0, 0.0, 0.0, true},
+	}
+	for _, test := range tests {
+		slope, intercept, r2, err := linearRegression(test.x, test.y)
+		if (err != nil) != test.err {
+			t.Errorf("linearRegression(%v, %v) error: %v", test.x, test.y, err)
+			continue
+		} else if test.err {
+			continue
+		}
+		if !near(slope, test.slope, 1e-9) || !near(intercept, test.intercept, 1e-9) || !near(r2, test.r2, 1e-9) {
+			t.Errorf("linearRegression(%v, %v) = %g, %g, %g, expected %g, %g, %g", test.x, test.y,
+				slope, intercept, r2, test.slope, test.intercept, test.r2)
+		}
+	}
+}
+
+func TestIncompleteBeta(t *testing.T) {
+	tests := []struct {
+		x, a, b  float64
+		expected float64
+	}{
+		{0.0, 2.0, 3.0, 0.0},
+		{1.0, 2.0, 3.0, 1.0},
+		{0.25, 1.0, 1.0, 0.25},          // I_x(1, 1) = x
+		{0.5, 3.0, 1.0, 0.125},          // I_x(a, 1) = x^a
+		{0.5, 1.0, 3.0, 0.875},          // I_x(1, b) = 1 - (1 - x)^b
+		{0.5, 4.5, 4.5, 0.5},            // symmetric
+		{0.3, 2.0, 3.0, 0.3483},         // 6x^2 - 8x^3 + 3x^4
+		{0.7, 5.0, 2.5, 0.541003383307}, // numerical integration
+	}
+	for _, test := range tests {
+		if got := incompleteBeta(test.x, test.a, test.b); !near(got, test.expected, 1e-8) {
+			t.Errorf("incompleteBeta(%g, %g, %g) = %.10f, expected %.10f",
+				test.x, test.a, test.b, got, test.expected)
+		}
+	}
+}
+
+func TestWelchTTest(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b []float64
+		t    float64
+		df   float64
+		p    float64
+	}{
+		{
+			// example from the Welch's t-test Wikipedia article
+			"wikipedia",
+			[]float64{27.5, 21.0, 19.0, 23.6, 17.0, 17.9, 16.9, 20.1, 21.9, 22.6, 23.1, 19.6, 19.0, 21.7, 21.4},
+			[]float64{27.1, 22.0, 20.8, 23.4, 23.4, 23.5, 25.8, 22.0, 24.8, 20.2, 21.9, 22.1, 22.9, 20.5, 24.4},
+			-2.455356, 24.988529, 0.021378,
+		},
+		{
+			"rssi",
+			[]float64{-92, -95, -90, -97, -93, -94, -91, -96},
+			[]float64{-85, -88, -84, -87, -86, -89},
+			-6.062178, 11.978610, 0.0000570,
+		},
+		{
+			"not significant",
+			[]float64{1, 2, 3, 4, 5},
+			[]float64{1.5, 2.5, 3.5, 4.5, 5.5, 2},
+			-0.264135, 8.552266, 0.797930,
+		},
+		{"identical", []float64{5, 5, 5}, []float64{5, 5}, 0.0, 3.0, 1.0},
+	}
+	for _, test := range tests {
+		tt, df, p := welchTTest(test.a, test.b)
+		if !near(tt, test.t, 1e-6) || !near(df, test.df, 1e-6) || !near(p, test.p, 1e-6) {
+			t.Errorf("%s: welchTTest = t %f, df %f, p %f, expected t %f, df %f, p %f",
+				test.name, tt, df, p, test.t, test.df, test.p)
+		}
+	}
+
+	if _, _, p := welchTTest([]float64{1}, []float64{1, 2, 3}); !math.IsNaN(p) {
+		t.Errorf("Expected a p-value of NaN with only 1 sample, got %f", p)
+	}
+}
+
+func TestCusumChangePoint(t *testing.T) {
+	tests := []struct {
+		vals     []float64
+		expected int
+	}{
+		{[]float64{}, 0},
+		{[]float64{1}, 0},
+		{[]float64{5, 5, 5}, 0},
+		{[]float64{1, 1, 1, 5, 5, 5}, 3},
+		{[]float64{-90, -90, -100, -100, -100, -100}, 2},
+		{[]float64{0, 0, 0, 0, 10}, 4},
+		{[]float64{10, 0, 0, 0, 0}, 1},
+		{[]float64{1, 2, 1, 2, 8, 9, 8, 9}, 4},
+	}
+	for _, test := range tests {
+		if got := cusumChangePoint(test.vals); got != test.expected {
+			t.Errorf("cusumChangePoint(%v) = %d, expected %d", test.vals, got, test.expected)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package analysis
+
+/*
+ * Helium Analysis
+ * Copyright (c) 2021-2022 Aaron Turner  <aturner at synfin dot net>
+ *
+ * This program is free software: you can redistribute it
+ * and/or modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or with the authors permission any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/wcharczuk/go-chart/v2"
+	"github.com/wcharczuk/go-chart/v2/drawing"
+)
+
+const (
+	MIN_GRAPH_WIDTH  = 320
+	MIN_GRAPH_HEIGHT = 200
+)
+
+// Dimensions, axis ranges & colors of a graph
+type GraphStyle struct {
+	Width   int
+	Height  int
+	YMin    float64 // RSSI axis
+	YMax    float64
+	SnrMin  float64
+	SnrMax  float64
+	DotSize float64
+	Colors  GraphColors
+}
+
+type GraphColors struct {
+	Valid     drawing.Color // beacons & witnesses graphs
+	Invalid   drawing.Color
+	Tx        drawing.Color // peer graphs
+	TxInvalid drawing.Color
+	Rx        drawing.Color
+	RxInvalid drawing.Color
+	MinValid  drawing.Color
+	MaxValid  drawing.Color
+	Snr       drawing.Color
+}
+
+// graphs whose style can be set individually
+var GRAPH_NAMES []string = []string{
+	"beacon-totals", "witness-distance", "peer", "beacon-cadence", "compare",
+	"coverage-success", "coverage-distance", "coverage-rssi", "fleet-overlap",
+	"patterns-beacons", "patterns-witnesses", "patterns-rssi",
+}
+
+var style GraphStyle = DefaultGraphStyle()
+var graphStyles map[string]GraphStyle = map[string]GraphStyle{} // graph => style
+
+func DefaultGraphStyle() GraphStyle {
+	return GraphStyle{
+		Width:   1024,
+		Height:  512,
+		YMin:    -130.0,
+		YMax:    -70.0,
+		SnrMin:  -20.0,
+		SnrMax:  16.0,
+		DotSize: 3,
+		Colors: GraphColors{
+			Valid:     chart.ColorGreen,
+			Invalid:   chart.ColorRed,
+			Tx:        chart.ColorBlue,
+			TxInvalid: chart.ColorRed,
+			Rx:        chart.ColorGreen,
+			RxInvalid: chart.ColorYellow,
+			MinValid:  chart.ColorOrange,
+			MaxValid:  chart.ColorRed,
+			Snr:       chart.ColorYellow,
+		},
+	}
+}
+
+// Returns the style used for graphs without their own style
+func GetGraphStyle() GraphStyle {
+	return style
+}
+
+// Validate & set the style used for graphs without their own style
+func SetGraphStyle(s GraphStyle) error {
+	if err := s.validate(); err != nil {
+		return err
+	}
+	style = s
+	return nil
+}
+
+// Validate & set the style of a single graph
+func SetGraphStyleFor(graph string, s GraphStyle) error {
+	valid := false
+	for _, name := range GRAPH_NAMES {
+		valid = valid || name == graph
+	}
+	if !valid {
+		return fmt.Errorf("Unknown graph '%s'.  Must be one of: %s", graph, strings.Join(GRAPH_NAMES, ", "))
+	}
+	if err := s.validate(); err != nil {
+		return fmt.Errorf("Graph %s: %s", graph, err)
+	}
+	graphStyles[graph] = s
+	return nil
+}
+
+// returns the style of the graph, falling back to the default style
+func styleFor(graph string) GraphStyle {
+	if s, ok := graphStyles[graph]; ok {
+		return s
+	}
+	return style
+}
+
+func (s GraphStyle) validate() error {
+	if s.Width < MIN_GRAPH_WIDTH || s.Height < MIN_GRAPH_HEIGHT {
+		return fmt.Errorf("Graphs must be at least %dx%d", MIN_GRAPH_WIDTH, MIN_GRAPH_HEIGHT)
+	}
+	if s.YMin >= s.YMax {
+		return fmt.Errorf("Graph y_min (%g) must be less than y_max (%g)", s.YMin, s.YMax)
+	}
+	if s.SnrMin >= s.SnrMax {
+		return fmt.Errorf("Graph snr_min (%g) must be less than snr_max (%g)", s.SnrMin, s.SnrMax)
+	}
+	if s.DotSize <= 0 {
+		return fmt.Errorf("Graph dot_size must be > 0")
+	}
+	return nil
+}
+
+var hexColor = regexp.MustCompile(`^([0-9a-fA-F]{3}|[0-9a-fA-F]{6})$`)
+
+// Parse a CSS hex color like #00ff00 or #0f0
+func ParseColor(color string) (drawing.Color, error) {
+	hex := strings.TrimPrefix(color, "#")
+	if !hexColor.MatchString(hex) {
+		return drawing.Color{}, fmt.Errorf("Invalid color '%s'.  Must be a hex color like #00ff00", color)
+	}
+	return drawing.ColorFromHex(hex), nil
+}
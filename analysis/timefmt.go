@@ -0,0 +1,236 @@
+package analysis
+
+/*
+ * Helium Analysis
+ * Copyright (c) 2021-2022 Aaron Turner  <aturner at synfin dot net>
+ *
+ * This program is free software: you can redistribute it
+ * and/or modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or with the authors permission any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/wcharczuk/go-chart/v2"
+)
+
+const (
+	CLOCK_12H = "12h"
+	CLOCK_24H = "24h"
+
+	TICK_WIDTH = 110 // minimum pixels between time axis labels
+	MAX_TICKS  = 100
+)
+
+// How times are shown in graphs, reports & tables
+type TimeDisplay struct {
+	Location *time.Location
+	Clock    string // 12h, 24h or empty for 12h on graph axes & 24h elsewhere
+}
+
+var display = TimeDisplay{Location: time.Local}
+
+// Set the timezone (IANA name like America/Los_Angeles, empty for Local) and
+// 12h or 24h clock.  An empty clock keeps the 12h graph axes & 24h tables.
+func SetTimeDisplay(tz, clock string) error {
+	if tz == "" {
+		tz = "Local"
+	}
+	loc, err := time.LoadLocation(tz)
+	if err != nil {
+		return fmt.Errorf("Invalid timezone '%s': %s", tz, err)
+	}
+	switch clock {
+	case "", CLOCK_12H, CLOCK_24H:
+	default:
+		return fmt.Errorf("Invalid clock '%s'.  Must be %s or %s", clock, CLOCK_12H, CLOCK_24H)
+	}
+	display = TimeDisplay{
+		Location: loc,
+		Clock:    clock,
+	}
+	return nil
+}
+
+// returns true if times on graph axes (axis = true) or elsewhere use a 12h clock
+func (d TimeDisplay) hour12(axis bool) bool {
+	if d.Clock == "" {
+		return axis
+	}
+	return d.Clock == CLOCK_12H
+}
+
+// Returns the timezone times are displayed in
+func GetLocation() *time.Location {
+	return display.Location
+}
+
+// Format the time with the date, time & timezone for tables & reports
+func FormatTime(t time.Time) string {
+	if display.hour12(false) {
+		return t.In(display.Location).Format("2006-01-02 3:04:05pm MST")
+	}
+	return t.In(display.Location).Format("2006-01-02 15:04:05 MST")
+}
+
+// Format the Unix time in seconds
+func FormatUnix(secs int64) string {
+	return FormatTime(time.Unix(secs, 0))
+}
+
+// Format just the date of the time
+func FormatDate(t time.Time) string {
+	return t.In(display.Location).Format(DATE_LAYOUT)
+}
+
+// returns the hour & minute in the 12h or 24h format for graph axes
+func formatClock(t time.Time) string {
+	if !display.hour12(true) {
+		return t.Format("15:04")
+	} else if t.Minute() == 0 {
+		return t.Format("3pm")
+	}
+	return t.Format("3:04pm")
+}
+
+// Format time (X values) in nanoseconds
+func XValueFormatter(v interface{}) string {
+	if fv, isFloat := v.(float64); isFloat {
+		t := time.Unix(0, int64(fv)).In(display.Location)
+		return fmt.Sprintf("%s %s", t.Format(DATE_LAYOUT), formatClock(t))
+	}
+	return ""
+}
+
+// Format time (X values) in seconds
+func XValueFormatterUnix(v interface{}) string {
+	if fv, isFloat := v.(float64); isFloat {
+		t := time.Unix(int64(fv), 0).In(display.Location)
+		return fmt.Sprintf("%s %s", t.Format(DATE_LAYOUT), formatClock(t))
+	}
+	return ""
+}
+
+// spacing of the ticks on a time axis
+type tickStep struct {
+	hours, days, months int
+}
+
+func (s tickStep) duration() time.Duration {
+	return time.Duration(s.hours)*time.Hour + time.Duration(s.days*24)*time.Hour +
+		time.Duration(s.months*30*24)*time.Hour
+}
+
+var TICK_STEPS []tickStep = []tickStep{
+	{hours: 1}, {hours: 2}, {hours: 3}, {hours: 6}, {hours: 12},
+	{days: 1}, {days: 2}, {days: 7}, {days: 14},
+	{months: 1}, {months: 2}, {months: 3}, {months: 6}, {months: 12},
+}
+
+/*
+ * A continuous range for time X axes which puts the ticks on round hours,
+ * days, weeks (Mondays) or months in the display timezone with as many ticks
+ * as fit.  Unit is the duration of 1.0 on the axis.
+ */
+type TimeAxisRange struct {
+	chart.ContinuousRange
+	Unit time.Duration
+}
+
+func NewTimeAxisRange(unit time.Duration, min, max float64) *TimeAxisRange {
+	return &TimeAxisRange{
+		ContinuousRange: chart.ContinuousRange{Min: min, Max: max},
+		Unit:            unit,
+	}
+}
+
+// Implements chart.TicksProvider
+func (r *TimeAxisRange) GetTicks(rend chart.Renderer, defaults chart.Style, vf chart.ValueFormatter) []chart.Tick {
+	first := r.toTime(r.Min)
+	last := r.toTime(r.Max)
+	count := r.Domain / TICK_WIDTH
+	if count < 2 {
+		count = 2
+	}
+
+	step := TICK_STEPS[len(TICK_STEPS)-1]
+	for _, s := range TICK_STEPS {
+		if last.Sub(first)/s.duration() < time.Duration(count) {
+			step = s
+			break
+		}
+	}
+
+	ticks := []chart.Tick{}
+	for t := alignTick(first, step); !t.After(last) && len(ticks) < MAX_TICKS; t = nextTick(t, step) {
+		if t.Before(first) {
+			continue
+		}
+		ticks = append(ticks, chart.Tick{
+			Value: float64(t.UnixNano()) / float64(r.Unit),
+			Label: tickLabel(t, step),
+		})
+	}
+	return ticks
+}
+
+func (r *TimeAxisRange) toTime(v float64) time.Time {
+	return time.Unix(0, int64(v*float64(r.Unit))).In(display.Location)
+}
+
+// returns the first tick at or before t
+func alignTick(t time.Time, step tickStep) time.Time {
+	loc := t.Location()
+	switch {
+	case step.months > 0:
+		month := (int(t.Month()) - 1) / step.months * step.months
+		return time.Date(t.Year(), time.Month(month+1), 1, 0, 0, 0, 0, loc)
+	case step.days == 7 || step.days == 14:
+		day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+		offset := (int(day.Weekday()) + 6) % 7 // days since Monday
+		return day.AddDate(0, 0, -offset)
+	case step.days > 0:
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+	default:
+		return time.Date(t.Year(), t.Month(), t.Day(), t.Hour()/step.hours*step.hours, 0, 0, 0, loc)
+	}
+}
+
+// calendar math so ticks stay on midnight across DST changes
+func nextTick(t time.Time, step tickStep) time.Time {
+	if step.hours > 0 {
+		next := t.Add(time.Duration(step.hours) * time.Hour)
+		// realign after a DST change
+		if aligned := alignTick(next, step); aligned.After(t) {
+			return aligned
+		}
+		return next
+	}
+	return t.AddDate(0, step.months, step.days)
+}
+
+func tickLabel(t time.Time, step tickStep) string {
+	switch {
+	case step.months >= 12:
+		return t.Format("2006")
+	case step.months > 0:
+		return t.Format("Jan 2006")
+	case step.days > 0:
+		return t.Format("Jan 2")
+	case t.Hour() == 0:
+		return t.Format("Jan 2")
+	default:
+		return formatClock(t)
+	}
+}
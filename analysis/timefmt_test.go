@@ -0,0 +1,66 @@
+package analysis_test
+
+/*
+ * Helium Analysis
+ * Copyright (c) 2021-2022 Aaron Turner  <aturner at synfin dot net>
+ *
+ * This program is free software: you can redistribute it
+ * and/or modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or with the authors permission any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+import (
+	"testing"
+	"time"
+
+	"github.com/synfinatic/helium-analysis/analysis"
+)
+
+func TestTimeDisplay(t *testing.T) {
+	defer analysis.SetTimeDisplay("UTC", "")
+	when := time.Date(2022, 1, 15, 20, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		tz    string
+		clock string
+		table string
+		axis  string
+	}{
+		// by default graph axes use a 12h clock and tables a 24h clock
+		{"UTC", "", "2022-01-15 20:30:00 UTC", "2022-01-15 8:30pm"},
+		{"UTC", "12h", "2022-01-15 8:30:00pm UTC", "2022-01-15 8:30pm"},
+		{"UTC", "24h", "2022-01-15 20:30:00 UTC", "2022-01-15 20:30"},
+		{"America/Los_Angeles", "", "2022-01-15 12:30:00 PST", "2022-01-15 12:30pm"},
+		{"Asia/Tokyo", "24h", "2022-01-16 05:30:00 JST", "2022-01-16 05:30"},
+	}
+	for _, test := range tests {
+		if err := analysis.SetTimeDisplay(test.tz, test.clock); err != nil {
+			t.Fatal(err)
+		}
+		if got := analysis.FormatTime(when); got != test.table {
+			t.Errorf("%s/%s: FormatTime() = %s, expected %s", test.tz, test.clock, got, test.table)
+		}
+		if got := analysis.XValueFormatterUnix(float64(when.Unix())); got != test.axis {
+			t.Errorf("%s/%s: XValueFormatterUnix() = %s, expected %s", test.tz, test.clock, got, test.axis)
+		}
+	}
+
+	if err := analysis.SetTimeDisplay("Mars/Olympus_Mons", ""); err == nil {
+		t.Errorf("Expected an invalid timezone to fail")
+	}
+	if err := analysis.SetTimeDisplay("UTC", "13h"); err == nil {
+		t.Errorf("Expected an invalid clock to fail")
+	}
+	if err := analysis.SetTimeDisplay("", ""); err != nil || analysis.GetLocation() != time.Local {
+		t.Errorf("Expected an empty timezone to be Local: %v", err)
+	}
+}
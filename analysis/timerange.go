@@ -0,0 +1,217 @@
+package analysis
+
+/*
+ * Helium Analysis
+ * Copyright (c) 2021-2022 Aaron Turner  <aturner at synfin dot net>
+ *
+ * This program is free software: you can redistribute it
+ * and/or modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or with the authors permission any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	bolt "go.etcd.io/bbolt"
+)
+
+const (
+	BLOCK_TIME         = 60  // target seconds between blocks
+	MAX_HEIGHT_LOOKUPS = 100 // max challenges to walk to find a block height
+)
+
+var ageRegexp = regexp.MustCompile(`^(\d+)([smhdw])$`)
+
+// layouts accepted by ParseTime, in the display timezone unless they include a zone
+var TIME_LAYOUTS []string = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+}
+
+const DATE_LAYOUT = "2006-01-02"
+
+/*
+ * Parses a duration such as 90s, 30m, 12h, 3d or 2w.  Go durations like
+ * 1h30m are also accepted.
+ */
+func ParseAge(value string) (time.Duration, error) {
+	if m := ageRegexp.FindStringSubmatch(value); m != nil {
+		x, err := strconv.ParseInt(m[1], 10, 64)
+		if err != nil {
+			return 0, err
+		}
+		unit := time.Second
+		switch m[2] {
+		case "m":
+			unit = time.Minute
+		case "h":
+			unit = time.Hour
+		case "d":
+			unit = 24 * time.Hour
+		case "w":
+			unit = 7 * 24 * time.Hour
+		}
+		return time.Duration(x) * unit, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("Invalid duration '%s'.  Must be an integer followed by: s, m, h, d or w", value)
+	}
+	return d, nil
+}
+
+/*
+ * Parses a point in time which may be:
+ *  - now
+ *  - a date: YYYY-MM-DD
+ *  - a time: RFC3339 or YYYY-MM-DDTHH:MM[:SS]
+ *  - an age before now: 30m, 12h, 3d, 2w
+ *  - a block height: 1150000
+ * Returns true if the value was only a date.
+ */
+func (b *BoltDB) ParseTime(value string, now time.Time) (time.Time, bool, error) {
+	value = strings.TrimSpace(value)
+	if value == "" || value == "now" {
+		return now.UTC(), false, nil
+	}
+
+	if t, err := time.ParseInLocation(DATE_LAYOUT, value, display.Location); err == nil {
+		return t.UTC(), true, nil
+	}
+	for _, layout := range TIME_LAYOUTS {
+		if t, err := time.ParseInLocation(layout, value, display.Location); err == nil {
+			return t.UTC(), false, nil
+		}
+	}
+	if height, err := strconv.ParseInt(value, 10, 64); err == nil {
+		t, err := b.GetTimeForHeight(height)
+		return t, false, err
+	}
+	if age, err := ParseAge(value); err == nil {
+		return now.UTC().Add(-age), false, nil
+	}
+	return now, false, fmt.Errorf("Invalid time '%s'.  Must be YYYY-MM-DD, RFC3339, an age like 3d or a block height", value)
+}
+
+// Parses --since & --until.  An until date includes the whole day.
+func (b *BoltDB) ParseTimeRange(since, until string, now time.Time) (time.Time, time.Time, error) {
+	first, _, err := b.ParseTime(since, now)
+	if err != nil {
+		return first, now, err
+	}
+	last, isDate, err := b.ParseTime(until, now)
+	if err != nil {
+		return first, last, err
+	}
+	if isDate {
+		last = last.Add(24*time.Hour - time.Second)
+	}
+	if !first.Before(last) {
+		return first, last, fmt.Errorf("Start time %s must be before end time %s",
+			first.Format(TIME_FORMAT), last.Format(TIME_FORMAT))
+	}
+	return first, last, nil
+}
+
+/*
+ * Estimates the time of the given block height using the challenges in the
+ * database of the hotspot with the most challenges.  We seek to the time
+ * assuming BLOCK_TIME seconds per block and then walk to the challenges on
+ * either side of the height to interpolate.  Falls back to the Helium API if
+ * there are no challenges.
+ */
+func (b *BoltDB) GetTimeForHeight(height int64) (time.Time, error) {
+	if height < 1 {
+		return time.Unix(0, 0), fmt.Errorf("Invalid block height: %d", height)
+	}
+
+	var t int64 = 0
+	err := b.db.View(func(tx *bolt.Tx) error {
+		var bucket *bolt.Bucket
+		size := 0
+		err := tx.Bucket(CHALLENGES_BUCKET).ForEach(func(k, v []byte) error {
+			if v != nil {
+				return nil // not a bucket
+			}
+			if hb := tx.Bucket(CHALLENGES_BUCKET).Bucket(k); hb.Stats().KeyN > size {
+				bucket = hb
+				size = hb.Stats().KeyN
+			}
+			return nil
+		})
+		if err != nil || bucket == nil {
+			return err
+		}
+
+		cursor := bucket.Cursor()
+		_, v := cursor.Last()
+		c := Challenges{}
+		if err := json.Unmarshal(v, &c); err != nil {
+			return err
+		}
+		key := make([]byte, 8)
+		binary.BigEndian.PutUint64(key, uint64(c.Time+(height-c.Height)*BLOCK_TIME))
+		k, v := cursor.Seek(key)
+		if k == nil {
+			k, v = cursor.Last()
+		}
+
+		var before, after *Challenges
+		for i := 0; i < MAX_HEIGHT_LOOKUPS && k != nil; i++ {
+			c := Challenges{}
+			if err := json.Unmarshal(v, &c); err != nil {
+				return err
+			}
+			if c.Height <= height {
+				before = &c
+				if after != nil {
+					break
+				}
+				k, v = cursor.Next()
+			} else {
+				after = &c
+				if before != nil {
+					break
+				}
+				k, v = cursor.Prev()
+			}
+		}
+
+		switch {
+		case before != nil && after != nil:
+			t = before.Time + (height-before.Height)*(after.Time-before.Time)/(after.Height-before.Height)
+		case before != nil:
+			t = before.Time + (height-before.Height)*BLOCK_TIME
+		case after != nil:
+			t = after.Time - (after.Height-height)*BLOCK_TIME
+		}
+		return nil
+	})
+	if err != nil {
+		return time.Unix(0, 0), err
+	}
+	if t > 0 {
+		log.Debugf("Block %d is about %s", height, time.Unix(t, 0).UTC().Format(TIME_FORMAT))
+		return time.Unix(t, 0).UTC(), nil
+	}
+	return FetchBlockTime(height)
+}
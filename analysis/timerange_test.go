@@ -0,0 +1,226 @@
+package analysis_test
+
+/*
+ * Helium Analysis
+ * Copyright (c) 2021-2022 Aaron Turner  <aturner at synfin dot net>
+ *
+ * This program is free software: you can redistribute it
+ * and/or modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or with the authors permission any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/synfinatic/helium-analysis/analysis"
+	"github.com/synfinatic/helium-analysis/internal/testutil"
+	bolt "go.etcd.io/bbolt"
+)
+
+// stores challenges at the given height => unix time
+func putChallenges(t *testing.T, b *analysis.BoltDB, address string, heights map[int64]int64) {
+	err := b.GetDb().Update(func(tx *bolt.Tx) error {
+		bucket, err := analysis.ChallengeBucket(tx, address)
+		if err != nil {
+			return err
+		}
+		for height, secs := range heights {
+			jdata, err := json.Marshal(analysis.Challenges{Height: height, Time: secs})
+			if err != nil {
+				return err
+			}
+			key := make([]byte, 8)
+			binary.BigEndian.PutUint64(key, uint64(secs))
+			if err = bucket.Put(key, jdata); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// heights of the test challenges are 50s apart until 1100 and 60s after
+var testStart = time.Date(2022, 1, 15, 12, 0, 0, 0, time.UTC)
+
+var testHeights = map[int64]int64{
+	1000: testStart.Unix(),
+	1100: testStart.Unix() + 5000,
+	1200: testStart.Unix() + 11000,
+}
+
+func TestParseAge(t *testing.T) {
+	tests := []struct {
+		value string
+		age   time.Duration
+		err   bool
+	}{
+		{"90s", 90 * time.Second, false},
+		{"30m", 30 * time.Minute, false},
+		{"12h", 12 * time.Hour, false},
+		{"3d", 3 * 24 * time.Hour, false},
+		{"2w", 14 * 24 * time.Hour, false},
+		{"1h30m", 90 * time.Minute, false},
+		{"3y", 0, true},
+		{"-1h", 0, true},
+	}
+	for _, test := range tests {
+		age, err := analysis.ParseAge(test.value)
+		if (err != nil) != test.err {
+			t.Errorf("ParseAge(%s) error: %v", test.value, err)
+		} else if age != test.age {
+			t.Errorf("ParseAge(%s) = %s, expected %s", test.value, age, test.age)
+		}
+	}
+}
+
+func TestParseTime(t *testing.T) {
+	if err := analysis.SetTimeDisplay("UTC", ""); err != nil {
+		t.Fatal(err)
+	}
+	b := testutil.OpenDB(t)
+	putChallenges(t, b, "11testhotspot", testHeights)
+	now := time.Date(2022, 2, 1, 10, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		value  string
+		time   time.Time
+		isDate bool
+		err    bool
+	}{
+		{"now", now, false, false},
+		{"", now, false, false},
+		{"2022-01-15", time.Date(2022, 1, 15, 0, 0, 0, 0, time.UTC), true, false},
+		{"2022-01-15T08:00:00-08:00", time.Date(2022, 1, 15, 16, 0, 0, 0, time.UTC), false, false},
+		{"2022-01-15T08:00", time.Date(2022, 1, 15, 8, 0, 0, 0, time.UTC), false, false},
+		{"2022-01-15 08:00:30", time.Date(2022, 1, 15, 8, 0, 30, 0, time.UTC), false, false},
+		{"90m", now.Add(-90 * time.Minute), false, false},
+		{"3d", now.Add(-3 * 24 * time.Hour), false, false},
+		{"2w", now.Add(-14 * 24 * time.Hour), false, false},
+		{"1100", testStart.Add(5000 * time.Second), false, false},
+		{"yesterday", now, false, true},
+		{"2022-13-01", now, false, true},
+	}
+	for _, test := range tests {
+		got, isDate, err := b.ParseTime(test.value, now)
+		if (err != nil) != test.err {
+			t.Errorf("ParseTime(%s) error: %v", test.value, err)
+			continue
+		} else if test.err {
+			continue
+		}
+		if !got.Equal(test.time) {
+			t.Errorf("ParseTime(%s) = %s, expected %s", test.value, got, test.time)
+		}
+		if isDate != test.isDate {
+			t.Errorf("ParseTime(%s) isDate = %v, expected %v", test.value, isDate, test.isDate)
+		}
+	}
+
+	// dates & times without a zone are in the --tz timezone
+	if err := analysis.SetTimeDisplay("America/Los_Angeles", ""); err != nil {
+		t.Fatal(err)
+	}
+	defer analysis.SetTimeDisplay("UTC", "")
+	for value, expected := range map[string]time.Time{
+		"2022-01-15":       time.Date(2022, 1, 15, 8, 0, 0, 0, time.UTC),
+		"2022-01-15T08:00": time.Date(2022, 1, 15, 16, 0, 0, 0, time.UTC),
+	} {
+		if got, _, err := b.ParseTime(value, now); err != nil || !got.Equal(expected) {
+			t.Errorf("ParseTime(%s) in America/Los_Angeles = %s, %v, expected %s", value, got, err, expected)
+		}
+	}
+}
+
+func TestParseTimeRange(t *testing.T) {
+	if err := analysis.SetTimeDisplay("UTC", ""); err != nil {
+		t.Fatal(err)
+	}
+	b := testutil.OpenDB(t)
+	now := time.Date(2022, 2, 1, 10, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		since string
+		until string
+		first time.Time
+		last  time.Time
+		err   bool
+	}{
+		// an until date includes the whole day
+		{"2021-12-01", "2021-12-31", time.Date(2021, 12, 1, 0, 0, 0, 0, time.UTC),
+			time.Date(2021, 12, 31, 23, 59, 59, 0, time.UTC), false},
+		{"2022-01-31", "2022-01-31", time.Date(2022, 1, 31, 0, 0, 0, 0, time.UTC),
+			time.Date(2022, 1, 31, 23, 59, 59, 0, time.UTC), false},
+		{"30d", "now", now.Add(-30 * 24 * time.Hour), now, false},
+		{"2022-01-31T12:00", "2022-02-01T06:00", time.Date(2022, 1, 31, 12, 0, 0, 0, time.UTC),
+			time.Date(2022, 2, 1, 6, 0, 0, 0, time.UTC), false},
+		// since must be before until
+		{"now", "1d", time.Time{}, time.Time{}, true},
+		{"2022-01-15T08:00", "2022-01-15T08:00", time.Time{}, time.Time{}, true},
+		{"bogus", "now", time.Time{}, time.Time{}, true},
+		{"1d", "bogus", time.Time{}, time.Time{}, true},
+	}
+	for _, test := range tests {
+		first, last, err := b.ParseTimeRange(test.since, test.until, now)
+		if (err != nil) != test.err {
+			t.Errorf("ParseTimeRange(%s, %s) error: %v", test.since, test.until, err)
+			continue
+		} else if test.err {
+			continue
+		}
+		if !first.Equal(test.first) || !last.Equal(test.last) {
+			t.Errorf("ParseTimeRange(%s, %s) = %s - %s, expected %s - %s",
+				test.since, test.until, first, last, test.first, test.last)
+		}
+	}
+}
+
+func TestGetTimeForHeight(t *testing.T) {
+	b := testutil.OpenDB(t)
+	putChallenges(t, b, "11testhotspot", testHeights)
+	// a smaller bucket which should be ignored
+	putChallenges(t, b, "11otherhotspot", map[int64]int64{1050: testStart.Unix()})
+
+	tests := []struct {
+		height int64
+		secs   int64 // after testStart
+	}{
+		{1000, 0},
+		{1050, 2500}, // 50s per block
+		{1099, 4950},
+		{1100, 5000},
+		{1101, 5060}, // 60s per block
+		{1150, 8000},
+		{1200, 11000},
+		{900, -100 * analysis.BLOCK_TIME}, // before the first challenge
+		{1300, 11000 + 100*analysis.BLOCK_TIME},
+	}
+	for _, test := range tests {
+		got, err := b.GetTimeForHeight(test.height)
+		if err != nil {
+			t.Errorf("GetTimeForHeight(%d) error: %s", test.height, err)
+			continue
+		}
+		if expected := testStart.Add(time.Duration(test.secs) * time.Second); !got.Equal(expected) {
+			t.Errorf("GetTimeForHeight(%d) = %s, expected %s", test.height, got, expected)
+		}
+	}
+
+	if _, err := b.GetTimeForHeight(0); err == nil {
+		t.Errorf("GetTimeForHeight(0) should fail")
+	}
+}
@@ -19,10 +19,7 @@ package analysis
  */
 
 import (
-	"fmt"
 	"math"
-	"strconv"
-	"time"
 
 	"github.com/umahmood/haversine"
 )
@@ -54,39 +51,6 @@ func MergeTwoSeries(ax, ay, bx, by []float64) ([]float64, []float64) {
 	return newx, newy
 }
 
-// Format time (X values)
-func XValueFormatter(v interface{}) string {
-	if fv, isFloat := v.(float64); isFloat {
-		t := time.Unix(int64(fv/1000000000), 0)
-		hr, _ := strconv.Atoi(t.Format("15"))
-		htime := "am"
-		if hr > 11 {
-			htime = "pm"
-			if hr > 12 {
-				hr -= 12
-			}
-		}
-		return fmt.Sprintf("%s %d%s", t.Format("2006-01-02"), hr, htime)
-	}
-	return ""
-}
-
-func XValueFormatterUnix(v interface{}) string {
-	if fv, isFloat := v.(float64); isFloat {
-		t := time.Unix(int64(fv), 0)
-		hr, _ := strconv.Atoi(t.Format("15"))
-		htime := "am"
-		if hr > 11 {
-			htime = "pm"
-			if hr > 12 {
-				hr -= 12
-			}
-		}
-		return fmt.Sprintf("%s %d%s", t.Format("2006-01-02"), hr, htime)
-	}
-	return ""
-}
-
 // get a unique list of addresses in all the challenges
 func GetListOfAddresses(challenges []Challenges) ([]string, error) {
 	addrs := map[string]int{}
@@ -109,6 +73,17 @@ func GetListOfAddresses(challenges []Challenges) ([]string, error) {
 	return ret, nil
 }
 
+const (
+	TX_POWER      = 28.0  // dBm
+	ANTENNA_GAIN  = 1.8   // dBi
+	FREQUENCY_MHZ = 915.0 // MHz
+)
+
+// returns the free space path loss in dB for the given distance
+func fspl(km float64) float64 {
+	return (20.0 * math.Log10(km)) + (20.0 * math.Log10(FREQUENCY_MHZ)) + 32.44
+}
+
 // returns the max RSSI based on distance
 // Stolen from: https://github.com/Carniverous19/helium_analysis_tools.git
 func maxRssi(km float64) float64 {
@@ -118,7 +93,7 @@ func maxRssi(km float64) float64 {
 
 	// note hard coded 1.8dB antenna gain is how Helium calculates things, but
 	// is a horrible hack
-	return 28.0 + 1.8*2 - (20.0 * math.Log10(km)) - (20.0 * math.Log10(915.0)) - 32.44
+	return TX_POWER + ANTENNA_GAIN*2 - fspl(km)
 }
 
 // Table is map[SNR] = minimum valid RSSI
@@ -187,3 +162,15 @@ func getDistance(aHost, bHost Hotspot) (float64, float64, error) {
 	)
 	return km, mi, nil
 }
+
+// Get the initial bearing in degrees (0-360, 0 = North) from aHost to bHost
+func getBearing(aHost, bHost Hotspot) float64 {
+	lat1 := aHost.Lat * math.Pi / 180.0
+	lat2 := bHost.Lat * math.Pi / 180.0
+	dLng := (bHost.Lng - aHost.Lng) * math.Pi / 180.0
+
+	y := math.Sin(dLng) * math.Cos(lat2)
+	x := math.Cos(lat1)*math.Sin(lat2) - math.Sin(lat1)*math.Cos(lat2)*math.Cos(dLng)
+	bearing := math.Atan2(y, x) * 180.0 / math.Pi
+	return math.Mod(bearing+360.0, 360.0)
+}
@@ -0,0 +1,116 @@
+package analysis
+
+/*
+ * Helium Analysis
+ * Copyright (c) 2021-2022 Aaron Turner  <aturner at synfin dot net>
+ *
+ * This program is free software: you can redistribute it
+ * and/or modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or with the authors permission any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+import (
+	"encoding/json"
+	"math/rand"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+const (
+	WATCH_KEY_PREFIX   = "watch:"        // META_BUCKET key prefix for the WatchState of each hotspot
+	WATCH_BACKOFF_MIN  = 5 * time.Minute // delay after the first failure
+	WATCH_BACKOFF_MAX  = 24 * time.Hour  // never wait longer than this after failures
+	WATCH_BACKOFF_BASE = 2               // multiplier for each additional failure
+)
+
+// Scheduling state of a watched hotspot, persisted in the META_BUCKET
+type WatchState struct {
+	Address     string `json:"address"`
+	LastRun     int64  `json:"last_run"`     // unix time
+	LastSuccess int64  `json:"last_success"` // unix time
+	NextRun     int64  `json:"next_run"`     // unix time
+	Failures    int    `json:"failures"`     // consecutive failures
+	LastError   string `json:"last_error"`
+}
+
+func watchKey(address string) []byte {
+	return []byte(WATCH_KEY_PREFIX + address)
+}
+
+// Returns the watch state for the hotspot.  Hotspots which have never been
+// watched are due immediately.
+func (b *BoltDB) GetWatchState(address string) (WatchState, error) {
+	state := WatchState{
+		Address: address,
+	}
+	err := b.db.View(func(tx *bolt.Tx) error {
+		v := tx.Bucket(META_BUCKET).Get(watchKey(address))
+		if v == nil {
+			return nil
+		}
+		return json.Unmarshal(v, &state)
+	})
+	return state, err
+}
+
+// Saves the watch state for the hotspot
+func (b *BoltDB) SetWatchState(state WatchState) error {
+	jdata, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	return b.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(META_BUCKET).Put(watchKey(state.Address), jdata)
+	})
+}
+
+// Returns true if the hotspot should be refreshed now
+func (s WatchState) Due(now time.Time) bool {
+	return s.NextRun <= now.Unix()
+}
+
+// Record the result of a run and schedule the next one.  Successful runs are
+// scheduled after interval +/- jitter and failures back off exponentially
+// from WATCH_BACKOFF_MIN so we don't hammer a busy API server.
+func (s *WatchState) Finished(now time.Time, interval, jitter time.Duration, err error) {
+	s.LastRun = now.Unix()
+
+	delay := interval
+	if err == nil {
+		s.LastSuccess = now.Unix()
+		s.Failures = 0
+		s.LastError = ""
+	} else {
+		s.Failures += 1
+		s.LastError = err.Error()
+		delay = WATCH_BACKOFF_MIN
+		for i := 1; i < s.Failures && delay < WATCH_BACKOFF_MAX; i++ {
+			delay *= WATCH_BACKOFF_BASE
+		}
+		if delay > WATCH_BACKOFF_MAX {
+			delay = WATCH_BACKOFF_MAX
+		}
+	}
+
+	// don't let the jitter swallow a short backoff
+	if jitter > delay/2 {
+		jitter = delay / 2
+	}
+	if jitter > 0 {
+		delay += time.Duration(rand.Int63n(int64(2*jitter))) - jitter
+	}
+	if delay < time.Minute {
+		delay = time.Minute
+	}
+	s.NextRun = now.Add(delay).Unix()
+}
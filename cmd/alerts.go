@@ -0,0 +1,147 @@
+package main
+
+/*
+ * Helium Analysis
+ * Copyright (c) 2021-2022 Aaron Turner  <aturner at synfin dot net>
+ *
+ * This program is free software: you can redistribute it
+ * and/or modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or with the authors permission any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+import (
+	"fmt"
+	"reflect"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/synfinatic/helium-analysis/analysis"
+	"github.com/synfinatic/onelogin-aws-role/utils"
+)
+
+type AlertsCmd struct {
+	Address []string `kong:"arg,optional,name='address',help='Hotspot address(es) or name(s) to check'"`
+	Owner   []string `kong:"name='owner',help='Check all hotspots owned by these wallet(s)'"`
+	Rules   string   `kong:"required,name='rules',short='r',help='JSON file with the alert rules & webhooks'"`
+	Webhook []string `kong:"name='webhook',short='w',help='Additional webhook URL(s) to POST alerts to'"`
+	Since   string   `kong:"name='since',default='7d',help='Start of the challenges to check: YYYY-MM-DD, RFC3339, age (3d, 12h, 2w) or block height'"`
+	DryRun  bool     `kong:"name='dry-run',short='n',default=false,help='Print the alerts without sending or saving them'"`
+}
+
+type AlertReport struct {
+	Status  string `header:"Status"`
+	Rule    string `header:"Rule"`
+	Name    string `header:"Hotspot"`
+	Message string `header:"Message"`
+}
+
+func (ar AlertReport) GetHeader(fieldName string) (string, error) {
+	v := reflect.ValueOf(ar)
+	return utils.GetHeaderTag(v, fieldName)
+}
+
+func (cmd *AlertsCmd) Run(ctx *RunContext) error {
+	ctx.Cli.Alerts.Address = trackedHotspots(ctx.Cli.Alerts.Address, ctx.Cli.Alerts.Owner)
+	cli := *ctx.Cli
+
+	if len(cli.Alerts.Address) == 0 && len(cli.Alerts.Owner) == 0 {
+		return fmt.Errorf("Please specify one or more hotspots and/or --owner or add hotspots to the config file")
+	}
+	config, err := loadAlertConfig(cli.Alerts.Rules, cli.Alerts.Webhook)
+	if err != nil {
+		return err
+	}
+
+	hotspots, err := resolveHotspotList(ctx, cli.Alerts.Address, cli.Alerts.Owner)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	firstTime, _, err := ctx.BoltDB.ParseTimeRange(cli.Alerts.Since, "now", now)
+	if err != nil {
+		return err
+	}
+	rows := []utils.TableStruct{}
+	sent := []analysis.Alert{}
+	for _, hotspotAddress := range hotspots {
+		challenges, err := ctx.BoltDB.GetChallenges(hotspotAddress, firstTime, now)
+		if err != nil {
+			return err
+		}
+		alerts, err := checkAlerts(ctx.BoltDB, hotspotAddress, challenges, config, now, cli.Alerts.DryRun)
+		if err != nil {
+			if len(hotspots) == 1 {
+				return err
+			}
+			log.WithError(err).Errorf("Unable to check alerts for %s", hotspotAddress)
+		}
+		for _, alert := range alerts {
+			rows = append(rows, AlertReport{
+				Status:  alert.Status,
+				Rule:    alert.Rule,
+				Name:    alert.Name,
+				Message: alert.Message,
+			})
+		}
+		sent = append(sent, alerts...)
+	}
+
+	fields := []string{"Status", "Rule", "Name", "Message"}
+	return writeReport("table", "", rows, fields, sent)
+}
+
+// Load the alert config and add the extra webhooks
+func loadAlertConfig(filename string, webhooks []string) (analysis.AlertConfig, error) {
+	config, err := analysis.LoadAlertConfig(filename)
+	if err != nil {
+		return config, err
+	}
+	config.Webhooks = append(config.Webhooks, webhooks...)
+	if len(config.Webhooks) == 0 {
+		log.Warnf("No webhooks configured.  Alerts will only be logged.")
+	}
+	return config, nil
+}
+
+// Evaluate the rules for the hotspot and send the new & resolved alerts to
+// every webhook.  Webhooks which fail are saved with the alert and only they
+// are retried the next time.  Returns the changed alerts.
+func checkAlerts(db *analysis.BoltDB, address string, challenges []analysis.Challenges, config analysis.AlertConfig, now time.Time, dryRun bool) ([]analysis.Alert, error) {
+	firing, err := db.EvaluateAlerts(address, challenges, config.Rules, now)
+	if err != nil {
+		return []analysis.Alert{}, err
+	}
+	changes, err := db.AlertChanges(address, firing, now)
+	if err != nil || dryRun {
+		return changes, err
+	}
+
+	for _, alert := range changes {
+		log.Infof("Alert %s: %s", alert.Status, alert.Message)
+		urls := config.Webhooks
+		if len(alert.Pending) > 0 {
+			urls = alert.Pending
+		}
+		alert.Pending = []string{}
+		for _, url := range urls {
+			if err := analysis.SendAlert(url, alert); err != nil {
+				log.WithError(err).Errorf("Unable to send alert %s", alert.Key)
+				alert.Pending = append(alert.Pending, url)
+			}
+		}
+		if err = db.SaveAlert(alert); err != nil {
+			return changes, err
+		}
+	}
+	return changes, nil
+}
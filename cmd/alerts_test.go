@@ -0,0 +1,151 @@
+package main
+
+/*
+ * Helium Analysis
+ * Copyright (c) 2021-2022 Aaron Turner  <aturner at synfin dot net>
+ *
+ * This program is free software: you can redistribute it
+ * and/or modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or with the authors permission any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/synfinatic/helium-analysis/analysis"
+	"github.com/synfinatic/helium-analysis/internal/testutil"
+)
+
+const testAddress = "11testhotspot"
+
+// a local webhook which records the alerts it accepts
+type testWebhook struct {
+	lock   sync.Mutex
+	fail   bool
+	alerts []analysis.Alert
+}
+
+func (h *testWebhook) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	if h.fail {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	alert := analysis.Alert{}
+	if err := json.NewDecoder(r.Body).Decode(&alert); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	h.alerts = append(h.alerts, alert)
+}
+
+// returns the statuses of the alerts received since the last call
+func (h *testWebhook) received() []string {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	statuses := []string{}
+	for _, alert := range h.alerts {
+		statuses = append(statuses, alert.Status)
+	}
+	h.alerts = []analysis.Alert{}
+	return statuses
+}
+
+func (h *testWebhook) setFail(fail bool) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	h.fail = fail
+}
+
+// a beacon by our hotspot which nobody witnessed
+func testBeacon(t time.Time) analysis.Challenges {
+	return analysis.Challenges{
+		Time: t.Unix(),
+		Path: &[]analysis.PathType{
+			{
+				Challengee: testAddress,
+				Witnesses:  &[]analysis.WitnessType{},
+			},
+		},
+	}
+}
+
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestCheckAlerts(t *testing.T) {
+	db := testutil.OpenDB(t)
+	ok := &testWebhook{}
+	flaky := &testWebhook{}
+	okServer := httptest.NewServer(ok)
+	defer okServer.Close()
+	flakyServer := httptest.NewServer(flaky)
+	defer flakyServer.Close()
+
+	config := analysis.AlertConfig{
+		Rules: []analysis.AlertRule{
+			{Name: "quiet", Type: analysis.ALERT_NO_BEACON, Hours: 2, Minimum: 1},
+		},
+		Webhooks: []string{okServer.URL, flakyServer.URL},
+	}
+	start := time.Date(2022, 1, 15, 0, 0, 0, 0, time.UTC)
+	old := []analysis.Challenges{testBeacon(start)}
+	recent := append(old, testBeacon(start.Add(5*time.Hour)))
+
+	steps := []struct {
+		name       string
+		challenges []analysis.Challenges
+		now        time.Time
+		flakyFails bool
+		changes    int
+		ok         []string
+		flaky      []string
+	}{
+		{"fire with a failing webhook", old, start.Add(3 * time.Hour), true, 1, []string{"firing"}, []string{}},
+		{"retry only the failed webhook", old, start.Add(4 * time.Hour), false, 1, []string{}, []string{"firing"}},
+		{"dedup once delivered", old, start.Add(5 * time.Hour), false, 0, []string{}, []string{}},
+		{"resolve", recent, start.Add(6 * time.Hour), false, 1, []string{"resolved"}, []string{"resolved"}},
+		{"nothing after resolve", recent, start.Add(6 * time.Hour), false, 0, []string{}, []string{}},
+	}
+
+	for _, step := range steps {
+		flaky.setFail(step.flakyFails)
+		changes, err := checkAlerts(db, testAddress, step.challenges, config, step.now, false)
+		if err != nil {
+			t.Fatalf("%s: %s", step.name, err)
+		}
+		if len(changes) != step.changes {
+			t.Errorf("%s: expected %d changes, got %d", step.name, step.changes, len(changes))
+		}
+		if got := ok.received(); !equal(got, step.ok) {
+			t.Errorf("%s: ok webhook expected %v, got %v", step.name, step.ok, got)
+		}
+		if got := flaky.received(); !equal(got, step.flaky) {
+			t.Errorf("%s: flaky webhook expected %v, got %v", step.name, step.flaky, got)
+		}
+	}
+}
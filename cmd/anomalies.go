@@ -0,0 +1,123 @@
+package main
+
+/*
+ * Helium Analysis
+ * Copyright (c) 2021-2022 Aaron Turner  <aturner at synfin dot net>
+ *
+ * This program is free software: you can redistribute it
+ * and/or modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or with the authors permission any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+import (
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/synfinatic/helium-analysis/analysis"
+	"github.com/synfinatic/onelogin-aws-role/utils"
+)
+
+type AnomaliesCmd struct {
+	Address    string   `kong:"arg,optional,name='address',help='Hotspot address or name to report on'"`
+	Owner      []string `kong:"name='owner',help='Report on all hotspots owned by these wallet(s)'"`
+	Since      string   `kong:"name='since',default='30d',help='Start of the time range: YYYY-MM-DD, RFC3339, age (3d, 12h, 2w) or block height'"`
+	Until      string   `kong:"name='until',default='now',help='End of the time range: now, YYYY-MM-DD, RFC3339, age or block height'"`
+	MinShift   float64  `kong:"name='min-shift',default=5.0,help='Minimum change in mean RSSI/SNR (dB) to report'"`
+	MinSamples int      `kong:"name='min-samples',default=10,help='Minimum witnesses before & after a change'"`
+	GapFactor  float64  `kong:"name='gap-factor',default=3.0,help='Peer disappeared if silent this many times its usual interval'"`
+	Format     string   `kong:"name='format',short='f',default='table',enum='table,csv,json',help='Output format [table|csv|json]'"`
+	Output     string   `kong:"name='output',short='o',default='stdout',help='Output file for csv/json'"`
+}
+
+func (cmd *AnomaliesCmd) Run(ctx *RunContext) error {
+	cli := *ctx.Cli
+
+	firstTime, lastTime, err := ctx.BoltDB.ParseTimeRange(cli.Anomalies.Since, cli.Anomalies.Until, time.Now())
+	if err != nil {
+		return err
+	}
+
+	hotspots, err := resolveHotspots(ctx, cli.Anomalies.Address, cli.Anomalies.Owner)
+	if err != nil {
+		return err
+	}
+
+	settings := analysis.AnomalySettings{
+		MinShift:   cli.Anomalies.MinShift,
+		MinSamples: cli.Anomalies.MinSamples,
+		GapFactor:  cli.Anomalies.GapFactor,
+	}
+
+	ts := []utils.TableStruct{}
+	allEvents := map[string][]analysis.AnomalyEvent{} // keyed by hotspot address
+	for _, hotspotAddress := range hotspots {
+		challenges, err := ctx.BoltDB.GetChallenges(hotspotAddress, firstTime, lastTime)
+		if err != nil {
+			return err
+		}
+
+		events, err := ctx.BoltDB.FindAnomalies(hotspotAddress, challenges, lastTime, settings)
+		if err != nil {
+			return err
+		}
+
+		name, err := ctx.BoltDB.GetHotspotName(hotspotAddress)
+		if err != nil {
+			return err
+		}
+		allEvents[hotspotAddress] = events
+		for _, e := range events {
+			ts = append(ts, AnomalyReport{
+				Hotspot:   name,
+				Date:      analysis.FormatDate(time.Unix(e.Time, 0)),
+				Name:      e.Name,
+				Metric:    e.Metric,
+				Direction: e.Direction,
+				Before:    fmt.Sprintf("%.1f", e.Before),
+				After:     fmt.Sprintf("%.1f", e.After),
+				Delta:     fmt.Sprintf("%+.1f", e.Delta),
+				Message:   e.Message,
+			})
+		}
+	}
+
+	fields := []string{"Date", "Message"}
+	if cli.Anomalies.Format != "table" {
+		fields = []string{"Date", "Name", "Metric", "Direction", "Before", "After", "Delta", "Message"}
+	}
+	if len(hotspots) == 1 {
+		for _, events := range allEvents {
+			return writeReport(cli.Anomalies.Format, cli.Anomalies.Output, ts, fields, events)
+		}
+	}
+	fields = append([]string{"Hotspot"}, fields...)
+	return writeReport(cli.Anomalies.Format, cli.Anomalies.Output, ts, fields, allEvents)
+}
+
+// Necessary for utils.TableStruct magic
+type AnomalyReport struct {
+	Hotspot   string `header:"Hotspot"`
+	Date      string `header:"Date"`
+	Name      string `header:"Peer"`
+	Metric    string `header:"Metric"`
+	Direction string `header:"Direction"`
+	Before    string `header:"Before"`
+	After     string `header:"After"`
+	Delta     string `header:"Delta"`
+	Message   string `header:"Event"`
+}
+
+func (ar AnomalyReport) GetHeader(fieldName string) (string, error) {
+	v := reflect.ValueOf(ar)
+	return utils.GetHeaderTag(v, fieldName)
+}
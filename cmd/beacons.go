@@ -0,0 +1,105 @@
+package main
+
+/*
+ * Helium Analysis
+ * Copyright (c) 2021-2022 Aaron Turner  <aturner at synfin dot net>
+ *
+ * This program is free software: you can redistribute it
+ * and/or modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or with the authors permission any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+import (
+	"fmt"
+	"reflect"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/synfinatic/helium-analysis/analysis"
+	"github.com/synfinatic/onelogin-aws-role/utils"
+)
+
+type BeaconsCmd struct {
+	Address   string        `kong:"arg,required,name='address',help='Hotspot address or name to report on'"`
+	Since     string        `kong:"name='since',default='30d',help='Start of the time range: YYYY-MM-DD, RFC3339, age (3d, 12h, 2w) or block height'"`
+	Until     string        `kong:"name='until',default='now',help='End of the time range: now, YYYY-MM-DD, RFC3339, age or block height'"`
+	Network   time.Duration `kong:"name='network-interval',default='8h',help='Typical time between beacons on the network'"`
+	SkipGraph bool          `kong:"name='skip-graph',default=false,help='Do not generate the histogram'"`
+	Format    string        `kong:"name='format',short='f',default='table',enum='table,csv,json',help='Output format [table|csv|json]'"`
+	Output    string        `kong:"name='output',short='o',default='stdout',help='Output file for csv/json'"`
+}
+
+func (cmd *BeaconsCmd) Run(ctx *RunContext) error {
+	cli := *ctx.Cli
+
+	firstTime, lastTime, err := ctx.BoltDB.ParseTimeRange(cli.Beacons.Since, cli.Beacons.Until, time.Now())
+	if err != nil {
+		return err
+	}
+
+	hotspotAddress, err := ctx.BoltDB.GetHotspotByUnknown(cli.Beacons.Address)
+	if err != nil {
+		return err
+	}
+
+	challenges, err := ctx.BoltDB.GetChallenges(hotspotAddress, firstTime, lastTime)
+	if err != nil {
+		return err
+	}
+
+	cadence, err := analysis.GetBeaconCadence(hotspotAddress, challenges, lastTime, cli.Beacons.Network)
+	if err != nil {
+		return err
+	}
+
+	if !cli.Beacons.SkipGraph {
+		err = ctx.BoltDB.GenerateCadenceGraph(hotspotAddress, cadence, analysis.GraphSettings{Output: ctx.Output})
+		if err != nil {
+			log.WithError(err).Error("Unable to generate beacon cadence graph")
+		}
+	}
+
+	ts := []utils.TableStruct{
+		CadenceReport{"Beacons", fmt.Sprintf("%d", cadence.Beacons)},
+		CadenceReport{"First Beacon", analysis.FormatUnix(cadence.FirstBeacon)},
+		CadenceReport{"Last Beacon", analysis.FormatUnix(cadence.LastBeacon)},
+		CadenceReport{"Mean Interval", fmt.Sprintf("%.1fh", cadence.MeanInterval)},
+		CadenceReport{"Median Interval", fmt.Sprintf("%.1fh", cadence.MedianInterval)},
+		CadenceReport{"p90 Interval", fmt.Sprintf("%.1fh", cadence.P90Interval)},
+		CadenceReport{"Longest Gap", fmt.Sprintf("%.1fh starting %s", cadence.LongestGap,
+			analysis.FormatUnix(cadence.LongestGapStart))},
+		CadenceReport{"Current Gap", fmt.Sprintf("%.1fh", cadence.CurrentGap)},
+		CadenceReport{"Network Interval", fmt.Sprintf("%.1fh", cadence.NetworkInterval)},
+		CadenceReport{"vs. Network", fmt.Sprintf("%.2fx", cadence.NetworkRatio)},
+		CadenceReport{"Offline", fmt.Sprintf("%v", cadence.Offline)},
+	}
+	if err = writeReport(cli.Beacons.Format, cli.Beacons.Output, ts, []string{"Metric", "Value"}, cadence); err != nil {
+		return err
+	}
+
+	if cli.Beacons.Format == "table" && cadence.Offline {
+		fmt.Printf("No beacons for %.1fh which is longer than any previous gap.  "+
+			"The hotspot may be offline or unchallenged.\n", cadence.CurrentGap)
+	}
+	return nil
+}
+
+// Necessary for utils.TableStruct magic
+type CadenceReport struct {
+	Metric string `header:"Metric"`
+	Value  string `header:"Value"`
+}
+
+func (cr CadenceReport) GetHeader(fieldName string) (string, error) {
+	v := reflect.ValueOf(cr)
+	return utils.GetHeaderTag(v, fieldName)
+}
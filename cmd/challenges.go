@@ -53,15 +53,18 @@ type ChallengesImportCmd struct {
 }
 
 type ChallengesRefreshCmd struct {
-	Address string `kong:"arg,required,help='Hotspot name or address to refresh'"`
-	Days    int64  `kong:"name='days',short='d',default=30,help='Previous number of days to load'"`
-	Buffer  int64  `kong:"name='buffer',short='b',default=6,help='Challenge buffer in hours'"`
+	Address string   `kong:"arg,optional,help='Hotspot name or address to refresh'"`
+	Owner   []string `kong:"name='owner',help='Refresh all hotspots owned by these wallet(s)'"`
+	Since   string   `kong:"name='since',default='30d',help='Start of the time range: YYYY-MM-DD, RFC3339, age (3d, 12h, 2w) or block height'"`
+	Until   string   `kong:"name='until',default='now',help='End of the time range: now, YYYY-MM-DD, RFC3339, age or block height'"`
+	Days    int64    `kong:"name='days',short='d',hidden,help='Deprecated: use --since'"`
+	Buffer  int64    `kong:"name='buffer',short='b',default=6,help='Challenge buffer in hours'"`
 }
 
 type ChallengesDeleteCmd struct {
 	Address string `kong:"arg,required,help='Hotspot name or address to delete'"`
-	Before  string `kong:"name='before',short='b',help='Delete data before YYYY-MM-DD',xor='mode'"`
-	After   string `kong:"name='after',short='a',help='Delete data after YYYY-MM-DD',xor='mode'"`
+	Before  string `kong:"name='before',short='b',help='Delete data before YYYY-MM-DD (UTC), RFC3339, age (3d, 12h, 2w) or block height',xor='mode'"`
+	After   string `kong:"name='after',short='a',help='Delete data after YYYY-MM-DD (UTC), RFC3339, age (3d, 12h, 2w) or block height',xor='mode'"`
 }
 
 type ChallengesDeleteAllCmd struct {
@@ -69,7 +72,7 @@ type ChallengesDeleteAllCmd struct {
 }
 
 type ChallengesListCmd struct {
-	Local bool `kong:"name='localtime',default=false,help='Display in local time instead of UTC'"`
+	Local bool `kong:"name='localtime',hidden,help='Deprecated: use --tz Local'"`
 }
 
 // Export challenges for a hostspot as JSON
@@ -108,21 +111,32 @@ func (cmd *ChallengesExportCmd) Run(ctx *RunContext) error {
 func (cmd *ChallengesRefreshCmd) Run(ctx *RunContext) error {
 	cli := *ctx.Cli
 
-	// Is this a name or address of a hotspot?  Set `hotspotAddress`
-	hotspotAddress, err := ctx.BoltDB.GetHotspotByUnknown(cli.Challenges.Refresh.Address)
+	// Set `hotspots` from the name or address of a hotspot or the owner(s)
+	hotspots, err := resolveHotspots(ctx, cli.Challenges.Refresh.Address, cli.Challenges.Refresh.Owner)
 	if err != nil {
 		return err
 	}
 
-	daysOffset := time.Now().UTC().Unix() - (cli.Challenges.Refresh.Days * int64(24*60*60))
-	days := time.Unix(daysOffset, 0)
-	// go to the beginning of the day UTC
-	startDate := days.Format("2006-01-02")
-	firstTime, _ := time.Parse("2006-01-02", startDate)
-	lastTime := time.Now().UTC()
+	since, until, err := deprecatedRange(cli.Challenges.Refresh.Since, cli.Challenges.Refresh.Until, cli.Challenges.Refresh.Days, "")
+	if err != nil {
+		return err
+	}
+	firstTime, lastTime, err := ctx.BoltDB.ParseTimeRange(since, until, time.Now())
+	if err != nil {
+		return err
+	}
 	duration := time.Duration(time.Hour * time.Duration(cli.Challenges.Refresh.Buffer))
 
-	return ctx.BoltDB.LoadChallenges(hotspotAddress, firstTime, lastTime, duration)
+	for _, hotspotAddress := range hotspots {
+		err = ctx.BoltDB.LoadChallenges(hotspotAddress, firstTime, lastTime, duration)
+		if err != nil {
+			if len(hotspots) == 1 {
+				return err
+			}
+			log.WithError(err).Errorf("Unable to refresh challenges for %s", hotspotAddress)
+		}
+	}
+	return nil
 }
 
 // Import challenges stored in JSON for a hotspot into the DB
@@ -214,13 +228,13 @@ func (cmd *ChallengesDeleteCmd) Run(ctx *RunContext) error {
 	after := int64(0)
 
 	if cli.Challenges.Delete.Before != "" {
-		t, err := time.Parse("2006-01-02", cli.Challenges.Delete.Before)
+		t, err := parseDeleteTime(ctx.BoltDB, cli.Challenges.Delete.Before)
 		if err != nil {
 			return err
 		}
 		before = t.Unix()
 	} else if cli.Challenges.Delete.After != "" {
-		t, err := time.Parse("2006-01-02", cli.Challenges.Delete.After)
+		t, err := parseDeleteTime(ctx.BoltDB, cli.Challenges.Delete.After)
 		if err != nil {
 			return err
 		}
@@ -261,9 +275,32 @@ func (cmd *ChallengesDeleteCmd) Run(ctx *RunContext) error {
 	return err
 }
 
+// Dates are always the start of the UTC day so the same command deletes the
+// same challenges no matter where it is run
+func parseDeleteTime(b *analysis.BoltDB, value string) (time.Time, error) {
+	if t, err := time.Parse(analysis.DATE_LAYOUT, value); err == nil {
+		return t, nil
+	}
+	t, _, err := b.ParseTime(value, time.Now())
+	return t, err
+}
+
 // List all of the hotspots we have challenges for
 func (cmd *ChallengesListCmd) Run(ctx *RunContext) error {
 	cli := *ctx.Cli
+
+	// challenges list has always been in UTC
+	tz := cli.Timezone
+	if cli.Challenges.List.Local {
+		log.Warnf("--localtime is deprecated and will be removed.  Use --tz Local instead")
+		tz = "Local"
+	} else if tz == "" {
+		tz = "UTC"
+	}
+	if err := analysis.SetTimeDisplay(tz, cli.Clock); err != nil {
+		return err
+	}
+
 	db := ctx.BoltDB.GetDb()
 	report := []ChallengeReport{}
 
@@ -297,25 +334,13 @@ func (cmd *ChallengesListCmd) Run(ctx *RunContext) error {
 				firstInt := int64(binary.BigEndian.Uint64(first))
 				lastInt := int64(binary.BigEndian.Uint64(last))
 
-				if cli.Challenges.List.Local {
-					// Local time
-					report = append(report, ChallengeReport{
-						Name:    name,
-						Address: address,
-						First:   time.Unix(firstInt, 0).Format(analysis.TIME_FORMAT),
-						Last:    time.Unix(lastInt, 0).Format(analysis.TIME_FORMAT),
-						Records: int64(stats.KeyN),
-					})
-				} else {
-					// UTC Time
-					report = append(report, ChallengeReport{
-						Name:    name,
-						Address: address,
-						First:   time.Unix(firstInt, 0).UTC().Format(analysis.TIME_FORMAT),
-						Last:    time.Unix(lastInt, 0).UTC().Format(analysis.TIME_FORMAT),
-						Records: int64(stats.KeyN),
-					})
-				}
+				report = append(report, ChallengeReport{
+					Name:    name,
+					Address: address,
+					First:   analysis.FormatUnix(firstInt),
+					Last:    analysis.FormatUnix(lastInt),
+					Records: int64(stats.KeyN),
+				})
 			}
 		}
 
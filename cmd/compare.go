@@ -0,0 +1,213 @@
+package main
+
+/*
+ * Helium Analysis
+ * Copyright (c) 2021-2022 Aaron Turner  <aturner at synfin dot net>
+ *
+ * This program is free software: you can redistribute it
+ * and/or modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or with the authors permission any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/synfinatic/helium-analysis/analysis"
+	"github.com/synfinatic/onelogin-aws-role/utils"
+)
+
+type CompareCmd struct {
+	Address   string `kong:"arg,required,name='address',help='Hotspot address or name to report on'"`
+	Split     string `kong:"required,name='split',help='Compare before & after YYYY-MM-DD, RFC3339, age (3d, 12h, 2w) or block height'"`
+	Window    string `kong:"name='window',short='w',default='30d',help='Time before & after the split to compare (3d, 12h, 2w)'"`
+	Minimum   int    `kong:"name='minimum',short='m',default=5,help='Minimum required witnesses to graph a peer'"`
+	SkipGraph bool   `kong:"name='skip-graph',default=false,help='Do not generate the compare graph'"`
+	Format    string `kong:"name='format',short='f',default='table',enum='table,csv,json',help='Output format [table|csv|json]'"`
+	Output    string `kong:"name='output',short='o',default='stdout',help='Output file for csv/json'"`
+}
+
+func (cmd *CompareCmd) Run(ctx *RunContext) error {
+	cli := *ctx.Cli
+
+	window, err := analysis.ParseAge(cli.Compare.Window)
+	if err != nil {
+		return err
+	} else if window < time.Hour {
+		return fmt.Errorf("Please specify a --window >= 1h")
+	}
+	split, _, err := ctx.BoltDB.ParseTime(cli.Compare.Split, time.Now())
+	if err != nil {
+		return err
+	}
+	firstTime := split.Add(-window)
+	lastTime := split.Add(window)
+	if lastTime.After(time.Now().UTC()) {
+		lastTime = time.Now().UTC()
+	}
+
+	hotspotAddress, err := ctx.BoltDB.GetHotspotByUnknown(cli.Compare.Address)
+	if err != nil {
+		return err
+	}
+
+	challenges, err := ctx.BoltDB.GetChallenges(hotspotAddress, firstTime, lastTime)
+	if err != nil {
+		return err
+	}
+	before, after := analysis.SplitChallenges(challenges, split)
+	if len(before) == 0 || len(after) == 0 {
+		return fmt.Errorf("Need challenges before and after %s.  Have %d before and %d after",
+			cli.Compare.Split, len(before), len(after))
+	}
+
+	report, err := ctx.BoltDB.Compare(hotspotAddress, before, after, split)
+	if err != nil {
+		return err
+	}
+
+	if !cli.Compare.SkipGraph {
+		settings := analysis.GraphSettings{
+			Min:    cli.Compare.Minimum,
+			Output: ctx.Output,
+		}
+		err = ctx.BoltDB.GenerateCompareGraph(hotspotAddress, report, settings)
+		if err != nil {
+			log.WithError(err).Error("Unable to generate compare graph")
+		}
+	}
+
+	ts := []utils.TableStruct{}
+	for _, p := range report.Peers {
+		ts = append(ts, PeerCompareReport{
+			Name:        p.Name,
+			Km:          fmt.Sprintf("%.02f", p.Km),
+			Status:      p.Status,
+			CountBefore: int64(p.CountBefore),
+			CountAfter:  int64(p.CountAfter),
+			RssiBefore:  fmt.Sprintf("%.01f", p.RssiBefore),
+			RssiAfter:   fmt.Sprintf("%.01f", p.RssiAfter),
+			RssiDelta:   fmt.Sprintf("%+.01f", p.RssiDelta),
+			SnrDelta:    fmt.Sprintf("%+.01f", p.SnrDelta),
+			ValidBefore: fmt.Sprintf("%.0f%%", p.ValidBefore*100.0),
+			ValidAfter:  fmt.Sprintf("%.0f%%", p.ValidAfter*100.0),
+			PValue:      formatPValue(p.RssiPValue),
+		})
+	}
+	fields := []string{
+		"Name",
+		"Km",
+		"Status",
+		"CountBefore",
+		"CountAfter",
+		"RssiBefore",
+		"RssiAfter",
+		"RssiDelta",
+		"SnrDelta",
+		"ValidBefore",
+		"ValidAfter",
+		"PValue",
+	}
+
+	if cli.Compare.Format != "table" {
+		return writeReport(cli.Compare.Format, cli.Compare.Output, ts, fields, report)
+	}
+
+	b := report.Before
+	a := report.After
+	summary := []utils.TableStruct{
+		newCompareSummary("Beacons", float64(b.Beacons), float64(a.Beacons), "%.0f", -1.0),
+		newCompareSummary("Witnesses/Beacon", b.WitnessesPerBeacon, a.WitnessesPerBeacon, "%.02f", report.WitnessPValue),
+		newCompareSummary("Peers", float64(b.Peers), float64(a.Peers), "%.0f", -1.0),
+		newCompareSummary("Mean RSSI", b.RssiMean, a.RssiMean, "%.01f", report.RssiPValue),
+		newCompareSummary("Mean SNR", b.SnrMean, a.SnrMean, "%.01f", report.SnrPValue),
+		newCompareSummary("Valid %", b.ValidRatio*100.0, a.ValidRatio*100.0, "%.01f", -1.0),
+	}
+	utils.GenerateTable(summary, []string{"Metric", "Before", "After", "Delta", "PValue", "Significant"})
+	fmt.Printf("\n")
+
+	if err = writeReport("table", "stdout", ts, fields, report); err != nil {
+		return err
+	}
+	if len(report.Gained) > 0 {
+		fmt.Printf("Gained peers: %s\n", strings.Join(report.Gained, ", "))
+	}
+	if len(report.Lost) > 0 {
+		fmt.Printf("Lost peers: %s\n", strings.Join(report.Lost, ", "))
+	}
+	return nil
+}
+
+// returns the p-value as a string, or n/a if there wasn't enough data
+func formatPValue(p float64) string {
+	if p < 0.0 {
+		return "n/a"
+	}
+	return fmt.Sprintf("%.03f", p)
+}
+
+// Necessary for utils.TableStruct magic
+type CompareSummary struct {
+	Metric      string `header:"Metric"`
+	Before      string `header:"Before"`
+	After       string `header:"After"`
+	Delta       string `header:"Delta"`
+	PValue      string `header:"p-value"`
+	Significant string `header:"Significant"`
+}
+
+func newCompareSummary(metric string, before, after float64, format string, p float64) CompareSummary {
+	significant := "n/a"
+	if p >= 0.0 {
+		significant = "no"
+		if p < analysis.SIGNIFICANCE_LEVEL {
+			significant = "yes"
+		}
+	}
+	return CompareSummary{
+		Metric:      metric,
+		Before:      fmt.Sprintf(format, before),
+		After:       fmt.Sprintf(format, after),
+		Delta:       fmt.Sprintf(strings.Replace(format, "%", "%+", 1), after-before),
+		PValue:      formatPValue(p),
+		Significant: significant,
+	}
+}
+
+func (cs CompareSummary) GetHeader(fieldName string) (string, error) {
+	v := reflect.ValueOf(cs)
+	return utils.GetHeaderTag(v, fieldName)
+}
+
+// Necessary for utils.TableStruct magic
+type PeerCompareReport struct {
+	Name        string `header:"Name"`
+	Km          string `header:"Km"`
+	Status      string `header:"Status"`
+	CountBefore int64  `header:"# Before"`
+	CountAfter  int64  `header:"# After"`
+	RssiBefore  string `header:"RSSI Before"`
+	RssiAfter   string `header:"RSSI After"`
+	RssiDelta   string `header:"RSSI Delta"`
+	SnrDelta    string `header:"SNR Delta"`
+	ValidBefore string `header:"Valid Before"`
+	ValidAfter  string `header:"Valid After"`
+	PValue      string `header:"p-value"`
+}
+
+func (pcr PeerCompareReport) GetHeader(fieldName string) (string, error) {
+	v := reflect.ValueOf(pcr)
+	return utils.GetHeaderTag(v, fieldName)
+}
@@ -0,0 +1,192 @@
+package main
+
+/*
+ * Helium Analysis
+ * Copyright (c) 2021-2022 Aaron Turner  <aturner at synfin dot net>
+ *
+ * This program is free software: you can redistribute it
+ * and/or modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or with the authors permission any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	"github.com/alecthomas/kong"
+	"github.com/synfinatic/helium-analysis/analysis"
+	"gopkg.in/yaml.v2"
+)
+
+const CONFIG_FILE = "~/.helium-analysis.yaml"
+
+/*
+ * Top level keys in the config file which match a flag name (with '-'
+ * replaced by '_') set the default for that flag on every command.  Flags on
+ * the command line always win.  The sections below are not flags.
+ */
+type ConfigFile struct {
+	ApiUrl   string                 `yaml:"api_url"`
+	Hotspots map[string]string      `yaml:"hotspots"` // alias => hotspot name or address
+	Graph    GraphConfig            `yaml:"graph"`    // all graphs
+	Graphs   map[string]GraphConfig `yaml:"graphs"`   // graph name => overrides of graph
+}
+
+// Unset values use the default graph style
+type GraphConfig struct {
+	Width   int               `yaml:"width"`
+	Height  int               `yaml:"height"`
+	YMin    *float64          `yaml:"y_min"`
+	YMax    *float64          `yaml:"y_max"`
+	SnrMin  *float64          `yaml:"snr_min"`
+	SnrMax  *float64          `yaml:"snr_max"`
+	DotSize float64           `yaml:"dot_size"`
+	Colors  map[string]string `yaml:"colors"` // name => hex color
+}
+
+// loaded by yamlLoader when kong parses the command line
+var fileConfig = ConfigFile{}
+
+// kong.ConfigurationLoader for YAML config files
+func yamlLoader(r io.Reader) (kong.Resolver, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	values := map[string]interface{}{}
+	if err = yaml.Unmarshal(data, &values); err != nil {
+		return nil, fmt.Errorf("Unable to parse config: %s", err)
+	}
+	// later files override earlier ones
+	if err = yaml.Unmarshal(data, &fileConfig); err != nil {
+		return nil, fmt.Errorf("Unable to parse config: %s", err)
+	}
+
+	var f kong.ResolverFunc = func(context *kong.Context, parent *kong.Path, flag *kong.Flag) (interface{}, error) {
+		raw, ok := values[strings.ReplaceAll(flag.Name, "-", "_")]
+		if !ok {
+			return nil, nil
+		}
+		// let kong parse the value just like it came from the command line
+		if list, ok := raw.([]interface{}); ok {
+			vals := []string{}
+			for _, v := range list {
+				vals = append(vals, fmt.Sprintf("%v", v))
+			}
+			return strings.Join(vals, ","), nil
+		}
+		return fmt.Sprintf("%v", raw), nil
+	}
+	return f, nil
+}
+
+// Apply the non-flag settings of the config file
+func applyConfig(db *analysis.BoltDB) error {
+	if fileConfig.ApiUrl != "" {
+		if err := analysis.SetApiUrl(fileConfig.ApiUrl); err != nil {
+			return err
+		}
+	}
+
+	db.SetAliases(fileConfig.Hotspots)
+
+	style, err := fileConfig.Graph.apply(analysis.DefaultGraphStyle())
+	if err != nil {
+		return err
+	}
+	if err = analysis.SetGraphStyle(style); err != nil {
+		return err
+	}
+	for graph, g := range fileConfig.Graphs {
+		s, err := g.apply(style)
+		if err != nil {
+			return fmt.Errorf("graphs %s: %s", graph, err)
+		}
+		if err = analysis.SetGraphStyleFor(graph, s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Returns the graph style with the configured overrides
+func (g GraphConfig) apply(s analysis.GraphStyle) (analysis.GraphStyle, error) {
+	if g.Width > 0 {
+		s.Width = g.Width
+	}
+	if g.Height > 0 {
+		s.Height = g.Height
+	}
+	if g.YMin != nil {
+		s.YMin = *g.YMin
+	}
+	if g.YMax != nil {
+		s.YMax = *g.YMax
+	}
+	if g.SnrMin != nil {
+		s.SnrMin = *g.SnrMin
+	}
+	if g.SnrMax != nil {
+		s.SnrMax = *g.SnrMax
+	}
+	if g.DotSize > 0 {
+		s.DotSize = g.DotSize
+	}
+
+	for name, hex := range g.Colors {
+		color, err := analysis.ParseColor(hex)
+		if err != nil {
+			return s, fmt.Errorf("graph color %s: %s", name, err)
+		}
+		switch name {
+		case "valid":
+			s.Colors.Valid = color
+		case "invalid":
+			s.Colors.Invalid = color
+		case "tx":
+			s.Colors.Tx = color
+		case "tx_invalid":
+			s.Colors.TxInvalid = color
+		case "rx":
+			s.Colors.Rx = color
+		case "rx_invalid":
+			s.Colors.RxInvalid = color
+		case "min_valid":
+			s.Colors.MinValid = color
+		case "max_valid":
+			s.Colors.MaxValid = color
+		case "snr":
+			s.Colors.Snr = color
+		default:
+			return s, fmt.Errorf("Unknown graph color: %s", name)
+		}
+	}
+	return s, nil
+}
+
+// Returns the given hotspots or, if neither hotspots nor owners were given,
+// the aliases of all the hotspots in the config file
+func trackedHotspots(hotspots, owners []string) []string {
+	if len(hotspots) > 0 || len(owners) > 0 {
+		return hotspots
+	}
+	aliases := []string{}
+	for alias := range fileConfig.Hotspots {
+		aliases = append(aliases, alias)
+	}
+	sort.Strings(aliases)
+	return aliases
+}
@@ -0,0 +1,118 @@
+package main
+
+/*
+ * Helium Analysis
+ * Copyright (c) 2021-2022 Aaron Turner  <aturner at synfin dot net>
+ *
+ * This program is free software: you can redistribute it
+ * and/or modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or with the authors permission any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+import (
+	"fmt"
+	"reflect"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/synfinatic/helium-analysis/analysis"
+	"github.com/synfinatic/onelogin-aws-role/utils"
+)
+
+type CoverageCmd struct {
+	Address   string `kong:"arg,required,name='address',help='Hotspot address or name to report on'"`
+	Since     string `kong:"name='since',default='30d',help='Start of the time range: YYYY-MM-DD, RFC3339, age (3d, 12h, 2w) or block height'"`
+	Until     string `kong:"name='until',default='now',help='End of the time range: now, YYYY-MM-DD, RFC3339, age or block height'"`
+	Sectors   int    `kong:"name='sectors',short='S',default=8,help='Number of compass sectors [4|8|16]'"`
+	SkipGraph bool   `kong:"name='skip-graph',default=false,help='Do not generate the polar graphs'"`
+	Format    string `kong:"name='format',short='f',default='table',enum='table,csv,json',help='Output format [table|csv|json]'"`
+	Output    string `kong:"name='output',short='o',default='stdout',help='Output file for csv/json'"`
+}
+
+func (cmd *CoverageCmd) Run(ctx *RunContext) error {
+	cli := *ctx.Cli
+
+	firstTime, lastTime, err := ctx.BoltDB.ParseTimeRange(cli.Coverage.Since, cli.Coverage.Until, time.Now())
+	if err != nil {
+		return err
+	}
+
+	hotspotAddress, err := ctx.BoltDB.GetHotspotByUnknown(cli.Coverage.Address)
+	if err != nil {
+		return err
+	}
+
+	challenges, err := ctx.BoltDB.GetChallenges(hotspotAddress, firstTime, lastTime)
+	if err != nil {
+		return err
+	}
+
+	coverage, err := ctx.BoltDB.GetCoverage(hotspotAddress, challenges, cli.Coverage.Sectors)
+	if err != nil {
+		return err
+	}
+
+	if !cli.Coverage.SkipGraph {
+		err = ctx.BoltDB.GenerateCoverageGraphs(hotspotAddress, coverage, analysis.GraphSettings{Output: ctx.Output})
+		if err != nil {
+			log.WithError(err).Error("Unable to generate coverage graphs")
+		}
+	}
+
+	ts := []utils.TableStruct{}
+	for _, c := range coverage {
+		ts = append(ts, CoverageReport{
+			Sector:         c.Sector,
+			Range:          fmt.Sprintf("%.0f-%.0f", c.Start, c.End),
+			Peers:          int64(c.Peers),
+			Tx:             int64(c.TxCount),
+			Rx:             int64(c.RxCount),
+			Valid:          int64(c.ValidCount),
+			Invalid:        int64(c.Invalid),
+			WitnessSuccess: fmt.Sprintf("%.01f%%", c.WitnessSuccess*100.0),
+			RssiMean:       fmt.Sprintf("%.01f", c.RssiMean),
+			MaxKm:          fmt.Sprintf("%.02f", c.MaxKm),
+		})
+	}
+	fields := []string{
+		"Sector",
+		"Range",
+		"Peers",
+		"Tx",
+		"Rx",
+		"Valid",
+		"Invalid",
+		"WitnessSuccess",
+		"RssiMean",
+		"MaxKm",
+	}
+	return writeReport(cli.Coverage.Format, cli.Coverage.Output, ts, fields, coverage)
+}
+
+// Necessary for utils.TableStruct magic
+type CoverageReport struct {
+	Sector         string `header:"Sector"`
+	Range          string `header:"Degrees"`
+	Peers          int64  `header:"Peers"`
+	Tx             int64  `header:"TX"`
+	Rx             int64  `header:"RX"`
+	Valid          int64  `header:"Valid"`
+	Invalid        int64  `header:"Invalid"`
+	WitnessSuccess string `header:"Witness Success"`
+	RssiMean       string `header:"RSSI Mean"`
+	MaxKm          string `header:"Max Km"`
+}
+
+func (cr CoverageReport) GetHeader(fieldName string) (string, error) {
+	v := reflect.ValueOf(cr)
+	return utils.GetHeaderTag(v, fieldName)
+}
@@ -0,0 +1,140 @@
+package main
+
+/*
+ * Helium Analysis
+ * Copyright (c) 2021-2022 Aaron Turner  <aturner at synfin dot net>
+ *
+ * This program is free software: you can redistribute it
+ * and/or modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or with the authors permission any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+import (
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/synfinatic/helium-analysis/analysis"
+	"github.com/synfinatic/onelogin-aws-role/utils"
+)
+
+type ExplainCmd struct {
+	Address string `kong:"arg,required,name='address',help='Hotspot address or name to report on'"`
+	Peer    string `kong:"arg,optional,name='peer',help='Only explain witnesses with this peer address or name'"`
+	Since   string `kong:"name='since',default='30d',help='Start of the time range: YYYY-MM-DD, RFC3339, age (3d, 12h, 2w) or block height'"`
+	Until   string `kong:"name='until',default='now',help='End of the time range: now, YYYY-MM-DD, RFC3339, age or block height'"`
+	Format  string `kong:"name='format',short='f',default='table',enum='table,csv,json',help='Output format [table|csv|json]'"`
+	Output  string `kong:"name='output',short='o',default='stdout',help='Output file for csv/json'"`
+}
+
+func (cmd *ExplainCmd) Run(ctx *RunContext) error {
+	cli := *ctx.Cli
+
+	firstTime, lastTime, err := ctx.BoltDB.ParseTimeRange(cli.Explain.Since, cli.Explain.Until, time.Now())
+	if err != nil {
+		return err
+	}
+
+	hotspotAddress, err := ctx.BoltDB.GetHotspotByUnknown(cli.Explain.Address)
+	if err != nil {
+		return err
+	}
+
+	peerAddress := ""
+	if cli.Explain.Peer != "" {
+		peerAddress, err = ctx.BoltDB.GetHotspotByUnknown(cli.Explain.Peer)
+		if err != nil {
+			return err
+		}
+	}
+
+	challenges, err := ctx.BoltDB.GetChallenges(hotspotAddress, firstTime, lastTime)
+	if err != nil {
+		return err
+	}
+
+	report, err := ctx.BoltDB.Explain(hotspotAddress, peerAddress, challenges)
+	if err != nil {
+		return err
+	}
+
+	ts := []utils.TableStruct{}
+	for _, p := range report.Peers {
+		ts = append(ts, newExplainReport(p))
+	}
+	if len(report.Peers) > 1 {
+		ts = append(ts, newExplainReport(report.Totals))
+	}
+	fields := []string{
+		"Name",
+		"Km",
+		"Total",
+		"Invalid",
+		"TxInvalid",
+		"RxInvalid",
+		"TooStrong",
+		"BelowSnr",
+		"TooClose",
+		"Unknown",
+	}
+
+	if cli.Explain.Format != "table" {
+		return writeReport(cli.Explain.Format, cli.Explain.Output, ts, fields, report)
+	}
+
+	if err = writeReport("table", "stdout", ts, fields, report); err != nil {
+		return err
+	}
+	fmt.Printf("Suggestions:\n")
+	for _, s := range report.Suggestions {
+		fmt.Printf(" * %s\n", s)
+	}
+	return nil
+}
+
+// Necessary for utils.TableStruct magic
+type ExplainReport struct {
+	Name      string `header:"Name"`
+	Km        string `header:"Km"`
+	Total     int64  `header:"Total"`
+	Invalid   int64  `header:"Invalid"`
+	TxInvalid int64  `header:"TX Invalid"`
+	RxInvalid int64  `header:"RX Invalid"`
+	TooStrong int64  `header:"Too Strong"`
+	BelowSnr  int64  `header:"Below SNR Floor"`
+	TooClose  int64  `header:"Too Close"`
+	Unknown   int64  `header:"Unknown"`
+}
+
+func newExplainReport(e analysis.ExplainPeer) ExplainReport {
+	km := fmt.Sprintf("%.02f", e.Km)
+	if e.Name == "Total" {
+		km = ""
+	}
+	return ExplainReport{
+		Name:      e.Name,
+		Km:        km,
+		Total:     int64(e.Total),
+		Invalid:   int64(e.Invalid),
+		TxInvalid: int64(e.TxInvalid),
+		RxInvalid: int64(e.RxInvalid),
+		TooStrong: int64(e.TooStrong),
+		BelowSnr:  int64(e.BelowSnr),
+		TooClose:  int64(e.TooClose),
+		Unknown:   int64(e.Unknown),
+	}
+}
+
+func (er ExplainReport) GetHeader(fieldName string) (string, error) {
+	v := reflect.ValueOf(er)
+	return utils.GetHeaderTag(v, fieldName)
+}
@@ -0,0 +1,100 @@
+package main
+
+/*
+ * Helium Analysis
+ * Copyright (c) 2021-2022 Aaron Turner  <aturner at synfin dot net>
+ *
+ * This program is free software: you can redistribute it
+ * and/or modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or with the authors permission any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/synfinatic/helium-analysis/analysis"
+)
+
+const PROMETHEUS_CONTENT_TYPE = "text/plain; version=0.0.4; charset=utf-8"
+
+type ExporterCmd struct {
+	Address []string      `kong:"arg,optional,name='address',help='Hotspot address(es) or name(s) to export (default: config file hotspots or all with challenges)'"`
+	Owner   []string      `kong:"name='owner',help='Export all hotspots owned by these wallet(s)'"`
+	Listen  string        `kong:"name='listen',short='l',default=':9110',help='Address & port to listen on'"`
+	Window  time.Duration `kong:"name='window',short='w',default='24h',help='Sliding window for the beacon, witness & peer metrics'"`
+}
+
+func (cmd *ExporterCmd) Run(ctx *RunContext) error {
+	cli := *ctx.Cli
+
+	if cli.Exporter.Window < time.Hour {
+		return fmt.Errorf("Please specify a --window >= 1h")
+	}
+
+	m := &metricsHandler{
+		ctx:       ctx,
+		lock:      &sync.Mutex{},
+		addresses: trackedHotspots(cli.Exporter.Address, cli.Exporter.Owner),
+		owners:    cli.Exporter.Owner,
+		window:    cli.Exporter.Window,
+	}
+	// make sure the hotspots are valid before we start
+	if _, err := m.hotspots(); err != nil {
+		return err
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", m)
+	log.Infof("Serving metrics on %s/metrics", cli.Exporter.Listen)
+	return listenAndServe(cli.Exporter.Listen, logRequests(mux))
+}
+
+// Serves the Prometheus metrics calculated from the DB on every scrape
+type metricsHandler struct {
+	ctx       *RunContext
+	lock      *sync.Mutex // shared with any other handlers using the DB
+	addresses []string
+	owners    []string
+	window    time.Duration
+}
+
+// returns the hotspots to export, defaulting to every hotspot with challenges
+func (m *metricsHandler) hotspots() ([]string, error) {
+	if len(m.addresses) == 0 && len(m.owners) == 0 {
+		return m.ctx.BoltDB.GetChallengeHotspots()
+	}
+	return resolveHotspotList(m.ctx, m.addresses, m.owners)
+}
+
+func (m *metricsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	hotspots, err := m.hotspots()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	metrics, err := m.ctx.BoltDB.GetMetrics(hotspots, m.window, time.Now().UTC())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", PROMETHEUS_CONTENT_TYPE)
+	if err = analysis.WritePrometheus(w, metrics); err != nil {
+		log.WithError(err).Errorf("Unable to write metrics")
+	}
+}
@@ -0,0 +1,267 @@
+package main
+
+/*
+ * Helium Analysis
+ * Copyright (c) 2021-2022 Aaron Turner  <aturner at synfin dot net>
+ *
+ * This program is free software: you can redistribute it
+ * and/or modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or with the authors permission any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+import (
+	"fmt"
+	"reflect"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/synfinatic/helium-analysis/analysis"
+	"github.com/synfinatic/onelogin-aws-role/utils"
+)
+
+type FleetCmd struct {
+	Overlap FleetOverlapCmd `kong:"cmd,help='Report shared witnesses between every pair of hotspots in the fleet'"`
+	Summary FleetSummaryCmd `kong:"cmd,help='Summarize every hotspot owned by the given wallet(s)'"`
+}
+
+type FleetOverlapCmd struct {
+	Owner     []string `kong:"required,name='owner',help='Wallet address(es) of the fleet'"`
+	Since     string   `kong:"name='since',default='30d',help='Start of the time range: YYYY-MM-DD, RFC3339, age (3d, 12h, 2w) or block height'"`
+	Until     string   `kong:"name='until',default='now',help='End of the time range: now, YYYY-MM-DD, RFC3339, age or block height'"`
+	SkipGraph bool     `kong:"name='skip-graph',default=false,help='Do not generate the heatmap'"`
+	Format    string   `kong:"name='format',short='f',default='table',enum='table,csv,json',help='Output format [table|csv|json]'"`
+	Output    string   `kong:"name='output',short='o',default='stdout',help='Output file for csv/json'"`
+}
+
+type FleetSummaryCmd struct {
+	Owner  []string `kong:"required,name='owner',help='Wallet address(es) of the fleet'"`
+	Since  string   `kong:"name='since',default='30d',help='Start of the time range: YYYY-MM-DD, RFC3339, age (3d, 12h, 2w) or block height'"`
+	Until  string   `kong:"name='until',default='now',help='End of the time range: now, YYYY-MM-DD, RFC3339, age or block height'"`
+	Format string   `kong:"name='format',short='f',default='table',enum='table,csv,json',help='Output format [table|csv|json]'"`
+	Output string   `kong:"name='output',short='o',default='stdout',help='Output file for csv/json'"`
+}
+
+// Returns the list of hotspot addresses for the given hotspot name/address
+// or all of the hotspots owned by the given wallet(s)
+func resolveHotspots(ctx *RunContext, address string, owners []string) ([]string, error) {
+	if address != "" && len(owners) > 0 {
+		return []string{}, fmt.Errorf("Please specify a hotspot or --owner, not both")
+	}
+
+	if len(owners) > 0 {
+		hotspots, err := ctx.BoltDB.GetHotspotsByOwner(owners)
+		if err != nil {
+			return []string{}, err
+		}
+		addresses := []string{}
+		for _, h := range hotspots {
+			addresses = append(addresses, h.Address)
+		}
+		return addresses, nil
+	}
+
+	if address == "" {
+		return []string{}, fmt.Errorf("Please specify a hotspot or --owner")
+	}
+	hotspotAddress, err := ctx.BoltDB.GetHotspotByUnknown(address)
+	if err != nil {
+		return []string{}, err
+	}
+	return []string{hotspotAddress}, nil
+}
+
+// Returns the unique addresses of the given hotspot addresses or names and
+// all the hotspots owned by the given wallets
+func resolveHotspotList(ctx *RunContext, addresses []string, owners []string) ([]string, error) {
+	hotspots := []string{}
+	seen := map[string]bool{}
+
+	for _, address := range addresses {
+		hotspotAddress, err := ctx.BoltDB.GetHotspotByUnknown(address)
+		if err != nil {
+			return hotspots, err
+		}
+		if !seen[hotspotAddress] {
+			seen[hotspotAddress] = true
+			hotspots = append(hotspots, hotspotAddress)
+		}
+	}
+
+	if len(owners) > 0 {
+		owned, err := ctx.BoltDB.GetHotspotsByOwner(owners)
+		if err != nil {
+			return hotspots, err
+		}
+		for _, h := range owned {
+			if !seen[h.Address] {
+				seen[h.Address] = true
+				hotspots = append(hotspots, h.Address)
+			}
+		}
+	}
+	return hotspots, nil
+}
+
+func (cmd *FleetSummaryCmd) Run(ctx *RunContext) error {
+	cli := *ctx.Cli
+
+	firstTime, lastTime, err := ctx.BoltDB.ParseTimeRange(cli.Fleet.Summary.Since, cli.Fleet.Summary.Until, time.Now())
+	if err != nil {
+		return err
+	}
+
+	hotspots, err := ctx.BoltDB.GetHotspotsByOwner(cli.Fleet.Summary.Owner)
+	if err != nil {
+		return err
+	}
+
+	fleet := []analysis.FleetStats{}
+	ts := []utils.TableStruct{}
+	for _, h := range hotspots {
+		challenges, err := ctx.BoltDB.GetChallenges(h.Address, firstTime, lastTime)
+		if err != nil {
+			return err
+		}
+		stats := analysis.GetFleetStats(h, challenges)
+		fleet = append(fleet, stats)
+
+		validRatio := "n/a"
+		if stats.Witnessed > 0 {
+			validRatio = fmt.Sprintf("%.01f%%", stats.ValidRatio*100.0)
+		}
+		lastActivity := "never"
+		if stats.LastActivity > 0 {
+			lastActivity = analysis.FormatUnix(stats.LastActivity)
+		}
+		ts = append(ts, FleetReport{
+			Name:            stats.Name,
+			Owner:           stats.Owner,
+			Online:          stats.Online,
+			Beacons:         int64(stats.Beacons),
+			BeaconWitnesses: int64(stats.BeaconWitnesses),
+			Witnessed:       int64(stats.Witnessed),
+			ValidRatio:      validRatio,
+			LastActivity:    lastActivity,
+		})
+	}
+	fields := []string{
+		"Name",
+		"Owner",
+		"Online",
+		"Beacons",
+		"BeaconWitnesses",
+		"Witnessed",
+		"ValidRatio",
+		"LastActivity",
+	}
+	return writeReport(cli.Fleet.Summary.Format, cli.Fleet.Summary.Output, ts, fields, fleet)
+}
+
+// Necessary for utils.TableStruct magic
+type FleetReport struct {
+	Name            string `header:"Name"`
+	Owner           string `header:"Owner"`
+	Online          string `header:"Status"`
+	Beacons         int64  `header:"Beacons"`
+	BeaconWitnesses int64  `header:"Beacon Witnesses"`
+	Witnessed       int64  `header:"Witnessed"`
+	ValidRatio      string `header:"Valid"`
+	LastActivity    string `header:"Last Activity"`
+}
+
+func (fr FleetReport) GetHeader(fieldName string) (string, error) {
+	v := reflect.ValueOf(fr)
+	return utils.GetHeaderTag(v, fieldName)
+}
+
+const (
+	FLEET_DIRECTORY = "fleet" // used as the {hotspot} of the fleet graphs
+)
+
+func (cmd *FleetOverlapCmd) Run(ctx *RunContext) error {
+	cli := *ctx.Cli
+
+	firstTime, lastTime, err := ctx.BoltDB.ParseTimeRange(cli.Fleet.Overlap.Since, cli.Fleet.Overlap.Until, time.Now())
+	if err != nil {
+		return err
+	}
+
+	hotspots, err := ctx.BoltDB.GetHotspotsByOwner(cli.Fleet.Overlap.Owner)
+	if err != nil {
+		return err
+	}
+
+	challenges := map[string][]analysis.Challenges{}
+	for _, h := range hotspots {
+		challenges[h.Address], err = ctx.BoltDB.GetChallenges(h.Address, firstTime, lastTime)
+		if err != nil {
+			return err
+		}
+	}
+
+	overlap, err := analysis.GetFleetOverlap(hotspots, challenges)
+	if err != nil {
+		return err
+	}
+
+	if !cli.Fleet.Overlap.SkipGraph {
+		err = analysis.GenerateFleetOverlapGraph(FLEET_DIRECTORY, overlap, analysis.GraphSettings{Output: ctx.Output})
+		if err != nil {
+			log.WithError(err).Error("Unable to generate fleet overlap graph")
+		}
+	}
+
+	ts := []utils.TableStruct{}
+	for _, p := range overlap.Pairs {
+		ts = append(ts, OverlapReport{
+			A:         p.A,
+			B:         p.B,
+			Km:        fmt.Sprintf("%.02f", p.Km),
+			Shared:    int64(p.Shared),
+			Overlap:   fmt.Sprintf("%.0f%%", p.Overlap*100.0),
+			AHeardByB: int64(p.AHeardByB),
+			BHeardByA: int64(p.BHeardByA),
+			Mutual:    p.Mutual,
+			Redundant: p.Redundant,
+		})
+	}
+	fields := []string{
+		"A",
+		"B",
+		"Km",
+		"Shared",
+		"Overlap",
+		"AHeardByB",
+		"BHeardByA",
+		"Mutual",
+		"Redundant",
+	}
+	return writeReport(cli.Fleet.Overlap.Format, cli.Fleet.Overlap.Output, ts, fields, overlap)
+}
+
+// Necessary for utils.TableStruct magic
+type OverlapReport struct {
+	A         string `header:"Hotspot A"`
+	B         string `header:"Hotspot B"`
+	Km        string `header:"Km"`
+	Shared    int64  `header:"Shared Witnesses"`
+	Overlap   string `header:"Overlap"`
+	AHeardByB int64  `header:"B Heard A"`
+	BHeardByA int64  `header:"A Heard B"`
+	Mutual    bool   `header:"Mutual"`
+	Redundant bool   `header:"Redundant"`
+}
+
+func (or OverlapReport) GetHeader(fieldName string) (string, error) {
+	v := reflect.ValueOf(or)
+	return utils.GetHeaderTag(v, fieldName)
+}
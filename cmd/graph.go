@@ -20,7 +20,6 @@ package main
 
 import (
 	"fmt"
-	"os"
 	"time"
 
 	log "github.com/sirupsen/logrus"
@@ -28,17 +27,22 @@ import (
 )
 
 const (
-	HOTSPOT_REFRESH = 1000 // height delta to do a hotspot cache refresh
+	HOTSPOT_REFRESH = 1000     // height delta to do a hotspot cache refresh
+	GRAPH_PDF_NAME  = "graphs" // all the graphs for a hotspot when --format includes pdf
 )
 
 type GraphCmd struct {
-	Address     string `kong:"arg,required,name='address',help='Hotspot address or name to report on'"`
-	Days        int64  `kong:"name='days',short='d',default=30,help='Previous number of days to report on'"`
-	Last        string `kong:"name='last',short='l',default='1h',help='Age of last challenge before looking for more challenges'"`
-	Minimum     int    `kong:"name='minimum',short='m',default=5,help='Minimum required challenges to generate a graph'"`
-	Json        bool   `kong:"name='json',short='j',default=false,help='Generate per-hotspot JSON files'"`
-	Buffer      int64  `kong:"name='buffer',short='b',default=6,help='Challenge buffer in hours'"`
-	SkipRefresh bool   `kong:"name='skip-refresh',short='s',default=false,help='Skip refresh of challenge and hotspot data'"`
+	Address     string   `kong:"arg,optional,name='address',help='Hotspot address or name to report on'"`
+	Owner       []string `kong:"name='owner',help='Report on all hotspots owned by these wallet(s)'"`
+	Since       string   `kong:"name='since',default='30d',help='Start of the time range: YYYY-MM-DD, RFC3339, age (3d, 12h, 2w) or block height'"`
+	Until       string   `kong:"name='until',default='now',help='End of the time range: now, YYYY-MM-DD, RFC3339, age or block height'"`
+	Days        int64    `kong:"name='days',short='d',hidden,help='Deprecated: use --since'"`
+	Last        string   `kong:"name='last',short='l',hidden,help='Deprecated: use --until'"`
+	Minimum     int      `kong:"name='minimum',short='m',default=5,help='Minimum required challenges to generate a graph'"`
+	Json        bool     `kong:"name='json',short='j',default=false,help='Generate per-hotspot JSON files'"`
+	Buffer      int64    `kong:"name='buffer',short='b',default=6,help='Challenge buffer in hours'"`
+	SkipRefresh bool     `kong:"name='skip-refresh',short='s',default=false,help='Skip refresh of challenge and hotspot data'"`
+	Format      []string `kong:"name='format',short='f',default='png',help='Graph format(s): png, svg and/or pdf'"`
 }
 
 func (cmd *GraphCmd) Run(ctx *RunContext) error {
@@ -49,47 +53,56 @@ func (cmd *GraphCmd) Run(ctx *RunContext) error {
 		return fmt.Errorf("Please specify a --minimum value >= 2")
 	}
 
-	// validate --days and set `firstTime`
-	if cli.Graph.Days < 1 {
-		return fmt.Errorf("Please specify a --days value >= 1")
+	if err := analysis.ValidateGraphFormats(cli.Graph.Format); err != nil {
+		return err
 	}
-	daysOffset := time.Now().UTC().Unix() - (cli.Graph.Days * int64(24*60*60))
-	days := time.Unix(daysOffset, 0).UTC()
-	// go to the beginning of the day UTC
-	startDate := days.Format("2006-01-02")
-	firstTime, _ := time.Parse("2006-01-02", startDate)
 
-	// validate --last and set `lastTime`
-	lastTime, err := parseLastTime(cli.Graph.Last)
-	lastTime = lastTime.UTC()
+	since, until, err := deprecatedRange(cli.Graph.Since, cli.Graph.Until, cli.Graph.Days, cli.Graph.Last)
+	if err != nil {
+		return err
+	}
+	firstTime, lastTime, err := ctx.BoltDB.ParseTimeRange(since, until, time.Now())
 	if err != nil {
 		return err
 	}
 	log.Debugf("Graph range: %s => %s", firstTime.Format(analysis.TIME_FORMAT),
 		lastTime.Format(analysis.TIME_FORMAT))
 
-	// Is this a name or address of a hotspot?  Set `hotspotAddress`
-	hotspotAddress, err := ctx.BoltDB.GetHotspotByUnknown(cli.Graph.Address)
-	if err != nil {
-		return err
+	if !cli.Graph.SkipRefresh {
+		err = ctx.BoltDB.AutoRefreshHotspots(HOTSPOT_REFRESH)
+		if err != nil {
+			log.WithError(err).Warnf("Unable to refresh hotspot data.  Using cache.")
+		}
 	}
 
-	name, err := ctx.BoltDB.GetHotspotName(hotspotAddress)
+	// Set `hotspots` from the name or address of a hotspot or the owner(s)
+	hotspots, err := resolveHotspots(ctx, cli.Graph.Address, cli.Graph.Owner)
 	if err != nil {
 		return err
 	}
 
-	err = makeDirectory(name)
+	for _, hotspotAddress := range hotspots {
+		err = cmd.graphHotspot(ctx, hotspotAddress, firstTime, lastTime)
+		if err != nil {
+			if len(hotspots) == 1 {
+				return err
+			}
+			log.WithError(err).Errorf("Unable to graph %s", hotspotAddress)
+		}
+	}
+	return nil
+}
+
+// Generate all the graphs for a single hotspot
+func (cmd *GraphCmd) graphHotspot(ctx *RunContext, hotspotAddress string, firstTime, lastTime time.Time) error {
+	cli := *ctx.Cli
+
+	name, err := ctx.BoltDB.GetHotspotName(hotspotAddress)
 	if err != nil {
 		return err
 	}
 
 	if !cli.Graph.SkipRefresh {
-		err = ctx.BoltDB.AutoRefreshHotspots(HOTSPOT_REFRESH)
-		if err != nil {
-			log.WithError(err).Warnf("Unable to refresh hotspot data.  Using cache.")
-		}
-
 		duration := time.Duration(time.Hour * time.Duration(cli.Graph.Buffer))
 		err = ctx.BoltDB.LoadChallenges(hotspotAddress, firstTime, lastTime, duration)
 		if err != nil {
@@ -99,72 +112,79 @@ func (cmd *GraphCmd) Run(ctx *RunContext) error {
 
 	challenges, err := ctx.BoltDB.GetChallenges(hotspotAddress, firstTime, lastTime)
 	if err != nil {
-		log.WithError(err).Panic("Unable to load challenges")
+		return fmt.Errorf("Unable to load challenges: %s", err)
 	}
 
 	settings := analysis.GraphSettings{
-		Min:  cli.Graph.Minimum,
-		Zoom: false,
-		Json: cli.Graph.Json,
+		Min:     cli.Graph.Minimum,
+		Zoom:    false,
+		Json:    cli.Graph.Json,
+		Formats: cli.Graph.Format,
+		Output:  ctx.Output,
 	}
+	return generateGraphs(ctx.BoltDB, hotspotAddress, name, challenges, settings)
+}
 
-	err = ctx.BoltDB.GenerateBeaconsGraph(hotspotAddress, challenges, settings)
-	if err != nil {
-		log.WithError(err).Error("Unable to generate beacons graph")
+// Generate the beacons, witnesses & peer graphs for a hotspot
+func generateGraphs(db *analysis.BoltDB, hotspotAddress, name string, challenges []analysis.Challenges, settings analysis.GraphSettings) error {
+	for _, format := range settings.Formats {
+		if format == analysis.FORMAT_PDF {
+			settings.Pdf = analysis.NewPdfDocument(fmt.Sprintf("Helium Analysis: %s", name))
+		}
 	}
 
-	err = ctx.BoltDB.GenerateWitnessesGraph(hotspotAddress, challenges, settings)
+	err := db.GenerateBeaconsGraph(hotspotAddress, challenges, settings)
 	if err != nil {
-		log.WithError(err).Error("Unable to generate witnesses graph")
+		log.WithError(err).WithField("hotspot", name).Error("Unable to generate beacons graph")
 	}
 
-	err = ctx.BoltDB.GeneratePeerGraphs(hotspotAddress, challenges, settings)
+	err = db.GenerateWitnessesGraph(hotspotAddress, challenges, settings)
 	if err != nil {
-		log.WithError(err).Error("Unable to generate peer graph(s)")
+		log.WithError(err).WithField("hotspot", name).Error("Unable to generate witnesses graph")
 	}
-	return nil
-}
 
-// Calc time offset based on refresh string
-func parseLastTime(last string) (time.Time, error) {
-	var x int
-	var t string
-
-	n, err := fmt.Sscanf(last, "%d%s", &x, &t)
+	err = db.GeneratePeerGraphs(hotspotAddress, challenges, settings)
 	if err != nil {
-		return time.Now().UTC(), fmt.Errorf("Unable to parse --last %s: %s", last, err)
-	}
-	if n != 2 {
-		return time.Now().UTC(), fmt.Errorf("Invalid --last %s.  Must be integer followed by: d, h, m", last)
+		log.WithError(err).WithField("hotspot", name).Error("Unable to generate peer graph(s)")
 	}
 
-	if x < 0 {
-		return time.Now().UTC(), fmt.Errorf("Invalid --last %s.  Must be positive integer value", last)
-	}
-
-	// convert into seconds
-	switch t {
-	case "m":
-		x *= 60
-	case "h":
-		x *= 60 * 60
-	case "d":
-		x *= 60 * 60 * 24
+	if settings.Pdf != nil {
+		artifact := analysis.Artifact{
+			Type:    analysis.ARTIFACT_PDF,
+			Format:  analysis.FORMAT_PDF,
+			Hotspot: name,
+			Address: hotspotAddress,
+			Graph:   GRAPH_PDF_NAME,
+		}
+		filename, artifact, err := settings.Output.Prepare(artifact)
+		if err != nil {
+			return err
+		}
+		if err = settings.Pdf.Save(filename); err != nil {
+			return err
+		}
+		settings.Output.Add(artifact)
 	}
-
-	// calc time offset
-	offsetSecs := time.Now().UTC().Unix() - int64(x)
-	return time.Unix(offsetSecs, 0), nil
+	return nil
 }
 
-// Make the directory for the given address
-func makeDirectory(name string) error {
-	var err error = nil
-	stat, err := os.Stat(name)
-	if os.IsNotExist(err) {
-		err = os.Mkdir(name, 0755)
-	} else if !stat.IsDir() {
-		err = fmt.Errorf("%s already exists and is not a directory", name)
+// Maps the deprecated --days & --last flags onto --since & --until.  --days
+// starts at the beginning of the UTC day and --last is an age like 1h.
+func deprecatedRange(since, until string, days int64, last string) (string, string, error) {
+	if days != 0 {
+		if days < 1 {
+			return since, until, fmt.Errorf("Please specify a --days value >= 1")
+		}
+		log.Warnf("--days is deprecated and will be removed.  Use --since instead")
+		start := time.Now().UTC().AddDate(0, 0, -int(days)).Truncate(24 * time.Hour)
+		since = start.Format(time.RFC3339)
+	}
+	if last != "" {
+		if _, err := analysis.ParseAge(last); err != nil {
+			return since, until, fmt.Errorf("Unable to parse --last %s: %s", last, err)
+		}
+		log.Warnf("--last is deprecated and will be removed.  Use --until instead")
+		until = last
 	}
-	return err
+	return since, until, nil
 }
@@ -0,0 +1,144 @@
+package main
+
+/*
+ * Helium Analysis
+ * Copyright (c) 2021-2022 Aaron Turner  <aturner at synfin dot net>
+ *
+ * This program is free software: you can redistribute it
+ * and/or modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or with the authors permission any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+import (
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/synfinatic/onelogin-aws-role/utils"
+)
+
+type HexesCmd struct {
+	Address    string `kong:"arg,required,name='address',help='Hotspot address or name to report on'"`
+	Since      string `kong:"name='since',default='30d',help='Start of the time range: YYYY-MM-DD, RFC3339, age (3d, 12h, 2w) or block height'"`
+	Until      string `kong:"name='until',default='now',help='End of the time range: now, YYYY-MM-DD, RFC3339, age or block height'"`
+	Resolution int    `kong:"name='resolution',short='r',default=8,help='H3 resolution to find peers in the same or adjacent hex'"`
+	Format     string `kong:"name='format',short='f',default='table',enum='table,csv,json',help='Output format [table|csv|json]'"`
+	Output     string `kong:"name='output',short='o',default='stdout',help='Output file for csv/json'"`
+}
+
+func (cmd *HexesCmd) Run(ctx *RunContext) error {
+	cli := *ctx.Cli
+
+	firstTime, lastTime, err := ctx.BoltDB.ParseTimeRange(cli.Hexes.Since, cli.Hexes.Until, time.Now())
+	if err != nil {
+		return err
+	}
+
+	hotspotAddress, err := ctx.BoltDB.GetHotspotByUnknown(cli.Hexes.Address)
+	if err != nil {
+		return err
+	}
+
+	challenges, err := ctx.BoltDB.GetChallenges(hotspotAddress, firstTime, lastTime)
+	if err != nil {
+		return err
+	}
+
+	report, err := ctx.BoltDB.GetHexReport(hotspotAddress, challenges, cli.Hexes.Resolution)
+	if err != nil {
+		return err
+	}
+
+	ts := []utils.TableStruct{}
+	for _, d := range report.Density {
+		ts = append(ts, HexDensityReport{
+			Resolution: int64(d.Resolution),
+			Hex:        d.Hex,
+			Hotspots:   int64(d.Hotspots),
+			Adjacent:   int64(d.Adjacent),
+			Target:     int64(d.Target),
+			Max:        int64(d.Max),
+			Saturation: fmt.Sprintf("%.0f%%", d.Saturation*100.0),
+			Saturated:  d.Saturated,
+		})
+	}
+	fields := []string{
+		"Resolution",
+		"Hex",
+		"Hotspots",
+		"Adjacent",
+		"Target",
+		"Max",
+		"Saturation",
+		"Saturated",
+	}
+
+	if cli.Hexes.Format != "table" {
+		return writeReport(cli.Hexes.Format, cli.Hexes.Output, ts, fields, report)
+	}
+
+	fmt.Printf("Hex density around %s\n", report.Location)
+	if err = writeReport("table", "stdout", ts, fields, report); err != nil {
+		return err
+	}
+	for _, d := range report.Density {
+		if d.Saturated {
+			fmt.Printf("Note: resolution %d hex has %d hotspots which is more than the HIP17 max of %d.  "+
+				"Reward scale of every hotspot in it is reduced.\n", d.Resolution, d.Hotspots, d.Max)
+		}
+	}
+	fmt.Printf("\n")
+
+	fmt.Printf("Peers in the same or adjacent resolution %d hex\n", report.Resolution)
+	peers := []utils.TableStruct{}
+	for _, p := range report.Peers {
+		peers = append(peers, HexPeerReport{
+			Name:     p.Name,
+			Km:       fmt.Sprintf("%.02f", p.Km),
+			Relation: p.Relation,
+			Valid:    int64(p.Valid),
+			Invalid:  int64(p.Invalid),
+		})
+	}
+	return writeReport("table", "stdout", peers, []string{"Name", "Km", "Relation", "Valid", "Invalid"}, report)
+}
+
+// Necessary for utils.TableStruct magic
+type HexDensityReport struct {
+	Resolution int64  `header:"Res"`
+	Hex        string `header:"Hex"`
+	Hotspots   int64  `header:"Hotspots"`
+	Adjacent   int64  `header:"Adjacent"`
+	Target     int64  `header:"Target"`
+	Max        int64  `header:"Max"`
+	Saturation string `header:"Saturation"`
+	Saturated  bool   `header:"Saturated"`
+}
+
+func (hdr HexDensityReport) GetHeader(fieldName string) (string, error) {
+	v := reflect.ValueOf(hdr)
+	return utils.GetHeaderTag(v, fieldName)
+}
+
+// Necessary for utils.TableStruct magic
+type HexPeerReport struct {
+	Name     string `header:"Name"`
+	Km       string `header:"Km"`
+	Relation string `header:"Hex"`
+	Valid    int64  `header:"Valid"`
+	Invalid  int64  `header:"Invalid"`
+}
+
+func (hpr HexPeerReport) GetHeader(fieldName string) (string, error) {
+	v := reflect.ValueOf(hpr)
+	return utils.GetHeaderTag(v, fieldName)
+}
@@ -0,0 +1,125 @@
+package main
+
+/*
+ * Helium Analysis
+ * Copyright (c) 2021-2022 Aaron Turner  <aturner at synfin dot net>
+ *
+ * This program is free software: you can redistribute it
+ * and/or modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or with the authors permission any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+import (
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/synfinatic/helium-analysis/analysis"
+	"github.com/synfinatic/onelogin-aws-role/utils"
+)
+
+type LocationCheckCmd struct {
+	Address   string  `kong:"arg,required,name='address',help='Hotspot address or name to check'"`
+	Since     string  `kong:"name='since',default='30d',help='Start of the time range: YYYY-MM-DD, RFC3339, age (3d, 12h, 2w) or block height'"`
+	Until     string  `kong:"name='until',default='now',help='End of the time range: now, YYYY-MM-DD, RFC3339, age or block height'"`
+	Threshold float64 `kong:"name='threshold',short='t',default=2.0,help='Flag hotspots whose estimated location is more than this many km from the asserted location'"`
+	Format    string  `kong:"name='format',short='f',default='table',enum='table,csv,json',help='Output format [table|csv|json]'"`
+	Output    string  `kong:"name='output',short='o',default='stdout',help='Output file for csv/json'"`
+}
+
+func (cmd *LocationCheckCmd) Run(ctx *RunContext) error {
+	cli := *ctx.Cli
+
+	if cli.LocationCheck.Threshold <= 0.0 {
+		return fmt.Errorf("Please specify a --threshold value > 0")
+	}
+	firstTime, lastTime, err := ctx.BoltDB.ParseTimeRange(cli.LocationCheck.Since, cli.LocationCheck.Until, time.Now())
+	if err != nil {
+		return err
+	}
+
+	hotspotAddress, err := ctx.BoltDB.GetHotspotByUnknown(cli.LocationCheck.Address)
+	if err != nil {
+		return err
+	}
+
+	challenges, err := ctx.BoltDB.GetChallenges(hotspotAddress, firstTime, lastTime)
+	if err != nil {
+		return err
+	}
+
+	checks, err := ctx.BoltDB.CheckLocations(hotspotAddress, challenges, cli.LocationCheck.Threshold)
+	if err != nil {
+		return err
+	}
+
+	ts := []utils.TableStruct{}
+	for _, c := range checks {
+		ts = append(ts, newLocationReport(c))
+	}
+	fields := []string{
+		"Name",
+		"Asserted",
+		"Estimated",
+		"Anchors",
+		"ErrorKm",
+		"RmsKm",
+		"Flagged",
+	}
+	if err = writeReport(cli.LocationCheck.Format, cli.LocationCheck.Output, ts, fields, checks); err != nil {
+		return err
+	}
+
+	if cli.LocationCheck.Format == "table" {
+		flagged := 0
+		for _, c := range checks {
+			if c.Flagged {
+				flagged += 1
+			}
+		}
+		if checks[0].Flagged {
+			fmt.Printf("The asserted location of %s looks wrong: estimated %.5f, %.5f\n",
+				checks[0].Name, checks[0].EstimatedLat, checks[0].EstimatedLng)
+		}
+		fmt.Printf("%d of %d hotspots are more than %.1fkm from their asserted location\n",
+			flagged, len(checks), cli.LocationCheck.Threshold)
+	}
+	return nil
+}
+
+// Necessary for utils.TableStruct magic
+type LocationReport struct {
+	Name      string `header:"Name"`
+	Asserted  string `header:"Asserted Lat, Lng"`
+	Estimated string `header:"Estimated Lat, Lng"`
+	Anchors   int64  `header:"Anchors"`
+	ErrorKm   string `header:"Error Km"`
+	RmsKm     string `header:"RMS Km"`
+	Flagged   bool   `header:"Flagged"`
+}
+
+func newLocationReport(c analysis.LocationCheck) LocationReport {
+	return LocationReport{
+		Name:      c.Name,
+		Asserted:  fmt.Sprintf("%.5f, %.5f", c.AssertedLat, c.AssertedLng),
+		Estimated: fmt.Sprintf("%.5f, %.5f", c.EstimatedLat, c.EstimatedLng),
+		Anchors:   int64(c.Anchors),
+		ErrorKm:   fmt.Sprintf("%.02f", c.ErrorKm),
+		RmsKm:     fmt.Sprintf("%.02f", c.RmsKm),
+		Flagged:   c.Flagged,
+	}
+}
+
+func (lr LocationReport) GetHeader(fieldName string) (string, error) {
+	v := reflect.ValueOf(lr)
+	return utils.GetHeaderTag(v, fieldName)
+}
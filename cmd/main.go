@@ -43,27 +43,57 @@ type RunContext struct {
 	Ctx    *kong.Context
 	Cli    *CLI
 	BoltDB *analysis.BoltDB
+	Output *analysis.Output
 }
 
 type CLI struct {
 	// Common Arguments
-	LogLevel string `kong:"optional,short='L',name='loglevel',default='info',enum='error,warn,info,debug',help='Logging level [error|warn|info|debug]'"`
-	Lines    bool   `kong:"optional,name='lines',default=false,help='Include line numbers in logs'"`
-	Database string `kong:"optional,short='D',name='database',default='helium.db',help='Database file'"`
-	InitDb   bool   `kong:"name='init-db',help='Initialize a new database'"`
+	LogLevel string          `kong:"optional,short='L',name='loglevel',default='info',enum='error,warn,info,debug',help='Logging level [error|warn|info|debug]'"`
+	Lines    bool            `kong:"optional,name='lines',default=false,help='Include line numbers in logs'"`
+	Database string          `kong:"optional,short='D',name='database',default='helium.db',help='Database file'"`
+	InitDb   bool            `kong:"name='init-db',help='Initialize a new database'"`
+	Config   kong.ConfigFlag `kong:"optional,name='config',help='YAML config file (default: ~/.helium-analysis.yaml)'"`
+	Timezone string          `kong:"optional,name='tz',help='Timezone for graphs, reports & tables, eg: America/Los_Angeles or UTC (default: Local, UTC for challenges list)'"`
+	Clock    string          `kong:"optional,name='clock',help='Show times with a 12h or 24h clock [12h|24h] (default: 12h in graphs, 24h in tables)'"`
+
+	// Output files
+	OutputDir    string `kong:"optional,name='output-dir',default='.',help='Directory to write graphs, reports & the index.json manifest into'"`
+	FileTemplate string `kong:"optional,name='file-template',default='{hotspot}/{graph}.{ext}',help='File name template for graphs & reports'"`
+	PeerTemplate string `kong:"optional,name='peer-template',default='{hotspot}/{peer}.{ext}',help='File name template for peer graphs'"`
 
 	// sub commands
-	Graph      GraphCmd      `kong:"cmd,help='Generate graphs for the given hotspot'"`
-	Hotspots   HotspotsCmd   `kong:"cmd,help='Manage hotspots in database'"`
-	Challenges ChallengesCmd `kong:"cmd,help='Manage challenges in database'"`
-	Names      NamesCmd      `kong:"cmd,help='Manage hotspot names in database'"`
-	Version    VersionCmd    `kong:"cmd,help='Print version and exit'"`
+	Anomalies     AnomaliesCmd     `kong:"cmd,help='Detect changes in RSSI/SNR & peers which stopped witnessing the given hotspot'"`
+	Alerts        AlertsCmd        `kong:"cmd,help='Check alert rules for hotspots and notify webhooks'"`
+	Beacons       BeaconsCmd       `kong:"cmd,help='Report how often the given hotspot beacons'"`
+	Graph         GraphCmd         `kong:"cmd,help='Generate graphs for the given hotspot'"`
+	Hexes         HexesCmd         `kong:"cmd,help='Report H3 hex density & saturation around the given hotspot'"`
+	Hotspots      HotspotsCmd      `kong:"cmd,help='Manage hotspots in database'"`
+	Challenges    ChallengesCmd    `kong:"cmd,help='Manage challenges in database'"`
+	Compare       CompareCmd       `kong:"cmd,help='Compare hotspot performance before & after a change'"`
+	Coverage      CoverageCmd      `kong:"cmd,help='Report directional coverage of the given hotspot'"`
+	Explain       ExplainCmd       `kong:"cmd,help='Explain why witnesses of the given hotspot are invalid'"`
+	Exporter      ExporterCmd      `kong:"cmd,help='Export Prometheus metrics for hotspots in the database'"`
+	Fleet         FleetCmd         `kong:"cmd,help='Manage all the hotspots owned by one or more wallets'"`
+	LocationCheck LocationCheckCmd `kong:"cmd,name='location-check',help='Check the asserted location of the given hotspot & its peers'"`
+	Names         NamesCmd         `kong:"cmd,help='Manage hotspot names in database'"`
+	Nearby        NearbyCmd        `kong:"cmd,help='List every hotspot within range of the given hotspot'"`
+	Peers         PeersCmd         `kong:"cmd,help='Report link statistics for every peer of the given hotspot'"`
+	PathLoss      PathLossCmd      `kong:"cmd,name='pathloss',help='Fit the path loss model and score the antenna of the given hotspot'"`
+	Patterns      PatternsCmd      `kong:"cmd,help='Report activity of the given hotspot by hour of day & day of week'"`
+	Reciprocity   ReciprocityCmd   `kong:"cmd,help='Compare how well peers hear the given hotspot vs. it hears them'"`
+	Report        ReportCmd        `kong:"cmd,help='Generate a self-contained HTML report for the given hotspot'"`
+	Serve         ServeCmd         `kong:"cmd,help='Serve a web dashboard & JSON API for all hotspots in the database'"`
+	Simulate      SimulateCmd      `kong:"cmd,help='Predict the links of the given hotspot at a new location or antenna'"`
+	Watch         WatchCmd         `kong:"cmd,help='Periodically refresh & regenerate the graphs and reports of hotspots'"`
+	Version       VersionCmd       `kong:"cmd,help='Print version and exit'"`
 }
 
 func main() {
-	op := kong.Description("Helium Analysis")
 	cli := CLI{}
-	ctx := kong.Parse(&cli, op)
+	ctx := kong.Parse(&cli,
+		kong.Description("Helium Analysis"),
+		kong.Configuration(yamlLoader, CONFIG_FILE),
+	)
 
 	switch cli.LogLevel {
 	case "debug":
@@ -81,18 +111,32 @@ func main() {
 	if cli.Lines {
 		log.SetReportCaller(true)
 	}
+	if err := analysis.SetTimeDisplay(cli.Timezone, cli.Clock); err != nil {
+		log.WithError(err).Fatalf("Invalid --tz or --clock")
+	}
 
 	db, err := analysis.OpenDB(cli.Database, cli.InitDb)
 	if err != nil {
 		log.WithError(err).Fatalf("Error opening database.  Another process has it locked?")
 	}
 	defer db.Close()
+	if err = applyConfig(db); err != nil {
+		log.WithError(err).Fatalf("Invalid config file")
+	}
+	output, err := analysis.NewOutput(cli.OutputDir, cli.FileTemplate, cli.PeerTemplate)
+	if err != nil {
+		log.WithError(err).Fatalf("Invalid output file template")
+	}
 	run_ctx := RunContext{
 		Ctx:    ctx,
 		Cli:    &cli,
 		BoltDB: db,
+		Output: output,
 	}
 	err = ctx.Run(&run_ctx)
+	if mErr := output.WriteManifest(); mErr != nil {
+		log.WithError(mErr).Errorf("Unable to write manifest")
+	}
 	if err != nil {
 		log.Panicf("Error running command: %s", err.Error())
 	}
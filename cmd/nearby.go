@@ -0,0 +1,126 @@
+package main
+
+/*
+ * Helium Analysis
+ * Copyright (c) 2021-2022 Aaron Turner  <aturner at synfin dot net>
+ *
+ * This program is free software: you can redistribute it
+ * and/or modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or with the authors permission any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+import (
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/synfinatic/helium-analysis/analysis"
+	"github.com/synfinatic/onelogin-aws-role/utils"
+)
+
+type NearbyCmd struct {
+	Address string `kong:"arg,required,name='address',help='Hotspot address or name to report on'"`
+	Radius  string `kong:"name='radius',short='r',default='10km',help='Search radius in km or mi (10km, 6mi)'"`
+	Since   string `kong:"name='since',default='30d',help='Start of the time range: YYYY-MM-DD, RFC3339, age (3d, 12h, 2w) or block height'"`
+	Until   string `kong:"name='until',default='now',help='End of the time range: now, YYYY-MM-DD, RFC3339, age or block height'"`
+	Status  string `kong:"name='status',short='s',default='all',enum='all,peer,silent,offline',help='Only report hotspots with this status [all|peer|silent|offline]'"`
+	Format  string `kong:"name='format',short='f',default='table',enum='table,csv,json',help='Output format [table|csv|json]'"`
+	Output  string `kong:"name='output',short='o',default='stdout',help='Output file for csv/json'"`
+}
+
+func (cmd *NearbyCmd) Run(ctx *RunContext) error {
+	cli := *ctx.Cli
+
+	radius, err := analysis.ParseDistance(cli.Nearby.Radius)
+	if err != nil {
+		return err
+	}
+	firstTime, lastTime, err := ctx.BoltDB.ParseTimeRange(cli.Nearby.Since, cli.Nearby.Until, time.Now())
+	if err != nil {
+		return err
+	}
+
+	hotspotAddress, err := ctx.BoltDB.GetHotspotByUnknown(cli.Nearby.Address)
+	if err != nil {
+		return err
+	}
+
+	challenges, err := ctx.BoltDB.GetChallenges(hotspotAddress, firstTime, lastTime)
+	if err != nil {
+		return err
+	}
+
+	nearby, err := ctx.BoltDB.GetNearby(hotspotAddress, challenges, radius)
+	if err != nil {
+		return err
+	}
+
+	counts := map[string]int{}
+	selected := []analysis.NearbyHotspot{}
+	for _, n := range nearby {
+		counts[n.Status] += 1
+		if cli.Nearby.Status == "all" || cli.Nearby.Status == n.Status {
+			selected = append(selected, n)
+		}
+	}
+
+	ts := []utils.TableStruct{}
+	for _, n := range selected {
+		ts = append(ts, NearbyReport{
+			Name:    n.Name,
+			Km:      fmt.Sprintf("%.02f", n.Km),
+			Mi:      fmt.Sprintf("%.02f", n.Mi),
+			Bearing: fmt.Sprintf("%.0f", n.Bearing),
+			Status:  n.Status,
+			Tx:      int64(n.TxCount),
+			Rx:      int64(n.RxCount),
+			Owner:   n.Owner,
+		})
+	}
+	fields := []string{
+		"Name",
+		"Km",
+		"Mi",
+		"Bearing",
+		"Status",
+		"Tx",
+		"Rx",
+		"Owner",
+	}
+	if err = writeReport(cli.Nearby.Format, cli.Nearby.Output, ts, fields, selected); err != nil {
+		return err
+	}
+
+	if cli.Nearby.Format == "table" {
+		fmt.Printf("%d hotspots within %.1fkm: %d peers, %d silent neighbors, %d offline\n",
+			len(nearby), radius, counts[analysis.NEARBY_PEER], counts[analysis.NEARBY_SILENT],
+			counts[analysis.NEARBY_OFFLINE])
+	}
+	return nil
+}
+
+// Necessary for utils.TableStruct magic
+type NearbyReport struct {
+	Name    string `header:"Name"`
+	Km      string `header:"Km"`
+	Mi      string `header:"Mi"`
+	Bearing string `header:"Bearing"`
+	Status  string `header:"Status"`
+	Tx      int64  `header:"TX"`
+	Rx      int64  `header:"RX"`
+	Owner   string `header:"Owner"`
+}
+
+func (nr NearbyReport) GetHeader(fieldName string) (string, error) {
+	v := reflect.ValueOf(nr)
+	return utils.GetHeaderTag(v, fieldName)
+}
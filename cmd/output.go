@@ -0,0 +1,95 @@
+package main
+
+/*
+ * Helium Analysis
+ * Copyright (c) 2021-2022 Aaron Turner  <aturner at synfin dot net>
+ *
+ * This program is free software: you can redistribute it
+ * and/or modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or with the authors permission any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/synfinatic/onelogin-aws-role/utils"
+)
+
+// Writes a report as a pretty table, CSV or JSON.  Tables always go to stdout,
+// CSV & JSON go to stdout or the given file.  `jdata` is what gets marshalled
+// for JSON output so callers can provide the raw (unformatted) values.
+func writeReport(format, file string, rows []utils.TableStruct, fields []string, jdata interface{}) error {
+	var data []byte
+	var err error
+
+	switch format {
+	case "table":
+		if len(rows) == 0 {
+			fmt.Printf("No results\n\n")
+			return nil
+		}
+		utils.GenerateTable(rows, fields)
+		fmt.Printf("\n")
+		return nil
+
+	case "csv":
+		buf := bytes.Buffer{}
+		w := csv.NewWriter(&buf)
+		// always write the header so empty results are still valid CSV.  Use
+		// the column names of the table if we have a row to get them from.
+		header := append([]string{}, fields...)
+		if len(rows) > 0 {
+			_, headers := utils.TableRow(rows[0])
+			for i, field := range fields {
+				header[i] = headers[field]
+			}
+		}
+		if err = w.Write(header); err != nil {
+			return err
+		}
+		for _, r := range rows {
+			row, _ := utils.TableRow(r)
+			line := []string{}
+			for _, field := range fields {
+				line = append(line, row[field])
+			}
+			if err = w.Write(line); err != nil {
+				return err
+			}
+		}
+		w.Flush()
+		if err = w.Error(); err != nil {
+			return err
+		}
+		data = buf.Bytes()
+
+	case "json":
+		data, err = json.MarshalIndent(jdata, "", "  ")
+		if err != nil {
+			return err
+		}
+		data = append(data, '\n')
+
+	default:
+		return fmt.Errorf("Invalid output format: %s", format)
+	}
+
+	if file != "stdout" {
+		return ioutil.WriteFile(file, data, 0644)
+	}
+	fmt.Printf("%s", string(data))
+	return nil
+}
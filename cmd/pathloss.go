@@ -0,0 +1,117 @@
+package main
+
+/*
+ * Helium Analysis
+ * Copyright (c) 2021-2022 Aaron Turner  <aturner at synfin dot net>
+ *
+ * This program is free software: you can redistribute it
+ * and/or modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or with the authors permission any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+import (
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/synfinatic/helium-analysis/analysis"
+	"github.com/synfinatic/onelogin-aws-role/utils"
+)
+
+type PathLossCmd struct {
+	Address string `kong:"arg,required,name='address',help='Hotspot address or name to report on'"`
+	Since   string `kong:"name='since',default='30d',help='Start of the time range: YYYY-MM-DD, RFC3339, age (3d, 12h, 2w) or block height'"`
+	Until   string `kong:"name='until',default='now',help='End of the time range: now, YYYY-MM-DD, RFC3339, age or block height'"`
+	Format  string `kong:"name='format',short='f',default='table',enum='table,csv,json',help='Output format [table|csv|json]'"`
+	Output  string `kong:"name='output',short='o',default='stdout',help='Output file for csv/json'"`
+}
+
+func (cmd *PathLossCmd) Run(ctx *RunContext) error {
+	cli := *ctx.Cli
+
+	firstTime, lastTime, err := ctx.BoltDB.ParseTimeRange(cli.PathLoss.Since, cli.PathLoss.Until, time.Now())
+	if err != nil {
+		return err
+	}
+
+	hotspotAddress, err := ctx.BoltDB.GetHotspotByUnknown(cli.PathLoss.Address)
+	if err != nil {
+		return err
+	}
+
+	challenges, err := ctx.BoltDB.GetChallenges(hotspotAddress, firstTime, lastTime)
+	if err != nil {
+		return err
+	}
+
+	models, err := ctx.BoltDB.GetPathLoss(hotspotAddress, challenges)
+	if err != nil {
+		return err
+	}
+
+	ts := []utils.TableStruct{}
+	for _, m := range models {
+		ts = append(ts, PathLossReport{
+			Direction:     m.Direction,
+			Samples:       int64(m.Samples),
+			Peers:         int64(m.Peers),
+			Exponent:      fmt.Sprintf("%.02f", m.Exponent),
+			RssiAt1Km:     fmt.Sprintf("%.01f", m.RssiAt1Km),
+			EffectiveGain: fmt.Sprintf("%.01f", m.EffectiveGain),
+			FsplGain:      fmt.Sprintf("%.01f", m.FsplGain),
+			Score:         fmt.Sprintf("%+.01f", m.Score),
+			RSquared:      fmt.Sprintf("%.02f", m.RSquared),
+			StdDev:        fmt.Sprintf("%.01f", m.StdDev),
+		})
+	}
+	fields := []string{
+		"Direction",
+		"Samples",
+		"Peers",
+		"Exponent",
+		"RssiAt1Km",
+		"EffectiveGain",
+		"FsplGain",
+		"Score",
+		"RSquared",
+		"StdDev",
+	}
+	err = writeReport(cli.PathLoss.Format, cli.PathLoss.Output, ts, fields, models)
+	if err != nil || cli.PathLoss.Format != "table" {
+		return err
+	}
+
+	m := models[0]
+	fmt.Printf("Antenna score: %+.01fdB vs. Helium model (%.01fdBi TX+RX)\n",
+		m.Score, analysis.ANTENNA_GAIN*2)
+	fmt.Printf("Path loss exponent: %.02f (free space = 2.00)\n", m.Exponent)
+	return nil
+}
+
+// Necessary for utils.TableStruct magic
+type PathLossReport struct {
+	Direction     string `header:"Direction"`
+	Samples       int64  `header:"Samples"`
+	Peers         int64  `header:"Peers"`
+	Exponent      string `header:"Exponent"`
+	RssiAt1Km     string `header:"RSSI@1km"`
+	EffectiveGain string `header:"Eff. Gain dBi"`
+	FsplGain      string `header:"FSPL Gain dBi"`
+	Score         string `header:"Score dB"`
+	RSquared      string `header:"R^2"`
+	StdDev        string `header:"StdDev dB"`
+}
+
+func (plr PathLossReport) GetHeader(fieldName string) (string, error) {
+	v := reflect.ValueOf(plr)
+	return utils.GetHeaderTag(v, fieldName)
+}
@@ -0,0 +1,109 @@
+package main
+
+/*
+ * Helium Analysis
+ * Copyright (c) 2021-2022 Aaron Turner  <aturner at synfin dot net>
+ *
+ * This program is free software: you can redistribute it
+ * and/or modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or with the authors permission any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+import (
+	"fmt"
+	"reflect"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/synfinatic/helium-analysis/analysis"
+	"github.com/synfinatic/onelogin-aws-role/utils"
+)
+
+type PatternsCmd struct {
+	Address   string `kong:"arg,required,name='address',help='Hotspot address or name to report on'"`
+	Since     string `kong:"name='since',default='30d',help='Start of the time range: YYYY-MM-DD, RFC3339, age (3d, 12h, 2w) or block height'"`
+	Until     string `kong:"name='until',default='now',help='End of the time range: now, YYYY-MM-DD, RFC3339, age or block height'"`
+	SkipGraph bool   `kong:"name='skip-graph',default=false,help='Do not generate the heatmaps & RSSI graph'"`
+	Format    string `kong:"name='format',short='f',default='table',enum='table,csv,json',help='Output format [table|csv|json]'"`
+	Output    string `kong:"name='output',short='o',default='stdout',help='Output file for csv/json'"`
+}
+
+func (cmd *PatternsCmd) Run(ctx *RunContext) error {
+	cli := *ctx.Cli
+
+	firstTime, lastTime, err := ctx.BoltDB.ParseTimeRange(cli.Patterns.Since, cli.Patterns.Until, time.Now())
+	if err != nil {
+		return err
+	}
+
+	hotspotAddress, err := ctx.BoltDB.GetHotspotByUnknown(cli.Patterns.Address)
+	if err != nil {
+		return err
+	}
+
+	challenges, err := ctx.BoltDB.GetChallenges(hotspotAddress, firstTime, lastTime)
+	if err != nil {
+		return err
+	}
+
+	patterns, err := ctx.BoltDB.GetActivityPatterns(hotspotAddress, challenges, analysis.GetLocation())
+	if err != nil {
+		return err
+	}
+
+	if !cli.Patterns.SkipGraph {
+		err = ctx.BoltDB.GeneratePatternsGraphs(hotspotAddress, patterns, analysis.GraphSettings{Output: ctx.Output})
+		if err != nil {
+			log.WithError(err).Error("Unable to generate activity pattern graphs")
+		}
+	}
+
+	ts := []utils.TableStruct{}
+	for hour, rssi := range patterns.Rssi {
+		beacons := 0
+		witnesses := 0
+		for day := 0; day < 7; day++ {
+			beacons += patterns.Beacons[day][hour]
+			witnesses += patterns.Witnesses[day][hour]
+		}
+		pr := PatternReport{
+			Hour:      fmt.Sprintf("%02d", hour),
+			Beacons:   int64(beacons),
+			Witnesses: int64(witnesses),
+			Samples:   int64(rssi.Samples),
+		}
+		if rssi.Samples > 0 {
+			pr.RssiMean = fmt.Sprintf("%.1f", rssi.RssiMean)
+			pr.RssiMedian = fmt.Sprintf("%.1f", rssi.RssiMedian)
+			pr.SnrMean = fmt.Sprintf("%.1f", rssi.SnrMean)
+		}
+		ts = append(ts, pr)
+	}
+	fields := []string{"Hour", "Beacons", "Witnesses", "Samples", "RssiMean", "RssiMedian", "SnrMean"}
+	return writeReport(cli.Patterns.Format, cli.Patterns.Output, ts, fields, patterns)
+}
+
+// Necessary for utils.TableStruct magic
+type PatternReport struct {
+	Hour       string `header:"Hour"`
+	Beacons    int64  `header:"Beacons"`
+	Witnesses  int64  `header:"Witnesses"`
+	Samples    int64  `header:"RSSI Samples"`
+	RssiMean   string `header:"RSSI Mean"`
+	RssiMedian string `header:"RSSI Median"`
+	SnrMean    string `header:"SNR Mean"`
+}
+
+func (pr PatternReport) GetHeader(fieldName string) (string, error) {
+	v := reflect.ValueOf(pr)
+	return utils.GetHeaderTag(v, fieldName)
+}
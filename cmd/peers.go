@@ -0,0 +1,165 @@
+package main
+
+/*
+ * Helium Analysis
+ * Copyright (c) 2021-2022 Aaron Turner  <aturner at synfin dot net>
+ *
+ * This program is free software: you can redistribute it
+ * and/or modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or with the authors permission any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+import (
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/synfinatic/helium-analysis/analysis"
+	"github.com/synfinatic/onelogin-aws-role/utils"
+)
+
+type PeersCmd struct {
+	Address string   `kong:"arg,optional,name='address',help='Hotspot address or name to report on'"`
+	Owner   []string `kong:"name='owner',help='Report on all hotspots owned by these wallet(s)'"`
+	Since   string   `kong:"name='since',default='30d',help='Start of the time range: YYYY-MM-DD, RFC3339, age (3d, 12h, 2w) or block height'"`
+	Until   string   `kong:"name='until',default='now',help='End of the time range: now, YYYY-MM-DD, RFC3339, age or block height'"`
+	Sort    string   `kong:"name='sort',short='s',default='distance',help='Column to sort by: name, distance, bearing, tx, rx, valid, invalid, rssi-mean, rssi-median, rssi-p90, snr-mean, snr-median, snr-p90, last-seen, reward-scale, online'"`
+	Reverse bool     `kong:"name='reverse',short='r',default=false,help='Reverse the sort order'"`
+	Format  string   `kong:"name='format',short='f',default='table',enum='table,csv,json',help='Output format [table|csv|json]'"`
+	Output  string   `kong:"name='output',short='o',default='stdout',help='Output file for csv/json'"`
+}
+
+func (cmd *PeersCmd) Run(ctx *RunContext) error {
+	cli := *ctx.Cli
+
+	firstTime, lastTime, err := ctx.BoltDB.ParseTimeRange(cli.Peers.Since, cli.Peers.Until, time.Now())
+	if err != nil {
+		return err
+	}
+
+	// Set `hotspots` from the name or address of a hotspot or the owner(s)
+	hotspots, err := resolveHotspots(ctx, cli.Peers.Address, cli.Peers.Owner)
+	if err != nil {
+		return err
+	}
+
+	ts := []utils.TableStruct{}
+	allStats := map[string][]analysis.PeerStats{} // keyed by hotspot address
+	for _, hotspotAddress := range hotspots {
+		challenges, err := ctx.BoltDB.GetChallenges(hotspotAddress, firstTime, lastTime)
+		if err != nil {
+			return err
+		}
+
+		stats, err := ctx.BoltDB.GetPeerStats(hotspotAddress, challenges)
+		if err != nil {
+			return err
+		}
+
+		err = analysis.SortPeerStats(stats, cli.Peers.Sort, cli.Peers.Reverse)
+		if err != nil {
+			return err
+		}
+
+		name, err := ctx.BoltDB.GetHotspotName(hotspotAddress)
+		if err != nil {
+			return err
+		}
+		allStats[hotspotAddress] = stats
+		for _, s := range stats {
+			pr := newPeerReport(s)
+			pr.Hotspot = name
+			ts = append(ts, pr)
+		}
+	}
+
+	fields := []string{
+		"Name",
+		"Km",
+		"Mi",
+		"Bearing",
+		"Tx",
+		"Rx",
+		"Valid",
+		"Invalid",
+		"RssiMean",
+		"RssiMedian",
+		"RssiP90",
+		"SnrMean",
+		"SnrMedian",
+		"SnrP90",
+		"LastSeen",
+		"RewardScale",
+		"Online",
+	}
+	if len(hotspots) == 1 {
+		for _, stats := range allStats {
+			return writeReport(cli.Peers.Format, cli.Peers.Output, ts, fields, stats)
+		}
+	}
+	// include which of our hotspots each peer belongs to
+	fields = append([]string{"Hotspot"}, fields...)
+	return writeReport(cli.Peers.Format, cli.Peers.Output, ts, fields, allStats)
+}
+
+// Necessary for utils.TableStruct magic
+type PeerReport struct {
+	Hotspot     string `header:"Hotspot"`
+	Name        string `header:"Name"`
+	Km          string `header:"Km"`
+	Mi          string `header:"Mi"`
+	Bearing     string `header:"Bearing"`
+	Tx          int64  `header:"TX"`
+	Rx          int64  `header:"RX"`
+	Valid       int64  `header:"Valid"`
+	Invalid     int64  `header:"Invalid"`
+	RssiMean    string `header:"RSSI Mean"`
+	RssiMedian  string `header:"RSSI Median"`
+	RssiP90     string `header:"RSSI p90"`
+	SnrMean     string `header:"SNR Mean"`
+	SnrMedian   string `header:"SNR Median"`
+	SnrP90      string `header:"SNR p90"`
+	LastSeen    string `header:"Last Seen"`
+	RewardScale string `header:"Scale"`
+	Online      string `header:"Status"`
+}
+
+func newPeerReport(s analysis.PeerStats) PeerReport {
+	pr := PeerReport{
+		Name:        s.Name,
+		Km:          fmt.Sprintf("%.02f", s.Km),
+		Mi:          fmt.Sprintf("%.02f", s.Mi),
+		Bearing:     "n/a",
+		Tx:          int64(s.TxCount),
+		Rx:          int64(s.RxCount),
+		Valid:       int64(s.ValidCount),
+		Invalid:     int64(s.Invalid),
+		RssiMean:    fmt.Sprintf("%.01f", s.RssiMean),
+		RssiMedian:  fmt.Sprintf("%.01f", s.RssiMedian),
+		RssiP90:     fmt.Sprintf("%.01f", s.RssiP90),
+		SnrMean:     fmt.Sprintf("%.01f", s.SnrMean),
+		SnrMedian:   fmt.Sprintf("%.01f", s.SnrMedian),
+		SnrP90:      fmt.Sprintf("%.01f", s.SnrP90),
+		LastSeen:    analysis.FormatTime(time.Unix(0, s.LastSeen)),
+		RewardScale: fmt.Sprintf("%.02f", s.RewardScale),
+		Online:      s.Online,
+	}
+	if s.HasBearing {
+		pr.Bearing = fmt.Sprintf("%.0f", s.Bearing)
+	}
+	return pr
+}
+
+func (pr PeerReport) GetHeader(fieldName string) (string, error) {
+	v := reflect.ValueOf(pr)
+	return utils.GetHeaderTag(v, fieldName)
+}
@@ -0,0 +1,157 @@
+package main
+
+/*
+ * Helium Analysis
+ * Copyright (c) 2021-2022 Aaron Turner  <aturner at synfin dot net>
+ *
+ * This program is free software: you can redistribute it
+ * and/or modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or with the authors permission any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/synfinatic/helium-analysis/analysis"
+	"github.com/synfinatic/onelogin-aws-role/utils"
+)
+
+type ReciprocityCmd struct {
+	Address     string  `kong:"arg,required,name='address',help='Hotspot address or name to report on'"`
+	Since       string  `kong:"name='since',default='30d',help='Start of the time range: YYYY-MM-DD, RFC3339, age (3d, 12h, 2w) or block height'"`
+	Until       string  `kong:"name='until',default='now',help='End of the time range: now, YYYY-MM-DD, RFC3339, age or block height'"`
+	Minimum     int     `kong:"name='minimum',short='m',default=3,help='Minimum witnesses in each direction to compare RSSI'"`
+	Threshold   float64 `kong:"name='threshold',short='t',default=6.0,help='Flag links whose RSSI delta differs by more than this many dB'"`
+	Ratio       float64 `kong:"name='ratio',short='r',default=4.0,help='Flag links where one direction has more than this many times the witnesses of the other'"`
+	FlaggedOnly bool    `kong:"name='flagged',default=false,help='Only report flagged links'"`
+	Format      string  `kong:"name='format',short='f',default='table',enum='table,csv,json',help='Output format [table|csv|json]'"`
+	Output      string  `kong:"name='output',short='o',default='stdout',help='Output file for csv/json'"`
+}
+
+func (cmd *ReciprocityCmd) Run(ctx *RunContext) error {
+	cli := *ctx.Cli
+
+	if cli.Reciprocity.Minimum < 1 {
+		return fmt.Errorf("Please specify a --minimum value >= 1")
+	}
+	if cli.Reciprocity.Ratio < 1.0 {
+		return fmt.Errorf("Please specify a --ratio value >= 1.0")
+	}
+	firstTime, lastTime, err := ctx.BoltDB.ParseTimeRange(cli.Reciprocity.Since, cli.Reciprocity.Until, time.Now())
+	if err != nil {
+		return err
+	}
+
+	hotspotAddress, err := ctx.BoltDB.GetHotspotByUnknown(cli.Reciprocity.Address)
+	if err != nil {
+		return err
+	}
+
+	challenges, err := ctx.BoltDB.GetChallenges(hotspotAddress, firstTime, lastTime)
+	if err != nil {
+		return err
+	}
+
+	report, err := ctx.BoltDB.GetReciprocity(hotspotAddress, challenges,
+		cli.Reciprocity.Minimum, cli.Reciprocity.Threshold, cli.Reciprocity.Ratio)
+	if err != nil {
+		return err
+	}
+
+	if cli.Reciprocity.FlaggedOnly {
+		peers := []analysis.ReciprocityStats{}
+		for _, p := range report.Peers {
+			if len(p.Flags) > 0 {
+				peers = append(peers, p)
+			}
+		}
+		report.Peers = peers
+	}
+
+	ts := []utils.TableStruct{}
+	for _, p := range report.Peers {
+		ts = append(ts, newReciprocityReport(p, cli.Reciprocity.Minimum))
+	}
+	fields := []string{
+		"Name",
+		"Km",
+		"Tx",
+		"Rx",
+		"TxRate",
+		"TxRssi",
+		"RxRssi",
+		"RssiDelta",
+		"RelativeDelta",
+		"Flags",
+	}
+	if err = writeReport(cli.Reciprocity.Format, cli.Reciprocity.Output, ts, fields, report); err != nil {
+		return err
+	}
+
+	if cli.Reciprocity.Format == "table" {
+		fmt.Printf("Median TX - RX RSSI delta over %d two-way peers: %+.01fdB\n",
+			report.TwoWayPeers, report.MedianDelta)
+		if report.Flagged {
+			fmt.Printf("Peers consistently hear us %s than we hear them.  Check our TX power, "+
+				"cable loss and antenna gain.\n", map[bool]string{true: "louder", false: "quieter"}[report.MedianDelta > 0])
+		}
+	}
+	return nil
+}
+
+// Necessary for utils.TableStruct magic
+type ReciprocityReport struct {
+	Name          string `header:"Name"`
+	Km            string `header:"Km"`
+	Tx            int64  `header:"TX"`
+	Rx            int64  `header:"RX"`
+	TxRate        string `header:"TX Rate"`
+	TxRssi        string `header:"TX RSSI"`
+	RxRssi        string `header:"RX RSSI"`
+	RssiDelta     string `header:"Delta"`
+	RelativeDelta string `header:"Relative Delta"`
+	Flags         string `header:"Flags"`
+}
+
+func newReciprocityReport(r analysis.ReciprocityStats, minimum int) ReciprocityReport {
+	rr := ReciprocityReport{
+		Name:          r.Name,
+		Km:            fmt.Sprintf("%.02f", r.Km),
+		Tx:            int64(r.TxCount),
+		Rx:            int64(r.RxCount),
+		TxRate:        fmt.Sprintf("%.0f%%", r.TxRate*100.0),
+		TxRssi:        "n/a",
+		RxRssi:        "n/a",
+		RssiDelta:     "n/a",
+		RelativeDelta: "n/a",
+		Flags:         strings.Join(r.Flags, ", "),
+	}
+	if r.TxCount > 0 {
+		rr.TxRssi = fmt.Sprintf("%.01f", r.TxRssi)
+	}
+	if r.RxCount > 0 {
+		rr.RxRssi = fmt.Sprintf("%.01f", r.RxRssi)
+	}
+	if r.TxCount >= minimum && r.RxCount >= minimum {
+		rr.RssiDelta = fmt.Sprintf("%+.01f", r.RssiDelta)
+		rr.RelativeDelta = fmt.Sprintf("%+.01f", r.RelativeDelta)
+	}
+	return rr
+}
+
+func (rr ReciprocityReport) GetHeader(fieldName string) (string, error) {
+	v := reflect.ValueOf(rr)
+	return utils.GetHeaderTag(v, fieldName)
+}
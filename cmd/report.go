@@ -0,0 +1,101 @@
+package main
+
+/*
+ * Helium Analysis
+ * Copyright (c) 2021-2022 Aaron Turner  <aturner at synfin dot net>
+ *
+ * This program is free software: you can redistribute it
+ * and/or modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or with the authors permission any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/synfinatic/helium-analysis/analysis"
+)
+
+const REPORT_NAME = "report" // {graph} of the per-hotspot HTML report
+
+type ReportCmd struct {
+	Address string   `kong:"arg,optional,name='address',help='Hotspot address or name to report on'"`
+	Owner   []string `kong:"name='owner',help='Report on all hotspots owned by these wallet(s)'"`
+	Since   string   `kong:"name='since',default='30d',help='Start of the time range: YYYY-MM-DD, RFC3339, age (3d, 12h, 2w) or block height'"`
+	Until   string   `kong:"name='until',default='now',help='End of the time range: now, YYYY-MM-DD, RFC3339, age or block height'"`
+	Minimum int      `kong:"name='minimum',short='m',default=5,help='Minimum required challenges to generate a graph'"`
+	Output  string   `kong:"name='output',short='o',help='Output file (default: --file-template with {graph} = report)'"`
+}
+
+func (cmd *ReportCmd) Run(ctx *RunContext) error {
+	cli := *ctx.Cli
+
+	if cli.Report.Minimum < 2 {
+		return fmt.Errorf("Please specify a --minimum value >= 2")
+	}
+	firstTime, lastTime, err := ctx.BoltDB.ParseTimeRange(cli.Report.Since, cli.Report.Until, time.Now())
+	if err != nil {
+		return err
+	}
+
+	hotspots, err := resolveHotspots(ctx, cli.Report.Address, cli.Report.Owner)
+	if err != nil {
+		return err
+	}
+	if len(hotspots) > 1 && cli.Report.Output != "" {
+		return fmt.Errorf("--output can only be used with a single hotspot")
+	}
+
+	settings := analysis.GraphSettings{
+		Min: cli.Report.Minimum,
+	}
+
+	for _, hotspotAddress := range hotspots {
+		name, err := ctx.BoltDB.GetHotspotName(hotspotAddress)
+		if err != nil {
+			return err
+		}
+		challenges, err := ctx.BoltDB.GetChallenges(hotspotAddress, firstTime, lastTime)
+		if err != nil {
+			return err
+		}
+
+		var f *os.File
+		if cli.Report.Output != "" {
+			f, err = os.Create(cli.Report.Output)
+		} else {
+			f, err = ctx.Output.Create(analysis.Artifact{
+				Type:    analysis.ARTIFACT_REPORT,
+				Format:  "html",
+				Hotspot: name,
+				Address: hotspotAddress,
+				Graph:   REPORT_NAME,
+			})
+		}
+		if err != nil {
+			return err
+		}
+		err = ctx.BoltDB.GenerateReport(hotspotAddress, challenges, firstTime, lastTime, settings, f)
+		f.Close()
+		if err != nil {
+			if len(hotspots) == 1 {
+				return err
+			}
+			log.WithError(err).Errorf("Unable to generate report for %s", name)
+			continue
+		}
+		log.Infof("Created %s", f.Name())
+	}
+	return nil
+}
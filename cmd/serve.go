@@ -0,0 +1,490 @@
+package main
+
+/*
+ * Helium Analysis
+ * Copyright (c) 2021-2022 Aaron Turner  <aturner at synfin dot net>
+ *
+ * This program is free software: you can redistribute it
+ * and/or modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or with the authors permission any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/synfinatic/helium-analysis/analysis"
+)
+
+const (
+	SERVE_MAX_HOTSPOTS = 50 // max search results
+
+	// slow clients must not hold the DB lock forever
+	SERVE_READ_HEADER_TIMEOUT = 10 * time.Second
+	SERVE_READ_TIMEOUT        = 30 * time.Second
+	SERVE_WRITE_TIMEOUT       = 2 * time.Minute // generating graphs can be slow
+)
+
+type ServeCmd struct {
+	Listen  string        `kong:"name='listen',short='l',default=':8080',help='Address & port to listen on'"`
+	Since   string        `kong:"name='since',default='30d',help='Default start of the time range to show: YYYY-MM-DD, RFC3339, age (3d, 12h, 2w) or block height'"`
+	Minimum int           `kong:"name='minimum',short='m',default=5,help='Minimum required challenges to generate a graph'"`
+	Window  time.Duration `kong:"name='metrics-window',short='w',default='24h',help='Sliding window for the /metrics beacon, witness & peer metrics'"`
+}
+
+func (cmd *ServeCmd) Run(ctx *RunContext) error {
+	cli := *ctx.Cli
+
+	if cli.Serve.Minimum < 2 {
+		return fmt.Errorf("Please specify a --minimum value >= 2")
+	}
+	if _, _, err := ctx.BoltDB.ParseTimeRange(cli.Serve.Since, "now", time.Now()); err != nil {
+		return err
+	}
+	if cli.Serve.Window < time.Hour {
+		return fmt.Errorf("Please specify a --metrics-window >= 1h")
+	}
+
+	s := &server{
+		db:      ctx.BoltDB,
+		since:   cli.Serve.Since,
+		minimum: cli.Serve.Minimum,
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", s.dashboard)
+	mux.HandleFunc("/api/hotspots", s.apiHotspots)
+	mux.HandleFunc("/api/challenges/", s.apiChallenges)
+	mux.HandleFunc("/api/witnesses/", s.apiWitnesses)
+	mux.HandleFunc("/api/peers/", s.apiPeers)
+	mux.HandleFunc("/graph/", s.graph)
+	mux.Handle("/metrics", &metricsHandler{
+		ctx:    ctx,
+		lock:   &s.lock,
+		window: cli.Serve.Window,
+	})
+
+	log.Infof("Listening on %s", cli.Serve.Listen)
+	return listenAndServe(cli.Serve.Listen, logRequests(mux))
+}
+
+// like http.ListenAndServe but with timeouts
+func listenAndServe(listen string, handler http.Handler) error {
+	server := &http.Server{
+		Addr:              listen,
+		Handler:           handler,
+		ReadHeaderTimeout: SERVE_READ_HEADER_TIMEOUT,
+		ReadTimeout:       SERVE_READ_TIMEOUT,
+		WriteTimeout:      SERVE_WRITE_TIMEOUT,
+	}
+	return server.ListenAndServe()
+}
+
+type server struct {
+	db      *analysis.BoltDB
+	lock    sync.Mutex // the BoltDB caches are not safe for concurrent use
+	since   string
+	minimum int
+}
+
+type serveHotspot struct {
+	Address string `json:"address"`
+	Name    string `json:"name"`
+	Owner   string `json:"owner"`
+}
+
+type serveError struct {
+	Error string `json:"error"`
+}
+
+func logRequests(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		h.ServeHTTP(w, r)
+		log.Debugf("%s %s %s", r.Method, r.URL.String(), time.Since(start))
+	})
+}
+
+func writeJson(w http.ResponseWriter, status int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(data); err != nil {
+		log.WithError(err).Errorf("Unable to encode JSON")
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJson(w, status, serveError{Error: err.Error()})
+}
+
+// splits the URL path after the prefix into the expected number of parts
+func pathArgs(r *http.Request, prefix string, count int) ([]string, error) {
+	args := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, prefix), "/"), "/")
+	if len(args) != count || args[0] == "" {
+		return []string{}, fmt.Errorf("Invalid path: %s", r.URL.Path)
+	}
+	return args, nil
+}
+
+// returns the from & to times from the query string which accept the same
+// values as --since & --until.  A to date is inclusive.
+func (s *server) timeRange(r *http.Request) (time.Time, time.Time, error) {
+	from := r.URL.Query().Get("from")
+	if from == "" {
+		from = s.since
+	}
+	to := r.URL.Query().Get("to")
+	if to == "" {
+		to = "now"
+	}
+	return s.db.ParseTimeRange(from, to, time.Now())
+}
+
+// returned for hotspots which aren't in the hotspot cache
+type notFoundError struct {
+	hotspot string
+}
+
+func (e notFoundError) Error() string {
+	return fmt.Sprintf("Unknown hotspot: %s", e.hotspot)
+}
+
+// returns 404 for unknown hotspots and the given status for other errors
+func errorStatus(err error, status int) int {
+	if errors.As(err, &notFoundError{}) {
+		return http.StatusNotFound
+	}
+	return status
+}
+
+// returns the address of a hotspot in the hotspot cache.  Caller must hold s.lock
+func (s *server) resolve(hotspot string) (string, error) {
+	address, err := s.db.GetHotspotByUnknown(hotspot)
+	if err != nil {
+		return "", notFoundError{hotspot: hotspot}
+	}
+	// GetHotspot doesn't fail on unknown addresses
+	if h, err := s.db.GetHotspot(address); err != nil || h.Address == "" {
+		return "", notFoundError{hotspot: hotspot}
+	}
+	return address, nil
+}
+
+// resolves the hotspot and loads the challenges for the requested time range.
+// Caller must hold s.lock
+func (s *server) challenges(r *http.Request, hotspot string) (string, []analysis.Challenges, error) {
+	address, err := s.resolve(hotspot)
+	if err != nil {
+		return "", []analysis.Challenges{}, err
+	}
+	first, last, err := s.timeRange(r)
+	if err != nil {
+		return "", []analysis.Challenges{}, err
+	}
+	challenges, err := s.db.GetChallenges(address, first, last)
+	return address, challenges, err
+}
+
+func (s *server) dashboard(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	s.lock.Lock()
+	first, _, err := s.db.ParseTime(s.since, time.Now())
+	s.lock.Unlock()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	fmt.Fprintf(w, DASHBOARD_HTML, analysis.FormatDate(first), analysis.FormatDate(time.Now()))
+}
+
+// GET /api/hotspots?q=<name, address or owner>
+func (s *server) apiHotspots(w http.ResponseWriter, r *http.Request) {
+	q := strings.ToLower(strings.ReplaceAll(r.URL.Query().Get("q"), " ", "-"))
+
+	s.lock.Lock()
+	hotspots, err := s.db.GetHotspots()
+	s.lock.Unlock()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	ret := []serveHotspot{}
+	for _, h := range hotspots {
+		if q != "" && !strings.Contains(strings.ToLower(h.Name), q) &&
+			!strings.HasPrefix(h.Address, q) && h.Owner != q {
+			continue
+		}
+		ret = append(ret, serveHotspot{
+			Address: h.Address,
+			Name:    h.Name,
+			Owner:   h.Owner,
+		})
+	}
+	sort.SliceStable(ret, func(i, j int) bool {
+		return ret[i].Name < ret[j].Name
+	})
+	if len(ret) > SERVE_MAX_HOTSPOTS {
+		ret = ret[:SERVE_MAX_HOTSPOTS]
+	}
+	writeJson(w, http.StatusOK, ret)
+}
+
+// GET /api/challenges/<hotspot>
+func (s *server) apiChallenges(w http.ResponseWriter, r *http.Request) {
+	args, err := pathArgs(r, "/api/challenges/", 1)
+	if err != nil {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	_, challenges, err := s.challenges(r, args[0])
+	if err != nil {
+		writeError(w, errorStatus(err, http.StatusBadRequest), err)
+		return
+	}
+	writeJson(w, http.StatusOK, challenges)
+}
+
+// GET /api/witnesses/<hotspot>/<peer>
+func (s *server) apiWitnesses(w http.ResponseWriter, r *http.Request) {
+	args, err := pathArgs(r, "/api/witnesses/", 2)
+	if err != nil {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	address, challenges, err := s.challenges(r, args[0])
+	if err != nil {
+		writeError(w, errorStatus(err, http.StatusBadRequest), err)
+		return
+	}
+	peer, err := s.resolve(args[1])
+	if err != nil {
+		writeError(w, errorStatus(err, http.StatusBadRequest), err)
+		return
+	}
+	results, err := s.db.GetWitnessResults(address, peer, challenges)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJson(w, http.StatusOK, results)
+}
+
+// GET /api/peers/<hotspot>
+func (s *server) apiPeers(w http.ResponseWriter, r *http.Request) {
+	args, err := pathArgs(r, "/api/peers/", 1)
+	if err != nil {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	address, challenges, err := s.challenges(r, args[0])
+	if err != nil {
+		writeError(w, errorStatus(err, http.StatusBadRequest), err)
+		return
+	}
+	stats, err := s.db.GetPeerStats(address, challenges)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJson(w, http.StatusOK, stats)
+}
+
+// GET /graph/<hotspot>/beacons.png
+// GET /graph/<hotspot>/witnesses.png
+// GET /graph/<hotspot>/peer/<peer>.png
+func (s *server) graph(w http.ResponseWriter, r *http.Request) {
+	args := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/graph/"), "/"), "/")
+	if len(args) < 2 || !strings.HasSuffix(args[len(args)-1], ".png") {
+		http.NotFound(w, r)
+		return
+	}
+	args[len(args)-1] = strings.TrimSuffix(args[len(args)-1], ".png")
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	address, challenges, err := s.challenges(r, args[0])
+	if err != nil {
+		http.Error(w, err.Error(), errorStatus(err, http.StatusBadRequest))
+		return
+	}
+	name, err := s.db.GetHotspotName(address)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	settings := analysis.GraphSettings{
+		Min:        s.minimum,
+		Zoom:       r.URL.Query().Get("zoom") != "",
+		Collection: analysis.NewGraphCollection(),
+	}
+
+	var basename string
+	switch {
+	case len(args) == 2 && args[1] == "beacons":
+		basename = fmt.Sprintf("%s/beacon-totals", name)
+		err = s.db.GenerateBeaconsGraph(address, challenges, settings)
+	case len(args) == 2 && args[1] == "witnesses":
+		basename = fmt.Sprintf("%s/witness-distance", name)
+		err = s.db.GenerateWitnessesGraph(address, challenges, settings)
+	case len(args) == 3 && args[1] == "peer":
+		var peer, peerName string
+		if peer, err = s.resolve(args[2]); err == nil {
+			if peerName, err = s.db.GetHotspotName(peer); err == nil {
+				basename = fmt.Sprintf("%s/%s", name, peerName)
+				err = s.db.GeneratePeerGraph(address, peer, challenges, settings)
+			}
+		}
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), errorStatus(err, http.StatusBadRequest))
+		return
+	}
+
+	png, ok := settings.Collection.Get(basename)
+	if !ok {
+		http.Error(w, fmt.Sprintf("Not enough data to graph %s", basename), http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "image/png")
+	if _, err = w.Write(png); err != nil {
+		log.WithError(err).Errorf("Unable to send %s", basename)
+	}
+}
+
+// fmt template: default from & to dates
+const DASHBOARD_HTML = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Helium Analysis</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #222; }
+#results { list-style: none; padding: 0; max-height: 12em; overflow-y: auto; }
+#results li { cursor: pointer; padding: 2px 4px; }
+#results li:hover { background: #eef; }
+img { max-width: 100%%; display: block; margin: 1em 0; }
+table { border-collapse: collapse; font-size: 90%%; }
+th, td { border: 1px solid #ccc; padding: 3px 8px; text-align: right; }
+th { background: #eee; }
+td:first-child { text-align: left; }
+a { cursor: pointer; color: #03c; }
+.error { color: #c00; }
+</style>
+</head>
+<body>
+<h1>Helium Analysis</h1>
+<p>
+<input id="search" size="50" placeholder="Hotspot name, address or owner">
+From <input id="from" type="date" value="%s">
+To <input id="to" type="date" value="%s">
+<label><input id="zoom" type="checkbox"> Zoom</label>
+</p>
+<ul id="results"></ul>
+<div id="hotspot"></div>
+<script>
+var current = "";
+
+function $(id) { return document.getElementById(id); }
+
+function esc(s) {
+	var d = document.createElement("div");
+	d.textContent = s;
+	return d.innerHTML;
+}
+
+function query() {
+	var q = "?from=" + $("from").value + "&to=" + $("to").value;
+	if ($("zoom").checked) { q += "&zoom=1"; }
+	return q;
+}
+
+function search() {
+	fetch("/api/hotspots?q=" + encodeURIComponent($("search").value))
+		.then(function(r) { return r.json(); })
+		.then(function(hotspots) {
+			var ul = $("results");
+			ul.innerHTML = "";
+			hotspots.forEach(function(h) {
+				var li = document.createElement("li");
+				li.textContent = h.name + " (" + h.address + ")";
+				li.onclick = function() { show(h.address, h.name); };
+				ul.appendChild(li);
+			});
+		});
+}
+
+function show(address, name) {
+	current = address;
+	$("results").innerHTML = "";
+	var div = $("hotspot");
+	div.innerHTML = "<h2>" + esc(name) + "</h2>" +
+		"<img src=\"/graph/" + address + "/beacons.png" + query() + "\" alt=\"beacons\">" +
+		"<img src=\"/graph/" + address + "/witnesses.png" + query() + "\" alt=\"witnesses\">" +
+		"<h3>Peers</h3><div id=\"peers\">Loading...</div><div id=\"peer\"></div>";
+	fetch("/api/peers/" + address + query())
+		.then(function(r) { return r.json(); })
+		.then(function(peers) {
+			if (peers.error) {
+				$("peers").innerHTML = "<p class=\"error\">" + esc(peers.error) + "</p>";
+				return;
+			}
+			var html = "<table><tr><th>Peer</th><th>Km</th><th>TX</th><th>RX</th>" +
+				"<th>Valid</th><th>Invalid</th><th>RSSI</th><th>SNR</th><th>Online</th></tr>";
+			peers.forEach(function(p) {
+				html += "<tr><td><a onclick=\"peer('" + p.address + "')\">" + esc(p.name) + "</a></td>" +
+					"<td>" + p.km.toFixed(2) + "</td><td>" + p.tx_count + "</td><td>" + p.rx_count + "</td>" +
+					"<td>" + p.valid_count + "</td><td>" + p.invalid_count + "</td>" +
+					"<td>" + p.rssi_median.toFixed(1) + "</td><td>" + p.snr_median.toFixed(1) + "</td>" +
+					"<td>" + esc(p.online) + "</td></tr>";
+			});
+			$("peers").innerHTML = html + "</table>";
+		});
+}
+
+function peer(address) {
+	$("peer").innerHTML = "<img src=\"/graph/" + current + "/peer/" + address + ".png" + query() + "\" alt=\"peer\">";
+}
+
+$("search").addEventListener("input", search);
+["from", "to", "zoom"].forEach(function(id) {
+	$(id).addEventListener("change", function() {
+		if (current !== "") { show(current, $("hotspot").querySelector("h2").textContent); }
+	});
+});
+</script>
+</body>
+</html>
+`
@@ -0,0 +1,149 @@
+package main
+
+/*
+ * Helium Analysis
+ * Copyright (c) 2021-2022 Aaron Turner  <aturner at synfin dot net>
+ *
+ * This program is free software: you can redistribute it
+ * and/or modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or with the authors permission any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/synfinatic/helium-analysis/analysis"
+	"github.com/synfinatic/onelogin-aws-role/utils"
+)
+
+type SimulateCmd struct {
+	Address        string  `kong:"arg,required,name='address',help='Hotspot address or name to simulate moving'"`
+	Since          string  `kong:"name='since',default='30d',help='Start of the time range: YYYY-MM-DD, RFC3339, age (3d, 12h, 2w) or block height'"`
+	Until          string  `kong:"name='until',default='now',help='End of the time range: now, YYYY-MM-DD, RFC3339, age or block height'"`
+	Lat            string  `kong:"name='lat',help='Candidate latitude, use --lat=-33.9 for negative values (default: current location)'"`
+	Lng            string  `kong:"name='lng',help='Candidate longitude, use --lng=-122.4 for negative values (default: current location)'"`
+	Gain           float64 `kong:"name='gain',default=1.8,help='Candidate antenna gain in dBi'"`
+	Height         float64 `kong:"name='height',default=0,help='Candidate antenna height in meters (0 = unchanged)'"`
+	CurrentGain    float64 `kong:"name='current-gain',default=1.8,help='Current antenna gain in dBi'"`
+	CurrentHeight  float64 `kong:"name='current-height',default=0,help='Current antenna height in meters (0 = unknown)'"`
+	Sensitivity    float64 `kong:"name='sensitivity',default=-130.0,help='Weakest RSSI (dBm) which can be heard'"`
+	MinProbability float64 `kong:"name='min-probability',default=0.5,help='Only list hotspots with at least this probability of a link'"`
+	Format         string  `kong:"name='format',short='f',default='table',enum='table,csv,json',help='Output format [table|csv|json]'"`
+	Output         string  `kong:"name='output',short='o',default='stdout',help='Output file for csv/json'"`
+}
+
+func (cmd *SimulateCmd) Run(ctx *RunContext) error {
+	cli := *ctx.Cli
+
+	firstTime, lastTime, err := ctx.BoltDB.ParseTimeRange(cli.Simulate.Since, cli.Simulate.Until, time.Now())
+	if err != nil {
+		return err
+	}
+
+	hotspotAddress, err := ctx.BoltDB.GetHotspotByUnknown(cli.Simulate.Address)
+	if err != nil {
+		return err
+	}
+	host, err := ctx.BoltDB.GetHotspot(hotspotAddress)
+	if err != nil {
+		return err
+	}
+
+	settings := analysis.SimulateSettings{
+		Lat:           host.Lat,
+		Lng:           host.Lng,
+		Gain:          cli.Simulate.Gain,
+		Height:        cli.Simulate.Height,
+		CurrentGain:   cli.Simulate.CurrentGain,
+		CurrentHeight: cli.Simulate.CurrentHeight,
+		Sensitivity:   cli.Simulate.Sensitivity,
+	}
+	if cli.Simulate.Lat != "" {
+		if settings.Lat, err = parseCoordinate(cli.Simulate.Lat, 90.0); err != nil {
+			return fmt.Errorf("Invalid --lat: %s", err)
+		}
+	}
+	if cli.Simulate.Lng != "" {
+		if settings.Lng, err = parseCoordinate(cli.Simulate.Lng, 180.0); err != nil {
+			return fmt.Errorf("Invalid --lng: %s", err)
+		}
+	}
+
+	challenges, err := ctx.BoltDB.GetChallenges(hotspotAddress, firstTime, lastTime)
+	if err != nil {
+		return err
+	}
+
+	report, err := ctx.BoltDB.Simulate(hotspotAddress, challenges, settings, cli.Simulate.MinProbability)
+	if err != nil {
+		return err
+	}
+
+	ts := []utils.TableStruct{}
+	for _, p := range report.Peers {
+		ts = append(ts, SimulateReport{
+			Name:     p.Name,
+			Km:       fmt.Sprintf("%.02f", p.Km),
+			Bearing:  fmt.Sprintf("%.0f", p.Bearing),
+			TxRssi:   fmt.Sprintf("%.1f", p.TxRssi),
+			TxChance: fmt.Sprintf("%.0f%%", p.TxChance*100.0),
+			RxRssi:   fmt.Sprintf("%.1f", p.RxRssi),
+			RxChance: fmt.Sprintf("%.0f%%", p.RxChance*100.0),
+			MaxRssi:  fmt.Sprintf("%.1f", p.MaxRssi),
+			Flags:    strings.Join(p.Flags, ", "),
+		})
+	}
+	fields := []string{"Name", "Km", "Bearing", "TxRssi", "TxChance", "RxRssi", "RxChance", "MaxRssi", "Flags"}
+	if err = writeReport(cli.Simulate.Format, cli.Simulate.Output, ts, fields, report); err != nil {
+		return err
+	}
+
+	if cli.Simulate.Format == "table" {
+		fmt.Printf("At %.5f, %.5f (%+.1fdB vs. today): %d hotspots likely hear us and we likely hear %d\n",
+			report.Lat, report.Lng, report.Adjustment, report.TxPeers, report.RxPeers)
+	}
+	return nil
+}
+
+// parses a latitude or longitude in decimal degrees
+func parseCoordinate(s string, limit float64) (float64, error) {
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0.0, err
+	}
+	if v < -limit || v > limit {
+		return 0.0, fmt.Errorf("%s is not between -%.0f and %.0f", s, limit, limit)
+	}
+	return v, nil
+}
+
+// Necessary for utils.TableStruct magic
+type SimulateReport struct {
+	Name     string `header:"Name"`
+	Km       string `header:"Km"`
+	Bearing  string `header:"Bearing"`
+	TxRssi   string `header:"TX RSSI"`
+	TxChance string `header:"TX Chance"`
+	RxRssi   string `header:"RX RSSI"`
+	RxChance string `header:"RX Chance"`
+	MaxRssi  string `header:"Max RSSI"`
+	Flags    string `header:"Flags"`
+}
+
+func (sr SimulateReport) GetHeader(fieldName string) (string, error) {
+	v := reflect.ValueOf(sr)
+	return utils.GetHeaderTag(v, fieldName)
+}
@@ -0,0 +1,287 @@
+package main
+
+/*
+ * Helium Analysis
+ * Copyright (c) 2021-2022 Aaron Turner  <aturner at synfin dot net>
+ *
+ * This program is free software: you can redistribute it
+ * and/or modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or with the authors permission any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"os/signal"
+	"reflect"
+	"syscall"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/synfinatic/helium-analysis/analysis"
+	"github.com/synfinatic/onelogin-aws-role/utils"
+)
+
+type WatchCmd struct {
+	Address    []string      `kong:"arg,optional,name='address',help='Hotspot address(es) or name(s) to watch'"`
+	Owner      []string      `kong:"name='owner',help='Watch all hotspots owned by these wallet(s)'"`
+	Interval   time.Duration `kong:"name='interval',short='i',default='6h',help='How often to refresh each hotspot'"`
+	Jitter     time.Duration `kong:"name='jitter',short='j',default='15m',help='Randomly spread each refresh by up to +/- this much'"`
+	Since      string        `kong:"name='since',default='30d',help='Start of the time range to graph: YYYY-MM-DD, RFC3339, age (3d, 12h, 2w) or block height'"`
+	Buffer     int64         `kong:"name='buffer',short='b',default=6,help='Challenge buffer in hours'"`
+	Minimum    int           `kong:"name='minimum',short='m',default=5,help='Minimum required challenges to generate a graph'"`
+	Format     []string      `kong:"name='format',short='f',default='png',help='Graph format(s): png, svg and/or pdf'"`
+	SkipReport bool          `kong:"name='skip-report',default=false,help='Do not generate the HTML report'"`
+	Alerts     string        `kong:"name='alerts',short='a',help='JSON file with alert rules to check after each refresh'"`
+	Webhook    []string      `kong:"name='webhook',short='w',help='Additional webhook URL(s) to POST alerts to'"`
+	Once       bool          `kong:"name='once',default=false,help='Refresh every hotspot once, ignoring the schedule, and exit'"`
+	Status     bool          `kong:"name='status',default=false,help='Show the schedule of every hotspot and exit'"`
+
+	alerts *analysis.AlertConfig
+}
+
+type WatchReport struct {
+	Name        string `header:"Hotspot"`
+	LastSuccess string `header:"Last Success"`
+	NextRun     string `header:"Next Run"`
+	Failures    int    `header:"Failures"`
+	LastError   string `header:"Last Error"`
+}
+
+func (wr WatchReport) GetHeader(fieldName string) (string, error) {
+	v := reflect.ValueOf(wr)
+	return utils.GetHeaderTag(v, fieldName)
+}
+
+func (cmd *WatchCmd) Run(ctx *RunContext) error {
+	ctx.Cli.Watch.Address = trackedHotspots(ctx.Cli.Watch.Address, ctx.Cli.Watch.Owner)
+	cli := *ctx.Cli
+
+	if cli.Watch.Minimum < 2 {
+		return fmt.Errorf("Please specify a --minimum value >= 2")
+	}
+	if _, _, err := ctx.BoltDB.ParseTimeRange(cli.Watch.Since, "now", time.Now()); err != nil {
+		return err
+	}
+	if cli.Watch.Interval < time.Minute {
+		return fmt.Errorf("Please specify an --interval >= 1m")
+	}
+	if cli.Watch.Jitter < 0 || cli.Watch.Jitter >= cli.Watch.Interval {
+		return fmt.Errorf("--jitter must be >= 0 and less than --interval")
+	}
+	if err := analysis.ValidateGraphFormats(cli.Watch.Format); err != nil {
+		return err
+	}
+	if len(cli.Watch.Address) == 0 && len(cli.Watch.Owner) == 0 {
+		return fmt.Errorf("Please specify one or more hotspots and/or --owner or add hotspots to the config file")
+	}
+
+	if cli.Watch.Status {
+		return cmd.status(ctx)
+	}
+
+	if cli.Watch.Alerts != "" {
+		config, err := loadAlertConfig(cli.Watch.Alerts, cli.Watch.Webhook)
+		if err != nil {
+			return err
+		}
+		cmd.alerts = &config
+	} else if len(cli.Watch.Webhook) > 0 {
+		return fmt.Errorf("--webhook requires --alerts")
+	}
+
+	rand.Seed(time.Now().UnixNano())
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+
+	for {
+		ctx.Output.Now = time.Now()
+		next, err := cmd.runDue(ctx, ctx.Output.Now)
+		if err != nil {
+			log.WithError(err).Errorf("Unable to refresh hotspots")
+			next = time.Now().Add(analysis.WATCH_BACKOFF_MIN)
+		}
+		if err := ctx.Output.WriteManifest(); err != nil {
+			log.WithError(err).Errorf("Unable to write manifest")
+		}
+		if cli.Watch.Once {
+			return err
+		}
+
+		log.Infof("Sleeping until %s", next.Format(analysis.TIME_FORMAT))
+		select {
+		case sig := <-sigs:
+			log.Infof("Received %s.  Exiting.", sig)
+			return nil
+		case <-time.After(time.Until(next)):
+		}
+	}
+}
+
+// Refresh every hotspot which is due and returns when the next one is due
+func (cmd *WatchCmd) runDue(ctx *RunContext, now time.Time) (time.Time, error) {
+	cli := *ctx.Cli
+	next := now.Add(cli.Watch.Interval)
+
+	hotspots, err := resolveHotspotList(ctx, cli.Watch.Address, cli.Watch.Owner)
+	if err != nil {
+		return next, err
+	}
+
+	states := []analysis.WatchState{}
+	for _, hotspotAddress := range hotspots {
+		state, err := ctx.BoltDB.GetWatchState(hotspotAddress)
+		if err != nil {
+			return next, err
+		}
+		if cli.Watch.Once || state.Due(now) {
+			states = append(states, state)
+		} else if t := time.Unix(state.NextRun, 0); t.Before(next) {
+			next = t
+		}
+	}
+	if len(states) == 0 {
+		return next, nil
+	}
+
+	err = ctx.BoltDB.AutoRefreshHotspots(HOTSPOT_REFRESH)
+	if err != nil {
+		log.WithError(err).Warnf("Unable to refresh hotspot data.  Using cache.")
+	}
+
+	for _, state := range states {
+		err = cmd.refresh(ctx, state.Address)
+		if err != nil {
+			log.WithError(err).Errorf("Unable to refresh %s", state.Address)
+		}
+		state.Finished(time.Now(), cli.Watch.Interval, cli.Watch.Jitter, err)
+		if err = ctx.BoltDB.SetWatchState(state); err != nil {
+			return next, err
+		}
+		if t := time.Unix(state.NextRun, 0); t.Before(next) {
+			next = t
+		}
+	}
+	return next, nil
+}
+
+// Load the latest challenges and regenerate the graphs & report for a hotspot
+func (cmd *WatchCmd) refresh(ctx *RunContext, hotspotAddress string) error {
+	cli := *ctx.Cli
+
+	name, err := ctx.BoltDB.GetHotspotName(hotspotAddress)
+	if err != nil {
+		return err
+	}
+	log.Infof("Refreshing %s", name)
+
+	// relative --since values move forward with every refresh
+	firstTime, lastTime, err := ctx.BoltDB.ParseTimeRange(cli.Watch.Since, "now", time.Now())
+	if err != nil {
+		return err
+	}
+	duration := time.Duration(time.Hour * time.Duration(cli.Watch.Buffer))
+	err = ctx.BoltDB.LoadChallenges(hotspotAddress, firstTime, lastTime, duration)
+	if err != nil {
+		return err
+	}
+
+	challenges, err := ctx.BoltDB.GetChallenges(hotspotAddress, firstTime, lastTime)
+	if err != nil {
+		return fmt.Errorf("Unable to load challenges: %s", err)
+	}
+
+	if cmd.alerts != nil {
+		_, err = checkAlerts(ctx.BoltDB, hotspotAddress, challenges, *cmd.alerts, time.Now().UTC(), false)
+		if err != nil {
+			log.WithError(err).Errorf("Unable to check alerts for %s", name)
+		}
+	}
+
+	settings := analysis.GraphSettings{
+		Min:     cli.Watch.Minimum,
+		Formats: cli.Watch.Format,
+		Output:  ctx.Output,
+	}
+	if err = generateGraphs(ctx.BoltDB, hotspotAddress, name, challenges, settings); err != nil {
+		return err
+	}
+
+	if cli.Watch.SkipReport {
+		return nil
+	}
+	f, err := ctx.Output.Create(analysis.Artifact{
+		Type:    analysis.ARTIFACT_REPORT,
+		Format:  "html",
+		Hotspot: name,
+		Address: hotspotAddress,
+		Graph:   REPORT_NAME,
+	})
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	settings = analysis.GraphSettings{
+		Min: cli.Watch.Minimum,
+	}
+	if err = ctx.BoltDB.GenerateReport(hotspotAddress, challenges, firstTime, lastTime, settings, f); err != nil {
+		return err
+	}
+	log.Infof("Created %s", f.Name())
+	return nil
+}
+
+// Print the schedule of each watched hotspot
+func (cmd *WatchCmd) status(ctx *RunContext) error {
+	cli := *ctx.Cli
+	hotspots, err := resolveHotspotList(ctx, cli.Watch.Address, cli.Watch.Owner)
+	if err != nil {
+		return err
+	}
+
+	formatTime := func(t int64) string {
+		if t == 0 {
+			return "never"
+		}
+		return analysis.FormatUnix(t)
+	}
+
+	rows := []utils.TableStruct{}
+	states := []analysis.WatchState{}
+	for _, hotspotAddress := range hotspots {
+		state, err := ctx.BoltDB.GetWatchState(hotspotAddress)
+		if err != nil {
+			return err
+		}
+		states = append(states, state)
+
+		name, err := ctx.BoltDB.GetHotspotName(hotspotAddress)
+		if err != nil {
+			return err
+		}
+		nextRun := "now"
+		if state.NextRun > 0 {
+			nextRun = formatTime(state.NextRun)
+		}
+		rows = append(rows, WatchReport{
+			Name:        name,
+			LastSuccess: formatTime(state.LastSuccess),
+			NextRun:     nextRun,
+			Failures:    state.Failures,
+			LastError:   state.LastError,
+		})
+	}
+
+	fields := []string{"Name", "LastSuccess", "NextRun", "Failures", "LastError"}
+	return writeReport("table", "", rows, fields, states)
+}
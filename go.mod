@@ -6,10 +6,13 @@ require (
 	github.com/alecthomas/kong v0.2.16
 	github.com/davecgh/go-spew v1.1.1
 	github.com/go-resty/resty/v2 v2.7.0
+	github.com/golang/freetype v0.0.0-20170609003504-e2365dfdc4a0
+	github.com/jung-kurt/gofpdf v1.16.2
 	github.com/mattn/go-colorable v0.1.8
 	github.com/sirupsen/logrus v1.7.0
 	github.com/synfinatic/onelogin-aws-role v0.1.0
 	github.com/umahmood/haversine v0.0.0-20151105152445-808ab04add26
 	github.com/wcharczuk/go-chart/v2 v2.1.0
 	go.etcd.io/bbolt v1.3.5
+	gopkg.in/yaml.v2 v2.4.0
 )